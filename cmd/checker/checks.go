@@ -0,0 +1,38 @@
+package main
+
+import (
+	"github.com/kubescape/sizing-checker/pkg/checks/connectivitycheck"
+	"github.com/kubescape/sizing-checker/pkg/checks/ebpfcheck"
+	"github.com/kubescape/sizing-checker/pkg/checks/headroomcheck"
+	"github.com/kubescape/sizing-checker/pkg/checks/platformcheck"
+	"github.com/kubescape/sizing-checker/pkg/checks/pvcheck"
+	"github.com/kubescape/sizing-checker/pkg/checks/rbaccheck"
+	"github.com/kubescape/sizing-checker/pkg/checks/runtimecheck"
+	"github.com/kubescape/sizing-checker/pkg/checks/sizing"
+	"github.com/kubescape/sizing-checker/pkg/checks/taintcheck"
+	"github.com/kubescape/sizing-checker/pkg/common"
+)
+
+// To add an in-house check, implement common.Check in its own package, call
+// common.RegisterCheck from that package's init function and blank-import it
+// from a new file next to this one:
+//
+//	import _ "example.com/acme/admissioncheck"
+
+// allChecks returns the built-in checks followed by every registered check,
+// in the order they should appear in the report. Preflight checks run before
+// the cluster data is collected, the others after.
+func allChecks() []common.Check {
+	builtin := []common.Check{
+		rbaccheck.Check{},
+		sizing.Check{},
+		pvcheck.Check{},
+		connectivitycheck.Check{},
+		ebpfcheck.Check{},
+		runtimecheck.Check{},
+		platformcheck.Check{},
+		taintcheck.Check{},
+		headroomcheck.Check{},
+	}
+	return append(builtin, common.RegisteredChecks()...)
+}
@@ -3,44 +3,192 @@ package main
 import (
 	"context"
 	"flag"
+	"fmt"
 	"log"
+	"os"
+	"path/filepath"
+	"strings"
 
-	"github.com/kubescape/sizing-checker/pkg/checks/connectivitycheck"
-	"github.com/kubescape/sizing-checker/pkg/checks/ebpfcheck"
-	"github.com/kubescape/sizing-checker/pkg/checks/pvcheck"
 	"github.com/kubescape/sizing-checker/pkg/checks/sizing"
 	"github.com/kubescape/sizing-checker/pkg/common"
+	"github.com/kubescape/sizing-checker/pkg/nodeprobe"
 )
 
 func main() {
 	// Add a new CLI flag to specify a custom kubeconfig path
 	kubeconfigPath := flag.String("kubeconfig", "", "Path to the kubeconfig file. If not set, in-cluster config is used or $HOME/.kube/config if outside a cluster.")
+	kubeContext := flag.String("context", "", "Kubeconfig context to use instead of the current context.")
+	contextList := flag.String("contexts", "", "Comma-separated kubeconfig contexts to check one after the other. Each cluster gets its own reports in <output-dir>/<context>, followed by a fleet summary.")
+	allContexts := flag.Bool("all-contexts", false, "Check every context of the kubeconfig, like --contexts.")
+	as := flag.String("as", "", "Username to impersonate, like kubectl --as.")
+	asGroups := flag.String("as-group", "", "Comma-separated groups to impersonate, like kubectl --as-group.")
+	printSchema := flag.Bool("print-report-schema", false, "Print the JSON schema of prerequisites-report.json and exit.")
+	failOn := flag.String("fail-on", "none", "Comma-separated policy deciding when to exit non-zero: a level (none, warn, error, fail) applied to all checks, "+
+		"check names (fail when that check fails) and/or <check>:<level> entries, e.g. \"fail,ebpf:warn\". "+
+		fmt.Sprintf("Exit codes: %d warn, %d error, %d fail.", common.ExitCheckWarn, common.ExitCheckErr, common.ExitCheckFail))
+	outputDir := flag.String("output-dir", "", "Directory to write the report files to when running outside the cluster (default: the system temp directory).")
+	formats := flag.String("formats", common.DefaultFormats, "Comma-separated artifacts to produce: html (report and review-values page), json, yaml, values, dump.")
+	fileNames := flag.String("file-names", "", "Comma-separated <artifact>=<file name> overrides, e.g. \"json=cluster-a.json,values=cluster-a-values.yaml\". Artifacts: html, review, json, yaml, values, dump.")
+	configMapName := flag.String("configmap-name", common.DefaultConfigMapName, "Name of the ConfigMap in-cluster runs store the report in. Large reports add <name>-part-N ConfigMaps.")
+	configMapNamespace := flag.String("configmap-namespace", common.DefaultConfigMapNamespace, "Namespace of the report ConfigMap.")
+	configMapLabels := flag.String("configmap-labels", "", "Comma-separated key=value labels to set on the report ConfigMap(s).")
+	configMapMode := flag.String("configmap-mode", common.ConfigMapModeAuto, "How artifacts are stored in the ConfigMap: auto (gzip only artifacts that would not fit), gzip (gzip everything into binaryData) or plain (skip artifacts that do not fit).")
+	pageSize := flag.Int64("page-size", common.DefaultPageSize, "Number of objects fetched per List call while collecting cluster data; 0 disables pagination.")
+	slim := flag.Bool("slim", false, "Keep only the object fields the checks need (metadata only for counted workloads) to bound memory on very large clusters. The cluster dump is slimmed too.")
+	concurrency := flag.Int("collect-concurrency", common.DefaultCollectConcurrency, "Maximum number of resource kinds listed in parallel.")
+	timeout := flag.Duration("timeout", 0, "Overall time limit for collecting cluster data and running the checks of each cluster, e.g. 5m. 0 means no limit.")
+	checkTimeout := flag.Duration("check-timeout", common.DefaultCheckTimeout, "Time limit of each check; a check exceeding it is reported as Error.")
+	fromDump := flag.String("from-dump", "", "Run the checks offline against a full-cluster-dump.yaml written by a previous run, without cluster access. A comma-separated list of dumps is checked like --contexts, with a fleet summary.")
+	nodeProbe := flag.Bool("node-probe", false, "Schedule a short-lived probe DaemonSet on every Linux node to check its kernel config, BTF and BPF syscall, and report them per node. Nodes with taints the probe does not tolerate are skipped.")
+	probeImage := flag.String("probe-image", nodeprobe.DefaultImage, "Checker image the node probe runs.")
+	probeNamespace := flag.String("probe-namespace", nodeprobe.DefaultNamespace, "Namespace the node probe DaemonSet is created in. It must allow privileged Pods.")
+	probeTimeout := flag.Duration("probe-timeout", nodeprobe.DefaultTimeout, "Time to wait for the node probe results of all nodes.")
+	probeTolerateAll := flag.Bool("probe-tolerate-all", false, "Let the node probe tolerate every taint, so tainted nodes are probed too.")
+	chartVersion := flag.String("chart-version", "", "Kubescape chart version to size for, e.g. 1.25.3. Selects the sizing profile (chart defaults and formulas) of that release; the newest profile is used by default.")
+	sizingProfiles := flag.String("sizing-profiles", "", "YAML file with sizing profiles that extend or replace the embedded ones (same format as pkg/checks/sizing/sizing_profiles.yaml).")
+	nodePoolLabel := flag.String("node-pool-label", "", "Node label grouping the nodes into pools for the per-pool node-agent sizing and --exclude-node-pools, checked before the EKS, GKE, AKS and Karpenter pool labels.")
+	excludeNodePools := flag.String("exclude-node-pools", "", "Comma-separated node pools meant to run without the node-agent. Their taints get no toleration in recommended-values.yaml, and the file documents the exclusion; with per-pool sizing they get no node-agent DaemonSet.")
+	runNodeProbe := flag.Bool(strings.TrimPrefix(nodeprobe.ProbeArg, "--"), false, "Run as the node probe: inspect this node and write the result to the termination log. Used by the --node-probe DaemonSet.")
 	flag.Parse()
 
-	clientset, inCluster := common.BuildKubeClient(*kubeconfigPath)
-	if clientset == nil {
-		log.Fatal("Could not create kube client. Exiting.")
+	if *runNodeProbe {
+		if err := nodeprobe.WriteResult(nodeprobe.Gather(os.Getenv("NODE_NAME"), nodeprobe.HostRoot)); err != nil {
+			log.Fatalf("Failed to write the node probe result: %v", err)
+		}
+		return
 	}
 
-	ctx := context.Background()
+	if *printSchema {
+		fmt.Print(common.ReportJSONSchema)
+		return
+	}
 
-	// 1) Collect cluster data
-	clusterData, err := common.CollectClusterData(ctx, clientset)
+	checks := allChecks()
+	policy, err := common.ParseFailOnPolicy(*failOn)
+	if err == nil {
+		err = policy.Validate(checks)
+	}
+	var outputOpts common.OutputOptions
+	if err == nil {
+		outputOpts, err = common.NewOutputOptions(*outputDir, *formats, *fileNames)
+	}
+	if err == nil && *pageSize < 0 {
+		err = fmt.Errorf("--page-size must not be negative")
+	}
+	if err == nil && *concurrency < 1 {
+		err = fmt.Errorf("--collect-concurrency must be at least 1")
+	}
+	if err == nil && (*timeout < 0 || *checkTimeout <= 0) {
+		err = fmt.Errorf("--timeout must not be negative and --check-timeout must be positive")
+	}
+	if err == nil {
+		outputOpts.ConfigMap, err = common.NewConfigMapOptions(*configMapName, *configMapNamespace, *configMapLabels, *configMapMode)
+	}
+	if err == nil && *nodeProbe && (*fromDump != "" || *probeTimeout <= 0) {
+		err = fmt.Errorf("--node-probe needs cluster access (no --from-dump) and a positive --probe-timeout")
+	}
+	if err == nil && *fromDump != "" && (*kubeContext != "" || *contextList != "" || *allContexts || *as != "" || *asGroups != "") {
+		err = fmt.Errorf("--from-dump runs offline and cannot be combined with --context, --contexts, --all-contexts, --as or --as-group")
+	}
+	var sizingProfile *common.SizingProfile
+	if err == nil {
+		sizingProfile, err = sizing.LoadProfile(*sizingProfiles, *chartVersion)
+	}
+	var fleet []fleetTarget
+	if err == nil {
+		fleet, err = selectFleet(*kubeconfigPath, *kubeContext, *contextList, *allContexts, *fromDump)
+	}
 	if err != nil {
-		log.Printf("Failed to collect cluster data: %v", err)
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
 	}
 
-	// 2) Run checks
-	sizingResult := sizing.RunSizingChecker(clusterData)
-	pvResult := pvcheck.RunPVProvisioningCheck(ctx, clientset, clusterData, inCluster)
-	connectivityResult := connectivitycheck.RunConnectivityChecks(ctx, clientset, clusterData, inCluster)
-	ebpfResult := ebpfcheck.RunEbpfCheck(ctx, clientset, clusterData, inCluster)
+	r := runner{
+		checks:       checks,
+		timeout:      *timeout,
+		checkTimeout: *checkTimeout,
+		kube: common.KubeClientOptions{
+			Kubeconfig: *kubeconfigPath,
+			As:         *as,
+			AsGroups:   splitList(*asGroups),
+		},
+		collect: common.CollectOptions{
+			PageSize:    *pageSize,
+			Concurrency: *concurrency,
+			Slim:        *slim,
+		},
+		reportNamespace: outputOpts.ConfigMap.Namespace,
+		excludedPools:   splitList(*excludeNodePools),
+		sizingProfile:   sizingProfile,
+		nodePoolLabel:   *nodePoolLabel,
+	}
+	if *nodeProbe {
+		r.probe = &nodeprobe.Options{
+			Namespace:   *probeNamespace,
+			Image:       *probeImage,
+			Timeout:     *probeTimeout,
+			TolerateAll: *probeTolerateAll,
+		}
+	}
 
-	// 3) Build and export the final ReportData
-	finalReport := common.BuildReportData(clusterData, sizingResult, pvResult, connectivityResult, ebpfResult)
+	if fleet == nil {
+		report, err := r.run(context.Background(), *kubeContext, *fromDump)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if err := common.GenerateOutput(report, report.InCluster, outputOpts); err != nil {
+			log.Fatal(err)
+		}
 
-	// If NOT using --active-checks, add a note to the HTML to clarify
-	finalReport.InCluster = inCluster
+		// Map the check verdicts to the process exit code
+		exitCode, violations := policy.Evaluate(report.CheckResults)
+		common.PrintPolicyViolations(violations)
+		os.Exit(exitCode)
+	}
 
-	common.GenerateOutput(finalReport, inCluster)
+	// Multi-cluster run: one report directory per cluster, then a summary.
+	// A cluster that cannot be reached does not stop the others.
+	entries := make([]common.FleetEntry, 0, len(fleet))
+	usedDirs := map[string]bool{}
+	for _, t := range fleet {
+		log.Printf("Checking cluster %s", t.name)
+		entry := common.FleetEntry{Cluster: t.name}
+		report, err := r.run(context.Background(), t.kubeContext, t.dump)
+		if err != nil {
+			log.Printf("Cluster %s: %v", t.name, err)
+			entry.Error = err.Error()
+			entry.ExitCode = common.ExitRuntimeError
+			entries = append(entries, entry)
+			continue
+		}
+		// Dumps of an earlier multi-cluster run know their context
+		if report.ClusterName != "" {
+			entry.Cluster = report.ClusterName
+		} else {
+			report.ClusterName = t.name
+		}
+		entry.OutputDir = uniqueDir(filepath.Join(outputOpts.Dir, common.SafeFileName(entry.Cluster)), usedDirs)
+
+		opts := outputOpts
+		opts.Dir = entry.OutputDir
+		outputErr := common.GenerateOutput(report, report.InCluster, opts)
+
+		var violations []*common.CheckResult
+		entry.Report = report
+		entry.ExitCode, violations = policy.Evaluate(report.CheckResults)
+		common.PrintPolicyViolations(violations)
+		if outputErr != nil {
+			log.Printf("Cluster %s: %v", entry.Cluster, outputErr)
+			entry.OutputError = outputErr.Error()
+			entry.ExitCode = max(entry.ExitCode, common.ExitRuntimeError)
+		}
+		entries = append(entries, entry)
+	}
+	exitCode := common.FleetExitCode(entries)
+	if err := common.WriteFleetSummary(entries, outputOpts); err != nil {
+		log.Print(err)
+		exitCode = max(exitCode, common.ExitRuntimeError)
+	}
+	common.PrintFleetSummary(entries)
+	os.Exit(exitCode)
 }
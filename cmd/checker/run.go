@@ -0,0 +1,213 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/kubescape/sizing-checker/pkg/checks/sizing"
+	"github.com/kubescape/sizing-checker/pkg/common"
+	"github.com/kubescape/sizing-checker/pkg/nodeprobe"
+)
+
+// runner runs the checks against one cluster at a time.
+type runner struct {
+	checks          []common.Check
+	timeout         time.Duration
+	checkTimeout    time.Duration
+	kube            common.KubeClientOptions // Context is set per run
+	collect         common.CollectOptions
+	reportNamespace string
+	probe           *nodeprobe.Options // nil unless --node-probe is set
+	excludedPools   []string
+	sizingProfile   *common.SizingProfile
+	nodePoolLabel   string
+}
+
+// run runs the preflight checks, collects the cluster data of kubeContext (or
+// loads it from fromDump in offline mode), runs the remaining checks and
+// builds the report.
+func (r runner) run(ctx context.Context, kubeContext, fromDump string) (*common.ReportData, error) {
+	if r.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, r.timeout)
+		defer cancel()
+	}
+	env := &common.CheckEnv{
+		ReportNamespace:   r.reportNamespace,
+		ExcludedNodePools: r.excludedPools,
+		SizingProfile:     r.sizingProfile,
+		NodePoolLabel:     r.nodePoolLabel,
+	}
+	if r.probe != nil {
+		env.NodeProbeNamespace = r.probe.Namespace
+	}
+	var phases []common.PhaseTiming
+	preflightChecks, mainChecks := common.SplitPreflightChecks(r.checks)
+
+	// 1) Run the preflight checks, then collect cluster data, or load it from
+	// a dump in offline mode
+	var preflightResults []*common.CheckResult
+	if fromDump != "" {
+		env.Offline = true
+		preflightResults = common.RunChecks(ctx, preflightChecks, env, r.checkTimeout)
+	} else {
+		kube := r.kube
+		kube.Context = kubeContext
+		var err error
+		env.Clientset, env.InCluster, err = common.BuildKubeClient(kube)
+		if err != nil {
+			return nil, err
+		}
+		start := time.Now()
+		preflightResults = common.RunChecks(ctx, preflightChecks, env, r.checkTimeout)
+		phases = append(phases, common.NewPhaseTiming("preflight", start))
+	}
+
+	start := time.Now()
+	var err error
+	if env.Offline {
+		env.ClusterData, err = common.LoadClusterDataFromDump(fromDump)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		env.ClusterData, err = common.CollectClusterData(ctx, env.Clientset, r.collect)
+		if err != nil {
+			return nil, fmt.Errorf("failed to collect cluster data: %w", err)
+		}
+		if kubeContext != "" {
+			env.ClusterData.ClusterDetails.Name = kubeContext
+		}
+	}
+	phases = append(phases, common.NewPhaseTiming("collection", start))
+
+	if r.probe != nil {
+		start = time.Now()
+		env.ClusterData.NodeProbes = nodeprobe.Run(ctx, env.Clientset, env.ClusterData.Nodes, *r.probe)
+		phases = append(phases, common.NewPhaseTiming("node-probe", start))
+	}
+
+	// 2) Run checks, sizing the node-agent once for the checks that need it
+	env.Sizing = sizing.RunSizingChecker(env.ClusterData, r.sizingProfile, r.nodePoolLabel, r.excludedPools)
+	start = time.Now()
+	results := append(preflightResults, common.RunChecks(ctx, mainChecks, env, r.checkTimeout)...)
+	phases = append(phases, common.NewPhaseTiming("checks", start))
+
+	// 3) Build the final ReportData
+	report := common.BuildReportData(env.ClusterData, results)
+	report.InCluster = env.InCluster
+	report.DumpSource = fromDump
+	report.Phases = phases
+	return report, nil
+}
+
+// fleetTarget is one cluster of a multi-cluster run, reached through a
+// kubeconfig context or loaded from a dump.
+type fleetTarget struct {
+	name        string
+	kubeContext string
+	dump        string
+}
+
+// selectFleet returns the clusters of a multi-cluster run, selected with
+// --contexts, --all-contexts or several --from-dump files, or nil for a
+// single-cluster run.
+func selectFleet(kubeconfig, kubeContext, list string, all bool, fromDump string) ([]fleetTarget, error) {
+	var fleet []fleetTarget
+	if dumps := splitList(fromDump); len(dumps) > 1 {
+		for _, dump := range dumps {
+			fleet = append(fleet, fleetTarget{name: dumpName(dump), dump: dump})
+		}
+		return fleet, nil
+	}
+	contexts, err := selectContexts(kubeconfig, kubeContext, list, all)
+	for _, name := range contexts {
+		fleet = append(fleet, fleetTarget{name: name, kubeContext: name})
+	}
+	return fleet, err
+}
+
+// dumpName names a cluster after its dump file, or after the directory of a
+// dump that kept the default file name, e.g. "prod" for prod/full-cluster-dump.yaml.
+func dumpName(path string) string {
+	name := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	if name == "full-cluster-dump" {
+		if dir := filepath.Base(filepath.Dir(path)); dir != "." && dir != string(filepath.Separator) {
+			return dir
+		}
+	}
+	return name
+}
+
+// uniqueDir returns dir, or dir with a numeric suffix if it is already used.
+func uniqueDir(dir string, used map[string]bool) string {
+	unique := dir
+	for i := 2; used[unique]; i++ {
+		unique = fmt.Sprintf("%s-%d", dir, i)
+	}
+	used[unique] = true
+	return unique
+}
+
+// selectContexts returns the kubeconfig contexts of a multi-cluster run
+// (--contexts or --all-contexts), or nil for a single-cluster run.
+func selectContexts(kubeconfig, kubeContext, list string, all bool) ([]string, error) {
+	if list == "" && !all {
+		return nil, nil
+	}
+	if kubeContext != "" {
+		return nil, fmt.Errorf("--context cannot be combined with --contexts or --all-contexts")
+	}
+	if list != "" && all {
+		return nil, fmt.Errorf("--contexts and --all-contexts are mutually exclusive")
+	}
+
+	available, err := common.KubeContexts(kubeconfig)
+	if err != nil {
+		return nil, err
+	}
+	if all {
+		if len(available) == 0 {
+			return nil, fmt.Errorf("--all-contexts: the kubeconfig defines no contexts")
+		}
+		return available, nil
+	}
+
+	known := map[string]bool{}
+	for _, name := range available {
+		known[name] = true
+	}
+	var selected, unknown []string
+	seen := map[string]bool{}
+	for _, name := range splitList(list) {
+		switch {
+		case seen[name]:
+		case !known[name]:
+			unknown = append(unknown, name)
+		default:
+			selected = append(selected, name)
+		}
+		seen[name] = true
+	}
+	if len(unknown) > 0 {
+		return nil, fmt.Errorf("--contexts refers to unknown kubeconfig context(s): %s", strings.Join(unknown, ", "))
+	}
+	if len(selected) == 0 {
+		return nil, fmt.Errorf("--contexts lists no contexts")
+	}
+	return selected, nil
+}
+
+// splitList splits a comma-separated flag value, dropping empty entries.
+func splitList(s string) []string {
+	var out []string
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			out = append(out, v)
+		}
+	}
+	return out
+}
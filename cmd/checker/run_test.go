@@ -0,0 +1,83 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestSelectContexts(t *testing.T) {
+	kubeconfig := filepath.Join(t.TempDir(), "config")
+	err := os.WriteFile(kubeconfig, []byte(`apiVersion: v1
+kind: Config
+clusters:
+- {name: c, cluster: {server: "https://example.com"}}
+users:
+- {name: u, user: {token: t}}
+contexts:
+- {name: prod, context: {cluster: c, user: u}}
+- {name: dev, context: {cluster: c, user: u}}
+- {name: staging, context: {cluster: c, user: u}}
+`), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name        string
+		kubeContext string
+		list        string
+		all         bool
+		want        []string
+		wantErr     bool
+	}{
+		{name: "single cluster", want: nil},
+		{name: "single context", kubeContext: "dev", want: nil},
+		{name: "list keeps order and drops duplicates", list: "prod, dev,prod", want: []string{"prod", "dev"}},
+		{name: "all contexts sorted", all: true, want: []string{"dev", "prod", "staging"}},
+		{name: "unknown context", list: "prod,qa", wantErr: true},
+		{name: "empty list", list: " , ", wantErr: true},
+		{name: "context with list", kubeContext: "dev", list: "prod", wantErr: true},
+		{name: "list with all", list: "prod", all: true, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := selectContexts(kubeconfig, tt.kubeContext, tt.list, tt.all)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("selectContexts() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("selectContexts() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSelectFleetFromDumps(t *testing.T) {
+	fleet, err := selectFleet("", "", "", false, "dumps/prod/full-cluster-dump.yaml, dumps/dev.yaml,full-cluster-dump.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []fleetTarget{
+		{name: "prod", dump: "dumps/prod/full-cluster-dump.yaml"},
+		{name: "dev", dump: "dumps/dev.yaml"},
+		{name: "full-cluster-dump", dump: "full-cluster-dump.yaml"},
+	}
+	if !reflect.DeepEqual(fleet, want) {
+		t.Errorf("selectFleet() = %+v, want %+v", fleet, want)
+	}
+
+	if fleet, err := selectFleet("", "", "", false, "dump.yaml"); fleet != nil || err != nil {
+		t.Errorf("selectFleet() with one dump = %v, %v, want a single-cluster run", fleet, err)
+	}
+}
+
+func TestUniqueDir(t *testing.T) {
+	used := map[string]bool{}
+	for _, want := range []string{"out/prod", "out/prod-2", "out/prod-3"} {
+		if got := uniqueDir("out/prod", used); got != want {
+			t.Errorf("uniqueDir() = %q, want %q", got, want)
+		}
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"net"
 	"os"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/kubescape/sizing-checker/pkg/common"
@@ -14,13 +15,32 @@ import (
 	"k8s.io/client-go/kubernetes"
 )
 
-func RunConnectivityChecks(ctx context.Context, clientset *kubernetes.Clientset, clusterData *common.ClusterData, inCluster bool) *common.ConnectivityResult {
+// Reason codes reported by the connectivity check.
+const (
+	ReasonNotInCluster        = "NotInCluster"
+	ReasonOffline             = "Offline"
+	ReasonPartialConnectivity = "PartialConnectivity"
+	ReasonNoConnectivity      = "NoConnectivity"
+	ReasonConnectFailed       = "ConnectFailed"
+)
+
+const (
+	// dialTimeout bounds each connection attempt.
+	dialTimeout = 5 * time.Second
+	// dialConcurrency is the number of targets dialed in parallel.
+	dialConcurrency = 8
+)
+
+const remediationConnectivity = "Allow egress on port 443 from the cluster to the listed endpoints " +
+	"(directly or through a proxy) so Kubescape can reach its backend and registries."
+
+func RunConnectivityChecks(ctx context.Context, clientset kubernetes.Interface, clusterData *common.ClusterData, inCluster bool) *common.CheckResult {
 	// If not running in-cluster, skip this check entirely.
 	if !inCluster {
-		return &common.ConnectivityResult{
-			AddressesTested: nil,
-			SuccessCount:    0,
-			ResultMessage:   "Skipped",
+		return &common.CheckResult{
+			Status:  common.StatusSkip,
+			Reason:  ReasonNotInCluster,
+			Message: "Skipped",
 		}
 	}
 
@@ -40,31 +60,80 @@ func RunConnectivityChecks(ctx context.Context, clientset *kubernetes.Clientset,
 		targets = connectivitytargets.GetDefaultTargets()
 	}
 
-	// Perform connectivity checks (TCP dial on port 443)
+	// Perform connectivity checks (TCP dial on port 443), a few targets at a time
+	findings := make([]common.Finding, len(targets))
+	dialer := &net.Dialer{Timeout: dialTimeout}
+	sem := make(chan struct{}, dialConcurrency)
+	var wg sync.WaitGroup
+	for i, addr := range targets {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			findings[i] = dialTarget(ctx, dialer, addr)
+		}()
+	}
+	wg.Wait()
+
 	successCount := 0
-	timeout := 5 * time.Second
-	for _, addr := range targets {
-		targetHostPort := fmt.Sprintf("%s:443", addr)
-		conn, err := net.DialTimeout("tcp", targetHostPort, timeout)
-		if err != nil {
-			log.Printf("Failed to connect to %s: %v", targetHostPort, err)
-			continue
+	for _, f := range findings {
+		if f.Status == common.StatusPass {
+			successCount++
 		}
-		_ = conn.Close() // close as soon as we succeed
-		successCount++
 	}
 
-	// Determine final message
-	resultMsg := "Failed"
-	if successCount == len(targets) {
-		resultMsg = "Passed"
-	} else if successCount > 0 && successCount < len(targets) {
-		resultMsg = fmt.Sprintf("Partial success (%d/%d)", successCount, len(targets))
+	// Determine final verdict
+	result := &common.CheckResult{Findings: findings}
+	switch {
+	case successCount == len(targets):
+		result.Status = common.StatusPass
+	case successCount > 0:
+		result.Status = common.StatusWarn
+		result.Reason = ReasonPartialConnectivity
+		result.Message = fmt.Sprintf("Partial success (%d/%d)", successCount, len(targets))
+		result.Remediation = remediationConnectivity
+	default:
+		result.Status = common.StatusFail
+		result.Reason = ReasonNoConnectivity
+		result.Message = "Failed"
+		result.Remediation = remediationConnectivity
 	}
+	return result
+}
+
+// dialTarget opens and closes a TCP connection to addr:443.
+func dialTarget(ctx context.Context, dialer *net.Dialer, addr string) common.Finding {
+	targetHostPort := fmt.Sprintf("%s:443", addr)
+	conn, err := dialer.DialContext(ctx, "tcp", targetHostPort)
+	if err != nil {
+		log.Printf("Failed to connect to %s: %v", targetHostPort, err)
+		return common.Finding{
+			Kind:    "Endpoint",
+			Name:    targetHostPort,
+			Status:  common.StatusFail,
+			Reason:  ReasonConnectFailed,
+			Message: err.Error(),
+		}
+	}
+	_ = conn.Close() // close as soon as we succeed
+	return common.Finding{
+		Kind:    "Endpoint",
+		Name:    targetHostPort,
+		Status:  common.StatusPass,
+		Message: "Reachable",
+	}
+}
+
+// Check exposes the connectivity check through the common.Check interface.
+type Check struct{}
+
+func (Check) Name() string        { return common.ConnectivityCheckName }
+func (Check) Description() string { return "Connectivity Check" }
 
-	return &common.ConnectivityResult{
-		AddressesTested: targets,
-		SuccessCount:    successCount,
-		ResultMessage:   resultMsg,
+func (Check) Run(ctx context.Context, env *common.CheckEnv) *common.CheckResult {
+	if env.Offline {
+		return &common.CheckResult{Status: common.StatusSkip, Reason: ReasonOffline, Message: "Skipped"}
 	}
+	return RunConnectivityChecks(ctx, env.Clientset, env.ClusterData, env.InCluster)
 }
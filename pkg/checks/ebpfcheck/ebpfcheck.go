@@ -4,78 +4,143 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"os"
-	"regexp"
 	"strings"
 
 	"k8s.io/client-go/kubernetes"
 
 	"github.com/kubescape/sizing-checker/pkg/common"
+	"github.com/kubescape/sizing-checker/pkg/nodeprobe"
 )
 
-func RunEbpfCheck(ctx context.Context, clientset *kubernetes.Clientset, clusterData *common.ClusterData, inCluster bool) *common.EbpfResult {
-	ebpfRes := &common.EbpfResult{ResultMessage: "Passed"} // default
+// Reason codes reported by the eBPF check.
+const (
+	ReasonKernelTooOld           = "KernelTooOld"
+	ReasonKernelVersionUnknown   = "KernelVersionUnknown"
+	ReasonKernelConfigUnreadable = "KernelConfigUnreadable"
+	ReasonMissingKernelFlags     = "MissingKernelFlags"
+	ReasonBTFNotDetected         = "BTFNotDetected"
+	ReasonNodesUnavailable       = "NodesUnavailable"
+	ReasonNodesNotProbed         = "NodesNotProbed"
+	ReasonBPFSyscallUnavailable  = "BPFSyscallUnavailable"
+	ReasonFeaturesDegraded       = "RuntimeFeaturesDegraded"
+)
+
+// requiredConfigFlags are the kernel config options the node-agent cannot run
+// without: the flags of the common.KernelFeatures that fail the check.
+var requiredConfigFlags = func() []string {
+	var flags []string
+	for _, f := range common.KernelFeatures {
+		if f.Impact == common.StatusFail {
+			flags = append(flags, f.Flags...)
+		}
+	}
+	return flags
+}()
+
+func RunEbpfCheck(ctx context.Context, clientset kubernetes.Interface, clusterData *common.ClusterData, inCluster bool) *common.CheckResult {
+	ebpfRes := &common.CheckResult{Status: common.StatusPass} // default
+
+	// Without the node list the kernel versions of the nodes are unknown
+	if missing := clusterData.MissingResources(common.ResourceNodes); len(missing) > 0 {
+		ebpfRes.Escalate(common.StatusWarn, ReasonNodesUnavailable,
+			fmt.Sprintf("Node kernel versions unknown: could not collect %s", common.DescribeMissing(missing)),
+			"Grant the checker list access to nodes (see the ClusterRole in k8s-manifest.yaml).")
+	}
 
 	// 1) Always check if any node has a kernel <4.4
 	//    We'll gather all kernel versions from clusterData, parse them, track if any is <4.4
 	var olderKernelNodes []string
 	for _, node := range clusterData.Nodes {
 		kernelVer := node.Status.NodeInfo.KernelVersion
-		major, minor, _, err := parseKernelVersion(kernelVer)
+		major, minor, _, err := common.ParseKernelVersion(kernelVer)
 		if err != nil {
 			// If we cannot parse, we just continue or log a note. Let's continue safely.
 			continue
 		}
 		if major < 4 || (major == 4 && minor < 4) {
 			olderKernelNodes = append(olderKernelNodes, fmt.Sprintf("%s (v=%s)", node.Name, kernelVer))
+			ebpfRes.Findings = append(ebpfRes.Findings, common.Finding{
+				Kind:    "Node",
+				Name:    node.Name,
+				Status:  common.StatusWarn,
+				Reason:  ReasonKernelTooOld,
+				Message: fmt.Sprintf("kernel %s is older than 4.4", kernelVer),
+			})
 		}
 	}
 
 	// If there are nodes older than 4.4, set a WARNING
 	if len(olderKernelNodes) > 0 {
-		ebpfRes.ResultMessage = fmt.Sprintf("Warning: Some nodes have kernel <4.4 => %v", olderKernelNodes)
+		ebpfRes.Escalate(common.StatusWarn, ReasonKernelTooOld,
+			fmt.Sprintf("Some nodes have kernel <4.4 => %v", olderKernelNodes),
+			"The node-agent requires kernel 4.4 or newer; upgrade these nodes or exclude them from the node-agent DaemonSet.")
 		// We continue with further checks, but we keep track that at least some nodes might be missing full eBPF
 	}
 
-	// 2) If we're NOT inCluster => We skip local file checks and just return
+	// 2) Report which runtime detection features each node supports
+	features := common.EvaluateNodeFeatures(clusterData.Nodes, clusterData.NodeProbes)
+	ebpfRes.Details = features
+	evaluateNodeFeatures(ebpfRes, features)
+
+	// 3) With node probe results, evaluate every node instead of the local one
+	if len(clusterData.NodeProbes) > 0 {
+		evaluateNodeProbes(ebpfRes, clusterData.NodeProbes)
+		return ebpfRes
+	}
+
+	// 4) If we're NOT inCluster => We skip local file checks and just return
 	if !inCluster {
 		// We do not read local /boot/config or /sys/kernel/btf, because we're external
 		return ebpfRes
 	}
 
-	// 3) If inCluster => attempt local checks. We need to find the kernel version
+	// 5) If inCluster => attempt local checks. We need to find the kernel version
 	//    for "this" node. If clusterData has exactly 1 node or if we can identify
 	//    the node name in the environment, you can do a more precise match.
 	localKernelVersion, err := findLocalKernelVersion(clusterData)
 	if err != nil {
 		// Fallback: read from /proc/sys/kernel/osrelease
-		localKernelVersion, err = getLocalKernelVersion()
+		localKernelVersion, err = nodeprobe.KernelRelease()
 		if err != nil {
 			// If we still cannot determine local kernel, we cannot do local checks
-			ebpfRes.ResultMessage = combineMessages(ebpfRes.ResultMessage,
-				"Skipping local eBPF config checks: cannot determine local kernel version")
+			ebpfRes.Escalate(common.StatusWarn, ReasonKernelVersionUnknown,
+				"Skipping local eBPF config checks: cannot determine local kernel version", "")
 			return ebpfRes
 		}
 	}
 
-	configPath := "/boot/config-" + localKernelVersion
-	configData, err := os.ReadFile(configPath)
+	configData, configSource, err := nodeprobe.ReadKernelConfig("", localKernelVersion)
 	if err != nil {
-		ebpfRes.ResultMessage = combineMessages(ebpfRes.ResultMessage,
-			fmt.Sprintf("Could not read kernel config at '%s'", configPath))
+		ebpfRes.Escalate(common.StatusWarn, ReasonKernelConfigUnreadable, capitalize(err.Error()),
+			"Mount the host /boot, /lib/modules and /usr/src directories into the checker Pod (see k8s-manifest.yaml), or use --node-probe.")
 	} else {
-		if err := checkEBPFConfigFlags(string(configData)); err != nil {
-			ebpfRes.ResultMessage = combineMessages(ebpfRes.ResultMessage, err.Error())
+		if err := checkEBPFConfigFlags(configData); err != nil {
+			ebpfRes.Escalate(common.StatusFail, ReasonMissingKernelFlags, fmt.Sprintf("%v (kernel config: %s)", err, configSource),
+				"Use a node image whose kernel is built with eBPF support (CONFIG_BPF and CONFIG_BPF_SYSCALL).")
 		}
 	}
 
-	if err := checkBTFSupport(localKernelVersion, configData); err != nil {
-		ebpfRes.ResultMessage = combineMessages(ebpfRes.ResultMessage, err.Error())
+	if !nodeprobe.BTFAvailable("", localKernelVersion, configData) {
+		ebpfRes.Escalate(common.StatusWarn, ReasonBTFNotDetected, "BTF support not detected on local node",
+			"Use a node image whose kernel exposes BTF (CONFIG_DEBUG_INFO_BTF=y).")
+	}
+	if ebpfRes.Message == "" && configSource != "" {
+		ebpfRes.Message = "eBPF kernel config flags set on local node (kernel config: " + configSource + ")"
 	}
 
 	return ebpfRes
 }
 
+// Check exposes the eBPF check through the common.Check interface.
+type Check struct{}
+
+func (Check) Name() string        { return common.EbpfCheckName }
+func (Check) Description() string { return "eBPF Check" }
+
+func (Check) Run(ctx context.Context, env *common.CheckEnv) *common.CheckResult {
+	return RunEbpfCheck(ctx, env.Clientset, env.ClusterData, env.InCluster)
+}
+
 func findLocalKernelVersion(clusterData *common.ClusterData) (string, error) {
 	if len(clusterData.Nodes) == 1 {
 		return clusterData.Nodes[0].Status.NodeInfo.KernelVersion, nil
@@ -83,45 +148,13 @@ func findLocalKernelVersion(clusterData *common.ClusterData) (string, error) {
 	return "", errors.New("could not uniquely identify local node kernel version from clusterData")
 }
 
-// getLocalKernelVersion reads the kernel version from /proc/sys/kernel/osrelease.
-func getLocalKernelVersion() (string, error) {
-	data, err := os.ReadFile("/proc/sys/kernel/osrelease")
-	if err != nil {
-		return "", fmt.Errorf("cannot read /proc/sys/kernel/osrelease: %w", err)
-	}
-	version := strings.TrimSpace(string(data))
-	if version == "" {
-		return "", errors.New("local kernel version is empty")
-	}
-	return version, nil
-}
-
-// parseKernelVersion extracts major, minor, patch from a string like "5.4.0-104-generic".
-func parseKernelVersion(versionStr string) (uint, uint, uint, error) {
-	re := regexp.MustCompile(`^(\d+)\.(\d+)(?:\.(\d+))?`)
-	matches := re.FindStringSubmatch(versionStr)
-	if len(matches) < 3 {
-		return 0, 0, 0, fmt.Errorf("invalid kernel version format: %s", versionStr)
-	}
-	var major, minor, patch uint
-	if _, err := fmt.Sscanf(matches[0], "%d.%d.%d", &major, &minor, &patch); err != nil {
-		// If there's no patch part, attempt parsing just %d.%d
-		if _, err2 := fmt.Sscanf(matches[0], "%d.%d", &major, &minor); err2 != nil {
-			return 0, 0, 0, fmt.Errorf("unable to parse kernel version %q: %v", matches[0], err)
-		}
-	}
-	return major, minor, patch, nil
-}
-
-// checkEBPFConfigFlags ensures the essential flags are present: CONFIG_BPF=y, CONFIG_BPF_SYSCALL=y,
-// and optionally checks CONFIG_DEBUG_INFO_BTF=y (though BTF is also checked separately).
+// checkEBPFConfigFlags ensures the essential flags are present: CONFIG_BPF=y and CONFIG_BPF_SYSCALL=y.
 func checkEBPFConfigFlags(configContent string) error {
-	requiredFlags := []string{"CONFIG_BPF=y", "CONFIG_BPF_SYSCALL=y"}
+	values := nodeprobe.ParseKernelConfig(configContent, requiredConfigFlags)
 	var missing []string
-	lines := strings.Split(configContent, "\n")
-	for _, f := range requiredFlags {
-		if !stringSliceContains(lines, f) {
-			missing = append(missing, f)
+	for _, f := range requiredConfigFlags {
+		if values[f] != "y" {
+			missing = append(missing, f+"=y")
 		}
 	}
 	if len(missing) > 0 {
@@ -130,62 +163,11 @@ func checkEBPFConfigFlags(configContent string) error {
 	return nil
 }
 
-// checkBTFSupport checks for /sys/kernel/btf/vmlinux or well-known fallback paths
-// or "CONFIG_DEBUG_INFO_BTF=y" in the kernel config.
-func checkBTFSupport(kernelRelease string, configData []byte) error {
-	// 1) If /sys/kernel/btf/vmlinux exists => OK
-	if fileExists("/sys/kernel/btf/vmlinux") {
-		return nil
-	}
-	// 2) Check fallback paths
-	fallbacks := []string{
-		"/boot/vmlinux-" + kernelRelease,
-		"/lib/modules/" + kernelRelease + "/vmlinux",
-	}
-	for _, p := range fallbacks {
-		if fileExists(p) {
-			return nil
-		}
-	}
-	// 3) If config was readable, see if it has CONFIG_DEBUG_INFO_BTF=y
-	if len(configData) > 0 {
-		lines := strings.Split(string(configData), "\n")
-		if stringSliceContains(lines, "CONFIG_DEBUG_INFO_BTF=y") {
-			return nil
-		}
-	}
-	return errors.New("BTF support not detected on local node")
-}
-
-// -------------------------------------------------------------------------
-// Utility helpers
-// -------------------------------------------------------------------------
-
-func fileExists(path string) bool {
-	info, err := os.Stat(path)
-	if err != nil {
-		return false
-	}
-	return !info.IsDir()
-}
-
-func stringSliceContains(lines []string, candidate string) bool {
-	for _, l := range lines {
-		if strings.TrimSpace(l) == candidate {
-			return true
-		}
-	}
-	return false
-}
-
-// combineMessages merges an existing message with new info, typically used to collect warnings.
-func combineMessages(existing, newPart string) string {
-	if existing == "" {
-		return newPart
-	}
-	if existing == "Passed" {
-		// Overwrite the default "Passed" if there's new info
-		return newPart
+// capitalize upper-cases the first letter of an error message used as a
+// check message.
+func capitalize(s string) string {
+	if s == "" {
+		return s
 	}
-	return existing + " | " + newPart
+	return strings.ToUpper(s[:1]) + s[1:]
 }
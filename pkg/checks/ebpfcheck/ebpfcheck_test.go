@@ -0,0 +1,95 @@
+package ebpfcheck
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/kubescape/sizing-checker/pkg/common"
+	"github.com/kubescape/sizing-checker/pkg/testutil"
+)
+
+func TestRunEbpfCheckOldKernels(t *testing.T) {
+	cd := &common.ClusterData{Nodes: []corev1.Node{
+		*testutil.Node("modern", "4", "16Gi"),
+		*testutil.Node("legacy", "4", "16Gi", testutil.WithKernel("3.10.0-1160.el7.x86_64")),
+		*testutil.Node("unparsable", "4", "16Gi", testutil.WithKernel("custom")),
+	}}
+
+	res := RunEbpfCheck(context.Background(), testutil.NewClientset(), cd, false)
+	if res.Status != common.StatusWarn || res.Reason != ReasonKernelTooOld {
+		t.Fatalf("RunEbpfCheck() = %s/%q, want Warn/%q", res.Status, res.Reason, ReasonKernelTooOld)
+	}
+	if len(res.Findings) != 1 || res.Findings[0].Name != "legacy" {
+		t.Errorf("findings = %+v, want a single finding for node legacy", res.Findings)
+	}
+}
+
+func TestRunEbpfCheckModernKernels(t *testing.T) {
+	cd := &common.ClusterData{Nodes: []corev1.Node{*testutil.Node("n1", "4", "16Gi"), *testutil.Node("n2", "4", "16Gi")}}
+	res := RunEbpfCheck(context.Background(), testutil.NewClientset(), cd, false)
+	if res.Status != common.StatusPass || len(res.Findings) != 0 {
+		t.Errorf("RunEbpfCheck() = %s with %d findings, want Pass without findings", res.Status, len(res.Findings))
+	}
+}
+
+func TestRunEbpfCheckNodeProbes(t *testing.T) {
+	ok := func(node, pool string) common.NodeProbe {
+		return common.NodeProbe{Node: node, Pool: pool, Status: common.NodeProbeOK, KernelRelease: "6.1.0",
+			KernelConfigSource: "/boot/config-6.1.0", KernelConfig: map[string]string{"CONFIG_BPF": "y", "CONFIG_BPF_SYSCALL": "y"},
+			BTF: true, BPFSyscall: common.BPFSyscallAvailable}
+	}
+
+	// Windows nodes are not probed, and reported by the node platform check
+	windows := common.NodeProbe{Node: "win", Pool: "win", Status: common.NodeProbeUnsupportedOS, Message: "windows node"}
+	cd := &common.ClusterData{NodeProbes: []common.NodeProbe{ok("n1", "general"), ok("n2", "general"), windows}}
+	res := RunEbpfCheck(context.Background(), testutil.NewClientset(), cd, false)
+	if res.Status != common.StatusPass || res.Message != "eBPF available on all 2 probed nodes" || len(res.Findings) != 0 {
+		t.Errorf("RunEbpfCheck() = %s %q with %d findings, want Pass on all probed nodes", res.Status, res.Message, len(res.Findings))
+	}
+
+	noSyscall := ok("legacy", "old")
+	noSyscall.KernelConfig = map[string]string{"CONFIG_BPF": "y"}
+	noSyscall.BPFSyscall = common.BPFSyscallNotImplemented
+	cd.NodeProbes = append(cd.NodeProbes, noSyscall,
+		common.NodeProbe{Node: "gpu", Pool: "gpu", Status: common.NodeProbeTainted, Message: "not probed"})
+	res = RunEbpfCheck(context.Background(), testutil.NewClientset(), cd, false)
+	if res.Status != common.StatusFail || res.Reason != ReasonBPFSyscallUnavailable {
+		t.Fatalf("RunEbpfCheck() = %s/%q, want Fail/%q", res.Status, res.Reason, ReasonBPFSyscallUnavailable)
+	}
+	reasons := map[string]string{}
+	for _, f := range res.Findings {
+		reasons[f.Name+"/"+f.Reason] = f.Message
+	}
+	for _, want := range []string{"legacy/" + ReasonMissingKernelFlags, "legacy/" + ReasonBPFSyscallUnavailable, "gpu/" + ReasonNodesNotProbed} {
+		if _, found := reasons[want]; !found {
+			t.Errorf("missing finding %s in %v", want, reasons)
+		}
+	}
+	if !strings.Contains(res.Message, "1 of 4 nodes lack the eBPF kernel config flags (pools: old (1))") {
+		t.Errorf("message = %q, want the affected pools", res.Message)
+	}
+}
+
+func TestRunEbpfCheckDegradedFeatures(t *testing.T) {
+	cd := &common.ClusterData{Nodes: []corev1.Node{
+		*testutil.Node("n1", "4", "16Gi"),
+		*testutil.Node("focal", "4", "16Gi", testutil.WithKernel("5.4.0-104-generic"),
+			testutil.WithLabels(map[string]string{"cloud.google.com/gke-nodepool": "legacy"})),
+	}}
+	res := RunEbpfCheck(context.Background(), testutil.NewClientset(), cd, false)
+	if res.Status != common.StatusWarn || res.Reason != ReasonFeaturesDegraded {
+		t.Fatalf("RunEbpfCheck() = %s/%q, want Warn/%q", res.Status, res.Reason, ReasonFeaturesDegraded)
+	}
+	if want := "ring buffer on 1 of 2 nodes (pools: legacy (1))"; !strings.Contains(res.Message, want) {
+		t.Errorf("message = %q, want it to contain %q", res.Message, want)
+	}
+	if len(res.Findings) != 1 || res.Findings[0].Name != "focal" {
+		t.Errorf("findings = %+v, want a single finding for node focal", res.Findings)
+	}
+	if features, ok := res.Details.([]common.NodeFeatures); !ok || len(features) != 2 {
+		t.Errorf("Details = %#v, want the features of both nodes", res.Details)
+	}
+}
@@ -0,0 +1,75 @@
+package ebpfcheck
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/kubescape/sizing-checker/pkg/common"
+)
+
+// evaluateNodeFeatures reports the nodes lacking runtime detection features
+// whose Impact is Warn. Features that fail the check are reported through the
+// kernel version and config flag reasons instead, and informational ones only
+// appear in the feature matrix. Nodes the node-agent cannot run on at all,
+// and kernels already reported as too old, are left out.
+func evaluateNodeFeatures(res *common.CheckResult, nodes []common.NodeFeatures) {
+	degraded := map[string]*nodeIssue{}
+	for _, nf := range nodes {
+		if !agentRuns(nf) {
+			continue
+		}
+		var lost []string
+		for i, v := range nf.Features {
+			f := common.KernelFeatures[i]
+			if v.Support != common.FeatureUnsupported || f.Impact != common.StatusWarn {
+				continue
+			}
+			lost = append(lost, fmt.Sprintf("%s (%s)", f.Name, v.Reason))
+			issue := degraded[f.Name]
+			if issue == nil {
+				issue = &nodeIssue{}
+				degraded[f.Name] = issue
+			}
+			issue.nodes = append(issue.nodes, nf.Node)
+			issue.pools = append(issue.pools, nf.Pool)
+		}
+		if len(lost) > 0 {
+			res.Findings = append(res.Findings, common.Finding{
+				Kind:    "Node",
+				Name:    nf.Node,
+				Status:  common.StatusWarn,
+				Reason:  ReasonFeaturesDegraded,
+				Message: "runtime detection features unavailable: " + strings.Join(lost, ", "),
+			})
+		}
+	}
+	if len(degraded) == 0 {
+		return
+	}
+
+	// Summarize in table order
+	var parts []string
+	for _, f := range common.KernelFeatures {
+		if issue := degraded[f.Name]; issue != nil {
+			parts = append(parts, fmt.Sprintf("%s on %d of %d nodes (pools: %s)",
+				f.Name, len(issue.nodes), len(nodes), common.DescribePools(issue.pools)))
+		}
+	}
+	res.Escalate(common.StatusWarn, ReasonFeaturesDegraded,
+		"Runtime detection degraded, features unavailable: "+strings.Join(parts, "; "),
+		"Use node images with a newer kernel built with the missing options; the runtime detection feature matrix of the report lists what each feature affects.")
+}
+
+// agentRuns reports whether none of the features the node-agent requires is
+// known to be missing on the node and its kernel is not older than 4.4.
+func agentRuns(nf common.NodeFeatures) bool {
+	if ok, err := common.VersionAtLeast(nf.KernelRelease, "4.4"); err == nil && !ok {
+		return false
+	}
+	for i, v := range nf.Features {
+		if v.Support == common.FeatureUnsupported && common.KernelFeatures[i].Impact == common.StatusFail {
+			return false
+		}
+	}
+	return true
+}
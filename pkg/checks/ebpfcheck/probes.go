@@ -0,0 +1,110 @@
+package ebpfcheck
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/kubescape/sizing-checker/pkg/common"
+)
+
+// nodeIssue collects the nodes sharing one problem, to report it once with
+// the affected pools.
+type nodeIssue struct {
+	status      common.CheckStatus
+	summary     string // e.g. "missing eBPF kernel config flags"
+	remediation string
+	nodes       []string
+	pools       []string
+}
+
+// evaluateNodeProbes turns the node probe results into one finding per node
+// problem, and escalates res once per problem. Non-Linux nodes are left to
+// the node platform check.
+func evaluateNodeProbes(res *common.CheckResult, probes []common.NodeProbe) {
+	issues := map[string]*nodeIssue{
+		ReasonNodesNotProbed: {status: common.StatusWarn, summary: "could not be probed",
+			remediation: "Use --probe-tolerate-all to probe tainted nodes; for timeouts, inspect the kubescape-node-probe Pods."},
+		ReasonKernelConfigUnreadable: {status: common.StatusWarn, summary: "have no readable kernel config",
+			remediation: "Use a node image that ships its kernel config under /boot, /lib/modules or /usr/src, or exposes /proc/config.gz."},
+		ReasonMissingKernelFlags: {status: common.StatusFail, summary: "lack the eBPF kernel config flags",
+			remediation: "Use a node image whose kernel is built with eBPF support (CONFIG_BPF and CONFIG_BPF_SYSCALL)."},
+		ReasonBPFSyscallUnavailable: {status: common.StatusFail, summary: "cannot use the bpf() syscall",
+			remediation: "Use a node image without kernel lockdown or seccomp rules blocking bpf() for privileged Pods."},
+		ReasonBTFNotDetected: {status: common.StatusWarn, summary: "do not expose BTF",
+			remediation: "Use a node image whose kernel exposes BTF (CONFIG_DEBUG_INFO_BTF=y)."},
+	}
+	add := func(reason string, p common.NodeProbe, message string) {
+		issue := issues[reason]
+		issue.nodes = append(issue.nodes, p.Node)
+		issue.pools = append(issue.pools, p.Pool)
+		res.Findings = append(res.Findings, common.Finding{
+			Kind:    "Node",
+			Name:    p.Node,
+			Status:  issue.status,
+			Reason:  reason,
+			Message: message,
+		})
+	}
+
+	linux := 0
+	for _, p := range probes {
+		if p.Status == common.NodeProbeUnsupportedOS {
+			continue
+		}
+		linux++
+		if p.Status != common.NodeProbeOK {
+			add(ReasonNodesNotProbed, p, fmt.Sprintf("%s: %s", p.Status, p.Message))
+			continue
+		}
+		if p.KernelConfigSource == "" {
+			add(ReasonKernelConfigUnreadable, p, p.Message)
+		} else if missing := missingFlags(p); len(missing) > 0 {
+			add(ReasonMissingKernelFlags, p, "missing "+strings.Join(missing, ", "))
+		}
+		switch p.BPFSyscall {
+		case common.BPFSyscallNotImplemented:
+			add(ReasonBPFSyscallUnavailable, p, "the kernel does not implement bpf()")
+		case common.BPFSyscallDenied:
+			add(ReasonBPFSyscallUnavailable, p, "bpf() is denied to privileged Pods")
+		}
+		if !p.BTF {
+			add(ReasonBTFNotDetected, p, "BTF support not detected")
+		}
+	}
+
+	// Escalate the most severe problems first, so they set the message
+	reasons := make([]string, 0, len(issues))
+	for reason, issue := range issues {
+		if len(issue.nodes) > 0 {
+			reasons = append(reasons, reason)
+		}
+	}
+	sort.Slice(reasons, func(i, j int) bool {
+		a, b := issues[reasons[i]], issues[reasons[j]]
+		if a.status.Severity() != b.status.Severity() {
+			return a.status.Severity() > b.status.Severity()
+		}
+		return reasons[i] < reasons[j]
+	})
+	for _, reason := range reasons {
+		issue := issues[reason]
+		res.Escalate(issue.status, reason,
+			fmt.Sprintf("%d of %d nodes %s (pools: %s)", len(issue.nodes), linux, issue.summary, common.DescribePools(issue.pools)),
+			issue.remediation)
+	}
+	if len(reasons) == 0 && res.Status == common.StatusPass {
+		res.Message = fmt.Sprintf("eBPF available on all %d probed nodes", linux)
+	}
+}
+
+// missingFlags returns the required kernel config flags a probed node lacks.
+func missingFlags(p common.NodeProbe) []string {
+	var missing []string
+	for _, f := range requiredConfigFlags {
+		if p.ConfigValue(f) != "y" {
+			missing = append(missing, f+"=y")
+		}
+	}
+	return missing
+}
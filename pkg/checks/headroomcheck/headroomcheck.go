@@ -0,0 +1,242 @@
+package headroomcheck
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+
+	"github.com/kubescape/sizing-checker/pkg/common"
+)
+
+// Reason codes reported by the headroom check.
+const (
+	ReasonInsufficientHeadroom = "InsufficientHeadroom"
+	ReasonNoNodeFits           = "NoNodeFits"
+	ReasonAllocatableUnknown   = "AllocatableUnknown"
+	ReasonInputsUnavailable    = "InputsUnavailable"
+)
+
+// nodeAgentLabels identify the Pods of an existing node-agent, whose requests
+// the recommended node-agent replaces rather than adds to.
+var nodeAgentLabels = []string{"app.kubernetes.io/name", "app"}
+
+// RunHeadroomCheck compares the free allocatable resources of every node the
+// node-agent runs on (its allocatable resources minus the requests of the
+// Pods running on it) with the node-agent requests recommended by the sizing,
+// and reports the nodes the node-agent DaemonSet Pods would stay Pending on.
+// Nodes of an unsupported platform and of the excluded node pools are skipped;
+// nodes that report no allocatable resources are warned about as unknown.
+func RunHeadroomCheck(clusterData *common.ClusterData, sr *common.SizingResult, poolLabel string, excludedPools []string) *common.CheckResult {
+	res := &common.CheckResult{Status: common.StatusPass}
+	if missing := clusterData.MissingResources(common.ResourceNodes, common.ResourcePods); len(missing) > 0 {
+		res.Escalate(common.StatusWarn, ReasonInputsUnavailable,
+			fmt.Sprintf("Node headroom unknown: could not collect %s", common.DescribeMissing(missing)),
+			"Grant the checker list access to nodes and pods (see the ClusterRole in k8s-manifest.yaml).")
+		return res
+	}
+
+	requested := map[string]corev1.ResourceList{}
+	for i := range clusterData.Pods {
+		pod := &clusterData.Pods[i]
+		if pod.Spec.NodeName == "" || pod.Status.Phase == corev1.PodSucceeded ||
+			pod.Status.Phase == corev1.PodFailed || isNodeAgent(pod) {
+			continue
+		}
+		total := requested[pod.Spec.NodeName]
+		if total == nil {
+			total = corev1.ResourceList{}
+			requested[pod.Spec.NodeName] = total
+		}
+		addResources(total, podRequests(pod))
+	}
+
+	excluded := map[string]bool{}
+	for _, p := range excludedPools {
+		excluded[p] = true
+	}
+
+	var short, unknown []string
+	eligible := 0
+	for i := range clusterData.Nodes {
+		node := &clusterData.Nodes[i]
+		if !common.NodeAgentSupported(node) {
+			continue // reported by the node platform check
+		}
+		_, pool := common.NodePoolLabel(node, poolLabel)
+		if pool != "" && excluded[pool] {
+			continue // left without node-agent on purpose
+		}
+		eligible++
+		if len(node.Status.Allocatable) == 0 {
+			unknown = append(unknown, pool)
+			res.Findings = append(res.Findings, common.Finding{
+				Kind:    "Node",
+				Name:    node.Name,
+				Status:  common.StatusWarn,
+				Reason:  ReasonAllocatableUnknown,
+				Message: "the node reports no allocatable resources, so its headroom is unknown",
+			})
+			continue
+		}
+		needCPU, needMem := nodeAgentRequests(node, sr, poolLabel)
+		freeCPU := freeResource(node, requested[node.Name], corev1.ResourceCPU)
+		freeMem := freeResource(node, requested[node.Name], corev1.ResourceMemory)
+
+		var lacking []string
+		if freeCPU.Cmp(needCPU) < 0 {
+			lacking = append(lacking, fmt.Sprintf("CPU: %s free, %s requested", formatCPU(freeCPU), formatCPU(needCPU)))
+		}
+		if freeMem.Cmp(needMem) < 0 {
+			lacking = append(lacking, fmt.Sprintf("memory: %s free, %s requested", formatMemory(freeMem), formatMemory(needMem)))
+		}
+		if len(lacking) == 0 {
+			continue
+		}
+		short = append(short, pool)
+		res.Findings = append(res.Findings, common.Finding{
+			Kind:    "Node",
+			Name:    node.Name,
+			Status:  common.StatusWarn,
+			Reason:  ReasonInsufficientHeadroom,
+			Message: "the node-agent does not fit: " + strings.Join(lacking, "; "),
+		})
+	}
+
+	switch {
+	case eligible == 0:
+		res.Message = "No nodes to run the node-agent on"
+	case len(short) == 0 && len(unknown) == 0:
+		res.Message = fmt.Sprintf("All %d node-agent nodes have room for the recommended node-agent requests", eligible)
+	}
+	if len(short) > 0 {
+		summary := fmt.Sprintf("%d of %d node-agent nodes lack the allocatable headroom for the recommended node-agent requests, so its Pods would stay Pending there (pools: %s)",
+			len(short), eligible, common.DescribePools(short))
+		remediation := "Free up requests on these nodes, give the node-agent a priority class that lets it preempt other Pods, or exclude these node pools from the node-agent."
+		if len(short) == eligible-len(unknown) {
+			res.Escalate(common.StatusFail, ReasonNoNodeFits, summary, remediation)
+		} else {
+			res.Escalate(common.StatusWarn, ReasonInsufficientHeadroom, summary, remediation)
+		}
+	}
+	if len(unknown) > 0 {
+		res.Escalate(common.StatusWarn, ReasonAllocatableUnknown,
+			fmt.Sprintf("%d of %d node-agent nodes report no allocatable resources, so their headroom is unknown (pools: %s)",
+				len(unknown), eligible, common.DescribePools(unknown)),
+			"Check that the kubelet of these nodes is running and has registered its capacity.")
+	}
+	return res
+}
+
+// nodeAgentRequests returns the recommended node-agent CPU and memory
+// requests for a node: those of its pool when the node-agent is deployed per
+// node pool, the cluster-wide ones otherwise.
+func nodeAgentRequests(node *corev1.Node, sr *common.SizingResult, poolLabel string) (resource.Quantity, resource.Quantity) {
+	resources := sr.FinalResourceAllocations["nodeAgent"]
+	if sr.MultipleNodeAgentDaemonSets {
+		label, pool := common.NodePoolLabel(node, poolLabel)
+		for _, p := range sr.NodeAgentPools {
+			if p.Label == label && p.Pool == pool {
+				resources = p.Resources
+				break
+			}
+		}
+	}
+	cpu, _ := resource.ParseQuantity(resources["cpuReq"])
+	mem, _ := resource.ParseQuantity(resources["memReq"])
+	return cpu, mem
+}
+
+// freeResource returns the allocatable amount of a resource of the node that
+// is not requested yet, which may be negative on overcommitted nodes.
+func freeResource(node *corev1.Node, requested corev1.ResourceList, name corev1.ResourceName) resource.Quantity {
+	free := node.Status.Allocatable[name].DeepCopy()
+	if used, ok := requested[name]; ok {
+		free.Sub(used)
+	}
+	return free
+}
+
+// podRequests returns the effective requests of a Pod, as the scheduler
+// computes them: the larger of its containers (with sidecars) and each init
+// container, or the Pod-level requests where set, plus the Pod overhead.
+func podRequests(pod *corev1.Pod) corev1.ResourceList {
+	total := corev1.ResourceList{}
+	for _, c := range pod.Spec.Containers {
+		addResources(total, c.Resources.Requests)
+	}
+	sidecars := corev1.ResourceList{}
+	initMax := corev1.ResourceList{}
+	for _, c := range pod.Spec.InitContainers {
+		if c.RestartPolicy != nil && *c.RestartPolicy == corev1.ContainerRestartPolicyAlways {
+			// sidecars keep running next to the later init and regular containers
+			addResources(sidecars, c.Resources.Requests)
+			maxResources(initMax, sidecars)
+			continue
+		}
+		step := sidecars.DeepCopy()
+		addResources(step, c.Resources.Requests)
+		maxResources(initMax, step)
+	}
+	addResources(total, sidecars)
+	maxResources(total, initMax)
+	if pod.Spec.Resources != nil {
+		// Pod-level requests (PodLevelResources) replace the container sum
+		for name, q := range pod.Spec.Resources.Requests {
+			total[name] = q.DeepCopy()
+		}
+	}
+	addResources(total, pod.Spec.Overhead)
+	return total
+}
+
+func addResources(total, add corev1.ResourceList) {
+	for name, q := range add {
+		sum := total[name]
+		sum.Add(q)
+		total[name] = sum
+	}
+}
+
+func maxResources(total, other corev1.ResourceList) {
+	for name, q := range other {
+		if cur, ok := total[name]; !ok || q.Cmp(cur) > 0 {
+			total[name] = q.DeepCopy()
+		}
+	}
+}
+
+func isNodeAgent(pod *corev1.Pod) bool {
+	for _, label := range nodeAgentLabels {
+		if pod.Labels[label] == "node-agent" {
+			return true
+		}
+	}
+	return false
+}
+
+func formatCPU(q resource.Quantity) string {
+	return fmt.Sprintf("%dm", q.MilliValue())
+}
+
+func formatMemory(q resource.Quantity) string {
+	return fmt.Sprintf("%dMi", q.Value()/(1024*1024))
+}
+
+// Check exposes the headroom check through the common.Check interface.
+type Check struct{}
+
+func (Check) Name() string        { return common.HeadroomCheckName }
+func (Check) Description() string { return "Node Allocatable Headroom Check" }
+
+func (Check) Run(ctx context.Context, env *common.CheckEnv) *common.CheckResult {
+	if env.Sizing == nil {
+		res := &common.CheckResult{Status: common.StatusPass}
+		res.Escalate(common.StatusWarn, ReasonInputsUnavailable,
+			"Node headroom unknown: the node-agent sizing was not computed", "")
+		return res
+	}
+	return RunHeadroomCheck(env.ClusterData, env.Sizing, env.NodePoolLabel, env.ExcludedNodePools)
+}
@@ -0,0 +1,208 @@
+package headroomcheck
+
+import (
+	"context"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+
+	"github.com/kubescape/sizing-checker/pkg/common"
+	"github.com/kubescape/sizing-checker/pkg/testutil"
+)
+
+func TestRunHeadroomCheck(t *testing.T) {
+	sr := &common.SizingResult{FinalResourceAllocations: map[string]map[string]string{
+		"nodeAgent": {"cpuReq": "100m", "memReq": "180Mi"},
+	}}
+	nodeAgent := testutil.Pod("kubescape", "node-agent-abc", "busy", "1500m", "2Gi")
+	nodeAgent.Labels = map[string]string{"app.kubernetes.io/name": "node-agent"}
+	completed := testutil.Pod("default", "job", "busy", "2", "4Gi")
+	completed.Status.Phase = corev1.PodSucceeded
+	notReported := testutil.Node("new", "2", "4Gi")
+	notReported.Status.Allocatable = nil
+
+	tests := []struct {
+		name         string
+		nodes        []corev1.Node
+		pods         []corev1.Pod
+		poolLabel    string
+		excluded     []string
+		wantStatus   common.CheckStatus
+		wantReason   string
+		wantFindings []string
+	}{
+		{
+			name:       "room everywhere",
+			nodes:      []corev1.Node{*testutil.Node("n1", "2", "4Gi"), *testutil.Node("busy", "2", "4Gi")},
+			pods:       []corev1.Pod{*testutil.Pod("default", "app", "busy", "1500m", "3Gi"), *nodeAgent, *completed},
+			wantStatus: common.StatusPass,
+		},
+		{
+			name:         "full node",
+			nodes:        []corev1.Node{*testutil.Node("n1", "2", "4Gi"), *testutil.Node("busy", "2", "4Gi")},
+			pods:         []corev1.Pod{*testutil.Pod("default", "app", "busy", "1950m", "3Gi")},
+			wantStatus:   common.StatusWarn,
+			wantReason:   ReasonInsufficientHeadroom,
+			wantFindings: []string{"busy"},
+		},
+		{
+			name:         "no node fits",
+			nodes:        []corev1.Node{*testutil.Node("busy", "2", "4Gi")},
+			pods:         []corev1.Pod{*testutil.Pod("default", "app", "busy", "", "3950Mi")},
+			wantStatus:   common.StatusFail,
+			wantReason:   ReasonNoNodeFits,
+			wantFindings: []string{"busy"},
+		},
+		{
+			name:       "windows nodes are skipped",
+			nodes:      []corev1.Node{*testutil.Node("n1", "2", "4Gi"), *testutil.Node("win", "1", "1Gi", testutil.WithOS("windows", "amd64"))},
+			pods:       []corev1.Pod{*testutil.Pod("default", "app", "win", "1", "1Gi")},
+			wantStatus: common.StatusPass,
+		},
+		{
+			name:       "unsupported architectures are skipped",
+			nodes:      []corev1.Node{*testutil.Node("n1", "2", "4Gi"), *testutil.Node("z", "1", "1Gi", testutil.WithOS("linux", "s390x"))},
+			pods:       []corev1.Pod{*testutil.Pod("default", "app", "z", "1", "1Gi")},
+			wantStatus: common.StatusPass,
+		},
+		{
+			name: "excluded pools are skipped",
+			nodes: []corev1.Node{*testutil.Node("n1", "2", "4Gi"),
+				*testutil.Node("gpu-1", "2", "4Gi", testutil.WithLabels(map[string]string{"cloud.google.com/gke-nodepool": "gpu"}))},
+			pods:       []corev1.Pod{*testutil.Pod("default", "app", "gpu-1", "2", "4Gi")},
+			excluded:   []string{"gpu"},
+			wantStatus: common.StatusPass,
+		},
+		{
+			name: "excluded pools by custom label are skipped",
+			nodes: []corev1.Node{*testutil.Node("n1", "2", "4Gi"),
+				*testutil.Node("gpu-1", "2", "4Gi", testutil.WithLabels(map[string]string{"cloud.google.com/gke-nodepool": "gpu", "team": "ml"}))},
+			pods:       []corev1.Pod{*testutil.Pod("default", "app", "gpu-1", "2", "4Gi")},
+			poolLabel:  "team",
+			excluded:   []string{"ml"},
+			wantStatus: common.StatusPass,
+		},
+		{
+			name:         "unknown allocatable",
+			nodes:        []corev1.Node{*testutil.Node("n1", "2", "4Gi"), *notReported},
+			wantStatus:   common.StatusWarn,
+			wantReason:   ReasonAllocatableUnknown,
+			wantFindings: []string{"new"},
+		},
+		{
+			name: "other pools are still checked",
+			nodes: []corev1.Node{*testutil.Node("n1", "2", "4Gi"),
+				*testutil.Node("gpu-1", "2", "4Gi", testutil.WithLabels(map[string]string{"cloud.google.com/gke-nodepool": "gpu"}))},
+			pods:         []corev1.Pod{*testutil.Pod("default", "app", "gpu-1", "2", "4Gi")},
+			excluded:     []string{"batch"},
+			wantStatus:   common.StatusWarn,
+			wantReason:   ReasonInsufficientHeadroom,
+			wantFindings: []string{"gpu-1"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := RunHeadroomCheck(&common.ClusterData{Nodes: tt.nodes, Pods: tt.pods}, sr, tt.poolLabel, tt.excluded)
+			if res.Status != tt.wantStatus || res.Reason != tt.wantReason {
+				t.Fatalf("RunHeadroomCheck() = %s/%q, want %s/%q: %s", res.Status, res.Reason, tt.wantStatus, tt.wantReason, res.Message)
+			}
+			if len(res.Findings) != len(tt.wantFindings) {
+				t.Fatalf("findings = %+v, want nodes %v", res.Findings, tt.wantFindings)
+			}
+			for i, f := range res.Findings {
+				if f.Name != tt.wantFindings[i] {
+					t.Errorf("finding %d = %s, want %s", i, f.Name, tt.wantFindings[i])
+				}
+			}
+		})
+	}
+}
+
+func TestRunHeadroomCheckPerPool(t *testing.T) {
+	const label = "eks.amazonaws.com/nodegroup"
+	sr := &common.SizingResult{
+		FinalResourceAllocations: map[string]map[string]string{"nodeAgent": {"cpuReq": "800m", "memReq": "3277Mi"}},
+		NodeAgentPools: []common.NodePoolSizing{
+			{Pool: "small", Label: label, Resources: map[string]string{"cpuReq": "100m", "memReq": "180Mi"}},
+			{Pool: "big", Label: label, Resources: map[string]string{"cpuReq": "800m", "memReq": "3277Mi"}},
+		},
+		MultipleNodeAgentDaemonSets: true,
+	}
+	cd := &common.ClusterData{
+		Nodes: []corev1.Node{
+			*testutil.Node("small-1", "2", "4Gi", testutil.WithLabels(map[string]string{label: "small"})),
+			*testutil.Node("big-1", "32", "128Gi", testutil.WithLabels(map[string]string{label: "big"})),
+		},
+		Pods: []corev1.Pod{*testutil.Pod("default", "app", "small-1", "1500m", "3Gi")},
+	}
+	// small-1 only has to fit the requests of its own pool
+	if res := RunHeadroomCheck(cd, sr, "", nil); res.Status != common.StatusPass {
+		t.Errorf("RunHeadroomCheck() = %s: %s", res.Status, res.Message)
+	}
+}
+
+func TestRunHeadroomCheckPodsUnavailable(t *testing.T) {
+	cd := &common.ClusterData{Collection: &common.CollectionStats{Resources: []common.ResourceCollection{
+		{Kind: common.ResourcePods, Status: common.CollectionForbidden},
+	}}}
+	res := RunHeadroomCheck(cd, &common.SizingResult{}, "", nil)
+	if res.Status != common.StatusWarn || res.Reason != ReasonInputsUnavailable {
+		t.Errorf("RunHeadroomCheck() = %s/%q, want Warn/%q", res.Status, res.Reason, ReasonInputsUnavailable)
+	}
+}
+
+func TestCheckRunUsesEnvSizing(t *testing.T) {
+	cd := &common.ClusterData{Nodes: []corev1.Node{*testutil.Node("n1", "2", "4Gi")}}
+	if res := (Check{}).Run(context.Background(), &common.CheckEnv{ClusterData: cd}); res.Reason != ReasonInputsUnavailable {
+		t.Errorf("Run() without sizing = %s/%q, want Warn/%q", res.Status, res.Reason, ReasonInputsUnavailable)
+	}
+	// The node only has to fit the requests of the sizing passed in
+	env := &common.CheckEnv{ClusterData: cd, Sizing: &common.SizingResult{FinalResourceAllocations: map[string]map[string]string{
+		"nodeAgent": {"cpuReq": "3", "memReq": "180Mi"},
+	}}}
+	if res := (Check{}).Run(context.Background(), env); res.Reason != ReasonNoNodeFits {
+		t.Errorf("Run() = %s/%q, want Fail/%q", res.Status, res.Reason, ReasonNoNodeFits)
+	}
+}
+
+func TestPodRequests(t *testing.T) {
+	always := corev1.ContainerRestartPolicyAlways
+	container := func(cpu string, restart *corev1.ContainerRestartPolicy) corev1.Container {
+		return corev1.Container{
+			RestartPolicy: restart,
+			Resources:     corev1.ResourceRequirements{Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse(cpu)}},
+		}
+	}
+	tests := []struct {
+		name string
+		spec corev1.PodSpec
+		want string
+	}{
+		{"containers", corev1.PodSpec{Containers: []corev1.Container{container("100m", nil), container("200m", nil)}}, "300m"},
+		{"large init container", corev1.PodSpec{
+			InitContainers: []corev1.Container{container("1", nil)},
+			Containers:     []corev1.Container{container("100m", nil)},
+		}, "1"},
+		{"sidecar", corev1.PodSpec{
+			InitContainers: []corev1.Container{container("50m", &always), container("200m", nil)},
+			Containers:     []corev1.Container{container("100m", nil)},
+		}, "250m"},
+		{"overhead", corev1.PodSpec{
+			Containers: []corev1.Container{container("100m", nil)},
+			Overhead:   corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("10m")},
+		}, "110m"},
+		{"pod-level requests", corev1.PodSpec{
+			Resources:      &corev1.ResourceRequirements{Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("500m")}},
+			InitContainers: []corev1.Container{container("1", nil)},
+			Containers:     []corev1.Container{container("100m", nil), container("200m", nil)},
+			Overhead:       corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("10m")},
+		}, "510m"},
+	}
+	for _, tt := range tests {
+		got := podRequests(&corev1.Pod{Spec: tt.spec})[corev1.ResourceCPU]
+		if want := resource.MustParse(tt.want); got.Cmp(want) != 0 {
+			t.Errorf("%s: podRequests() cpu = %s, want %s", tt.name, got.String(), tt.want)
+		}
+	}
+}
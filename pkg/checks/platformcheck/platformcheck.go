@@ -0,0 +1,94 @@
+package platformcheck
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/kubescape/sizing-checker/pkg/common"
+)
+
+// Reason codes reported by the node platform check.
+const (
+	ReasonUnsupportedOS    = "UnsupportedOS"
+	ReasonUnsupportedArch  = "UnsupportedArchitecture"
+	ReasonNoSupportedNodes = "NoSupportedNodes"
+	ReasonNodesUnavailable = "NodesUnavailable"
+)
+
+// RunPlatformCheck finds the nodes the node-agent cannot run on, Windows
+// nodes and nodes of an architecture without node-agent images, and returns
+// the scheduling constraints that keep it off them as *common.SchedulingAdvice
+// in the result details.
+func RunPlatformCheck(clusterData *common.ClusterData) *common.CheckResult {
+	res := &common.CheckResult{Status: common.StatusPass}
+	if missing := clusterData.MissingResources(common.ResourceNodes); len(missing) > 0 {
+		res.Escalate(common.StatusWarn, ReasonNodesUnavailable,
+			fmt.Sprintf("Node platforms unknown: could not collect %s", common.DescribeMissing(missing)),
+			"Grant the checker list access to nodes (see the ClusterRole in k8s-manifest.yaml).")
+		return res
+	}
+
+	supportedArch := map[string]bool{}
+	for _, arch := range common.NodeAgentArchitectures {
+		supportedArch[arch] = true
+	}
+
+	advice := &common.SchedulingAdvice{}
+	platforms := map[string]int{} // e.g. "windows/amd64" -> node count
+	var pools []string
+	for i := range clusterData.Nodes {
+		node := &clusterData.Nodes[i]
+		os, arch := common.NodeOS(node), common.NodeArch(node)
+		var reason, message string
+		switch {
+		case os != "linux":
+			advice.LinuxOnly = true
+			reason, message = ReasonUnsupportedOS, fmt.Sprintf("%s node: the node-agent only runs on Linux", os)
+		case arch != "" && !supportedArch[arch]:
+			advice.Architectures = common.NodeAgentArchitectures
+			reason, message = ReasonUnsupportedArch, fmt.Sprintf("%s node: no node-agent image for this architecture", arch)
+		default:
+			continue
+		}
+		advice.ExcludedNodes = append(advice.ExcludedNodes, node.Name)
+		platforms[os+"/"+arch]++
+		pools = append(pools, common.NodePool(node))
+		res.Findings = append(res.Findings, common.Finding{
+			Kind:    "Node",
+			Name:    node.Name,
+			Status:  common.StatusWarn,
+			Reason:  reason,
+			Message: message,
+		})
+	}
+
+	excluded := len(advice.ExcludedNodes)
+	if excluded == 0 {
+		res.Message = fmt.Sprintf("All %d nodes run Linux on %s", len(clusterData.Nodes), strings.Join(common.NodeAgentArchitectures, " or "))
+		return res
+	}
+	res.Details = advice
+
+	summary := fmt.Sprintf("%d of %d nodes cannot run the node-agent: %s (pools: %s)",
+		excluded, len(clusterData.Nodes), common.DescribeCounts(platforms), common.DescribePools(pools))
+	reason := res.Findings[0].Reason
+	if excluded == len(clusterData.Nodes) {
+		res.Escalate(common.StatusFail, ReasonNoSupportedNodes, summary,
+			fmt.Sprintf("Add Linux nodes (%s) for the Kubescape components.", strings.Join(common.NodeAgentArchitectures, " or ")))
+		return res
+	}
+	res.Escalate(common.StatusWarn, reason, summary,
+		"Install with recommended-values.yaml: its node affinity and node selector keep the Kubescape components off these nodes.")
+	return res
+}
+
+// Check exposes the node platform check through the common.Check interface.
+type Check struct{}
+
+func (Check) Name() string        { return common.PlatformCheckName }
+func (Check) Description() string { return "Node OS and Architecture Check" }
+
+func (Check) Run(ctx context.Context, env *common.CheckEnv) *common.CheckResult {
+	return RunPlatformCheck(env.ClusterData)
+}
@@ -0,0 +1,76 @@
+package platformcheck
+
+import (
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/kubescape/sizing-checker/pkg/common"
+	"github.com/kubescape/sizing-checker/pkg/testutil"
+)
+
+func TestRunPlatformCheck(t *testing.T) {
+	tests := []struct {
+		name       string
+		nodes      []corev1.Node
+		wantStatus common.CheckStatus
+		wantReason string
+		wantAdvice *common.SchedulingAdvice
+	}{
+		{
+			name:       "linux only",
+			nodes:      []corev1.Node{*testutil.Node("n1", "4", "16Gi"), *testutil.Node("n2", "4", "16Gi", testutil.WithOS("linux", "arm64"))},
+			wantStatus: common.StatusPass,
+		},
+		{
+			name: "windows and s390x nodes",
+			nodes: []corev1.Node{
+				*testutil.Node("n1", "4", "16Gi"),
+				*testutil.Node("win", "4", "16Gi", testutil.WithOS("windows", "amd64")),
+				*testutil.Node("mainframe", "4", "16Gi", testutil.WithOS("linux", "s390x")),
+			},
+			wantStatus: common.StatusWarn,
+			wantReason: ReasonUnsupportedOS,
+			wantAdvice: &common.SchedulingAdvice{LinuxOnly: true, Architectures: common.NodeAgentArchitectures, ExcludedNodes: []string{"win", "mainframe"}},
+		},
+		{
+			name:       "windows only",
+			nodes:      []corev1.Node{*testutil.Node("win", "4", "16Gi", testutil.WithOS("windows", "amd64"))},
+			wantStatus: common.StatusFail,
+			wantReason: ReasonNoSupportedNodes,
+			wantAdvice: &common.SchedulingAdvice{LinuxOnly: true, ExcludedNodes: []string{"win"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := RunPlatformCheck(&common.ClusterData{Nodes: tt.nodes})
+			if res.Status != tt.wantStatus || res.Reason != tt.wantReason {
+				t.Fatalf("RunPlatformCheck() = %s/%q, want %s/%q: %s", res.Status, res.Reason, tt.wantStatus, tt.wantReason, res.Message)
+			}
+			advice, _ := res.Details.(*common.SchedulingAdvice)
+			if (advice == nil) != (tt.wantAdvice == nil) {
+				t.Fatalf("Details = %#v, want %#v", res.Details, tt.wantAdvice)
+			}
+			if advice != nil && (advice.LinuxOnly != tt.wantAdvice.LinuxOnly ||
+				strings.Join(advice.Architectures, ",") != strings.Join(tt.wantAdvice.Architectures, ",") ||
+				strings.Join(advice.ExcludedNodes, ",") != strings.Join(tt.wantAdvice.ExcludedNodes, ",")) {
+				t.Errorf("advice = %+v, want %+v", advice, tt.wantAdvice)
+			}
+			if len(res.Findings) != len(tt.nodes)-countLinux(tt.nodes) {
+				t.Errorf("findings = %+v", res.Findings)
+			}
+		})
+	}
+}
+
+// countLinux counts the nodes the node-agent supports.
+func countLinux(nodes []corev1.Node) int {
+	n := 0
+	for i := range nodes {
+		if common.NodeOS(&nodes[i]) == "linux" && common.NodeArch(&nodes[i]) != "s390x" {
+			n++
+		}
+	}
+	return n
+}
@@ -21,13 +21,42 @@ const (
 	noProvisioner              = "kubernetes.io/no-provisioner"
 )
 
+// Reason codes reported by the PV provisioning check.
+const (
+	ReasonNoNodes                = "NoNodes"
+	ReasonNoSchedulableNodes     = "NoSchedulableNodes"
+	ReasonNoStorageClasses       = "NoStorageClasses"
+	ReasonNoDynamicStorageClass  = "NoDynamicStorageClass"
+	ReasonNoDefaultStorageClass  = "NoDefaultStorageClass"
+	ReasonPVCNotBound            = "PVCNotBound"
+	ReasonTestPodNotFound        = "TestPodNotFound"
+	ReasonPVNotBound             = "PVNotBound"
+	ReasonProvisioningTestFailed = "ProvisioningTestFailed"
+	ReasonInputsUnavailable      = "InputsUnavailable"
+)
+
+// provisioningTimeout bounds how long the full test waits for the PVC and Pod.
+var provisioningTimeout = 10 * time.Second
+
+const remediationPersistence = "Mark a StorageClass with a dynamic provisioner as default, " +
+	"or install with configurations.persistence=disable (included in recommended-values.yaml)."
+
 // RunPVProvisioningCheck decides if we run a full test (PVC/Pod existence check) or just a basic check.
 func RunPVProvisioningCheck(
 	ctx context.Context,
-	clientset *kubernetes.Clientset,
+	clientset kubernetes.Interface,
 	clusterData *common.ClusterData,
 	inCluster bool,
-) *common.PVCheckResult {
+) *common.CheckResult {
+
+	// Without the nodes and StorageClasses the pre-check would report a
+	// misleading failure (and disable persistence), so report a warning instead.
+	if missing := clusterData.MissingResources(common.ResourceNodes, common.ResourceStorageClasses); len(missing) > 0 {
+		res := failWarningResult(ReasonInputsUnavailable,
+			fmt.Sprintf("Could not evaluate PV provisioning: could not collect %s.", common.DescribeMissing(missing)))
+		res.Remediation = "Grant the checker list access to nodes and storageclasses (see the ClusterRole in k8s-manifest.yaml)."
+		return res
+	}
 
 	if inCluster {
 		// Full test: expect PVC + Pod to already exist
@@ -35,25 +64,12 @@ func RunPVProvisioningCheck(
 	}
 
 	// Only run the basic pre-check:
-	passed, failReason := BasicPreCheck(ctx, clientset, clusterData)
+	passed, reason, failMsg := BasicPreCheck(ctx, clientset, clusterData)
 	if passed {
-		// Basic check passed => "Passed"
-		return &common.PVCheckResult{
-			PassedCount:   len(clusterData.Nodes),
-			FailedCount:   0,
-			TotalNodes:    len(clusterData.Nodes),
-			ResultMessage: "Passed",
-		}
+		return &common.CheckResult{Status: common.StatusPass}
 	}
 
-	// Basic check failed => "Failed"
-	log.Printf("Basic PV check failed: %s", failReason)
-	return &common.PVCheckResult{
-		PassedCount:   0,
-		FailedCount:   len(clusterData.Nodes),
-		TotalNodes:    len(clusterData.Nodes),
-		ResultMessage: "Failed",
-	}
+	return failResult(reason, failMsg)
 }
 
 // runFullProvisioningTest verifies that:
@@ -62,77 +78,81 @@ func RunPVProvisioningCheck(
 // 3) If both checks pass, we confirm the PVC’s backing PV is present and Bound.
 func runFullProvisioningTest(
 	ctx context.Context,
-	clientset *kubernetes.Clientset,
+	clientset kubernetes.Interface,
 	clusterData *common.ClusterData,
-) *common.PVCheckResult {
+) *common.CheckResult {
 
 	// 1) Pre-check for dynamic provisioning
-	passed, failReason := BasicPreCheck(ctx, clientset, clusterData)
+	passed, reason, failMsg := BasicPreCheck(ctx, clientset, clusterData)
 	if !passed {
-		return failResult(len(clusterData.Nodes), failReason)
+		return failResult(reason, failMsg)
 	}
 
 	namespace := "kubescape-prerequisite"
 	pvcName := "kubescape-pv-check-pvc"
 	podName := "kubescape-pv-check-pod"
-	timeout := 10 * time.Second
+	timeout := provisioningTimeout
 
 	// 2) Wait for PVC to become Bound
 	pvc, err := waitForPVCBound(ctx, clientset, namespace, pvcName, timeout)
 	if err != nil {
 		// Distinguish between an actual error vs. a timeout
-		if ctx.Err() != nil {
-			return failWarningResult(len(clusterData.Nodes),
+		if wait.Interrupted(err) {
+			return failWarningResult(ReasonPVCNotBound,
 				"Could not complete the check. The PVC required for the check did not become Bound within the timeout.")
 		}
-		return failResult(len(clusterData.Nodes), fmt.Sprintf("Error waiting for PVC to be Bound: %v", err))
+		return failResult(ReasonProvisioningTestFailed, fmt.Sprintf("Error waiting for PVC to be Bound: %v", err))
 	}
 
 	// 2) Also wait up to 10 seconds for the Pod to appear
 	err = waitForPodExists(ctx, clientset, namespace, podName, timeout)
 	if err != nil {
-		if ctx.Err() != nil {
-			return failWarningResult(len(clusterData.Nodes),
-				"Could not complete the check. The Pod required for the check was not found within 10 seconds.")
+		if wait.Interrupted(err) {
+			return failWarningResult(ReasonTestPodNotFound,
+				fmt.Sprintf("Could not complete the check. The Pod required for the check was not found within %s.", timeout))
 		}
-		return failResult(len(clusterData.Nodes), fmt.Sprintf("Error checking Pod existence: %v", err))
+		return failResult(ReasonProvisioningTestFailed, fmt.Sprintf("Error checking Pod existence: %v", err))
 	}
 
 	// 3) Check the backing PV is present and Bound
 	pvName := pvc.Spec.VolumeName
 	if pvName == "" {
-		return failResult(len(clusterData.Nodes), "PVC is Bound but has empty volume name; cannot find PV.")
+		return failResult(ReasonPVNotBound, "PVC is Bound but has empty volume name; cannot find PV.")
 	}
 
 	pv, err := clientset.CoreV1().PersistentVolumes().Get(ctx, pvName, metav1.GetOptions{})
 	if err != nil {
-		return failResult(len(clusterData.Nodes), fmt.Sprintf("Failed retrieving PV %q: %v", pvName, err))
+		return failResult(ReasonProvisioningTestFailed, fmt.Sprintf("Failed retrieving PV %q: %v", pvName, err))
 	}
 	if pv.Status.Phase != corev1.VolumeBound {
-		return failResult(len(clusterData.Nodes),
+		return failResult(ReasonPVNotBound,
 			fmt.Sprintf("PV %q is present but not Bound (current phase: %s)", pvName, pv.Status.Phase))
 	}
 
 	// If everything was successful => "Passed"
-	return &common.PVCheckResult{
-		PassedCount:   len(clusterData.Nodes),
-		FailedCount:   0,
-		TotalNodes:    len(clusterData.Nodes),
-		ResultMessage: "Passed",
+	return &common.CheckResult{
+		Status: common.StatusPass,
+		Findings: []common.Finding{{
+			Kind:    "PersistentVolume",
+			Name:    pvName,
+			Status:  common.StatusPass,
+			Message: fmt.Sprintf("Bound to %s/%s", namespace, pvcName),
+		}},
 	}
 }
 
 // BasicPreCheck ensures there's at least one schedulable node,
 // at least one dynamic StorageClass, and at least one default dynamic SC.
+// On failure it returns a reason code and a human-readable message.
 func BasicPreCheck(
 	ctx context.Context,
-	clientset *kubernetes.Clientset,
+	clientset kubernetes.Interface,
 	clusterData *common.ClusterData,
-) (bool, string) {
+) (bool, string, string) {
 
 	totalNodes := len(clusterData.Nodes)
 	if totalNodes == 0 {
-		return false, "No nodes found in cluster."
+		return false, ReasonNoNodes, "No nodes found in cluster."
 	}
 
 	// Check for at least one schedulable node
@@ -144,13 +164,13 @@ func BasicPreCheck(
 		}
 	}
 	if !schedulableFound {
-		return false, "No schedulable node found (all unschedulable)."
+		return false, ReasonNoSchedulableNodes, "No schedulable node found (all unschedulable)."
 	}
 
 	// Reuse the storage classes from clusterData
 	scList := clusterData.StorageClasses
 	if len(scList) == 0 {
-		return false, "No StorageClasses found; dynamic provisioning not available."
+		return false, ReasonNoStorageClasses, "No StorageClasses found; dynamic provisioning not available."
 	}
 
 	// Identify dynamic StorageClasses
@@ -161,7 +181,7 @@ func BasicPreCheck(
 		}
 	}
 	if len(dynamicSCs) == 0 {
-		return false, "All StorageClasses use 'no-provisioner'; no dynamic provisioning."
+		return false, ReasonNoDynamicStorageClass, "All StorageClasses use 'no-provisioner'; no dynamic provisioning."
 	}
 
 	// Require at least one default dynamic SC
@@ -173,11 +193,11 @@ func BasicPreCheck(
 		}
 	}
 	if !hasDefault {
-		return false, "No default dynamic StorageClass found."
+		return false, ReasonNoDefaultStorageClass, "No default dynamic StorageClass found."
 	}
 
 	// If we got here, all theoretical checks passed
-	return true, ""
+	return true, "", ""
 }
 
 func isStorageClassDefault(sc *storagev1.StorageClass) bool {
@@ -196,7 +216,7 @@ func isStorageClassDefault(sc *storagev1.StorageClass) bool {
 // waitForPVCBound waits up to 'timeout' for the PVC to exist and transition to Bound.
 func waitForPVCBound(
 	ctx context.Context,
-	clientset *kubernetes.Clientset,
+	clientset kubernetes.Interface,
 	ns, pvcName string,
 	timeout time.Duration,
 ) (*corev1.PersistentVolumeClaim, error) {
@@ -237,7 +257,7 @@ func waitForPVCBound(
 // waitForPodExists uses PollUntilContextTimeout to check for existence of the Pod.
 func waitForPodExists(
 	ctx context.Context,
-	clientset *kubernetes.Clientset,
+	clientset kubernetes.Interface,
 	ns, podName string,
 	timeout time.Duration,
 ) error {
@@ -261,24 +281,34 @@ func waitForPodExists(
 	)
 }
 
-// failResult is a helper to generate a PVCheckResult with "Failed".
-func failResult(totalNodes int, reason string) *common.PVCheckResult {
-	log.Printf("Dynamic PV check failed: %s", reason)
-	return &common.PVCheckResult{
-		PassedCount:   0,
-		FailedCount:   totalNodes,
-		TotalNodes:    totalNodes,
-		ResultMessage: "Failed",
+// failResult is a helper to generate a failed CheckResult.
+func failResult(reason, message string) *common.CheckResult {
+	log.Printf("Dynamic PV check failed: %s", message)
+	return &common.CheckResult{
+		Status:      common.StatusFail,
+		Reason:      reason,
+		Message:     message,
+		Remediation: remediationPersistence,
 	}
 }
 
-// failWarningResult is a helper to generate a PVCheckResult with a "warning" message.
-func failWarningResult(totalNodes int, reason string) *common.PVCheckResult {
-	log.Printf("Dynamic PV check warning: %s", reason)
-	return &common.PVCheckResult{
-		PassedCount:   0,
-		FailedCount:   totalNodes,
-		TotalNodes:    totalNodes,
-		ResultMessage: fmt.Sprintf("Warning: %s", reason),
+// failWarningResult is a helper to generate a CheckResult for a test that could not complete.
+func failWarningResult(reason, message string) *common.CheckResult {
+	log.Printf("Dynamic PV check warning: %s", message)
+	return &common.CheckResult{
+		Status:      common.StatusWarn,
+		Reason:      reason,
+		Message:     message,
+		Remediation: "Verify that the kubescape-pv-check-pvc PVC and kubescape-pv-check-pod Pod from k8s-manifest.yaml were created.",
 	}
 }
+
+// Check exposes the PV provisioning check through the common.Check interface.
+type Check struct{}
+
+func (Check) Name() string        { return common.PVProvisioningCheckName }
+func (Check) Description() string { return "PV Provisioning Check" }
+
+func (Check) Run(ctx context.Context, env *common.CheckEnv) *common.CheckResult {
+	return RunPVProvisioningCheck(ctx, env.Clientset, env.ClusterData, env.InCluster)
+}
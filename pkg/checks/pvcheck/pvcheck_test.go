@@ -0,0 +1,160 @@
+package pvcheck
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	"github.com/kubescape/sizing-checker/pkg/common"
+	"github.com/kubescape/sizing-checker/pkg/testutil"
+)
+
+const testNamespace = "kubescape-prerequisite"
+
+func TestBasicPreCheck(t *testing.T) {
+	ready := []corev1.Node{*testutil.Node("n1", "4", "16Gi")}
+	defaultSC := *testutil.StorageClass("standard", "ebs.csi.aws.com", true)
+
+	tests := []struct {
+		name           string
+		nodes          []corev1.Node
+		storageClasses []storagev1.StorageClass
+		wantPassed     bool
+		wantReason     string
+	}{
+		{name: "no nodes", storageClasses: []storagev1.StorageClass{defaultSC}, wantReason: ReasonNoNodes},
+		{
+			name:           "all nodes unschedulable",
+			nodes:          []corev1.Node{*testutil.Node("n1", "4", "16Gi", testutil.Unschedulable())},
+			storageClasses: []storagev1.StorageClass{defaultSC},
+			wantReason:     ReasonNoSchedulableNodes,
+		},
+		{name: "no storage class", nodes: ready, wantReason: ReasonNoStorageClasses},
+		{
+			name:           "only static provisioning",
+			nodes:          ready,
+			storageClasses: []storagev1.StorageClass{*testutil.StorageClass("local", noProvisioner, true)},
+			wantReason:     ReasonNoDynamicStorageClass,
+		},
+		{
+			name:           "no default storage class",
+			nodes:          ready,
+			storageClasses: []storagev1.StorageClass{*testutil.StorageClass("standard", "ebs.csi.aws.com", false)},
+			wantReason:     ReasonNoDefaultStorageClass,
+		},
+		{
+			name:           "default dynamic storage class",
+			nodes:          ready,
+			storageClasses: []storagev1.StorageClass{*testutil.StorageClass("local", noProvisioner, false), defaultSC},
+			wantPassed:     true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cd := &common.ClusterData{Nodes: tt.nodes, StorageClasses: tt.storageClasses}
+			passed, reason, _ := BasicPreCheck(context.Background(), testutil.NewClientset(), cd)
+			if passed != tt.wantPassed || reason != tt.wantReason {
+				t.Errorf("BasicPreCheck() = %v, %q, want %v, %q", passed, reason, tt.wantPassed, tt.wantReason)
+			}
+		})
+	}
+}
+
+func TestRunPVProvisioningCheck(t *testing.T) {
+	defer func(d time.Duration) { provisioningTimeout = d }(provisioningTimeout)
+	provisioningTimeout = 1500 * time.Millisecond
+
+	cd := &common.ClusterData{
+		Nodes:          []corev1.Node{*testutil.Node("n1", "4", "16Gi")},
+		StorageClasses: []storagev1.StorageClass{*testutil.StorageClass("standard", "ebs.csi.aws.com", true)},
+	}
+
+	tests := []struct {
+		name       string
+		inCluster  bool
+		objects    []runtime.Object
+		wantStatus common.CheckStatus
+		wantReason string
+	}{
+		{name: "out of cluster pre-check only", wantStatus: common.StatusPass},
+		{
+			name:      "bound PVC",
+			inCluster: true,
+			objects: []runtime.Object{
+				testutil.PVC(testNamespace, "kubescape-pv-check-pvc", corev1.ClaimBound, "pv-1"),
+				testutil.Pod(testNamespace, "kubescape-pv-check-pod", "n1", "", ""),
+				testutil.PV("pv-1", corev1.VolumeBound),
+			},
+			wantStatus: common.StatusPass,
+		},
+		{
+			name:       "unbound PVC",
+			inCluster:  true,
+			objects:    []runtime.Object{testutil.PVC(testNamespace, "kubescape-pv-check-pvc", corev1.ClaimPending, "")},
+			wantStatus: common.StatusWarn,
+			wantReason: ReasonPVCNotBound,
+		},
+		{
+			name:      "bound PVC without test pod",
+			inCluster: true,
+			objects: []runtime.Object{
+				testutil.PVC(testNamespace, "kubescape-pv-check-pvc", corev1.ClaimBound, "pv-1"),
+				testutil.PV("pv-1", corev1.VolumeBound),
+			},
+			wantStatus: common.StatusWarn,
+			wantReason: ReasonTestPodNotFound,
+		},
+		{
+			name:      "PV not bound",
+			inCluster: true,
+			objects: []runtime.Object{
+				testutil.PVC(testNamespace, "kubescape-pv-check-pvc", corev1.ClaimBound, "pv-1"),
+				testutil.Pod(testNamespace, "kubescape-pv-check-pod", "n1", "", ""),
+				testutil.PV("pv-1", corev1.VolumeReleased),
+			},
+			wantStatus: common.StatusFail,
+			wantReason: ReasonPVNotBound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cs := testutil.NewClientset(tt.objects...)
+			res := RunPVProvisioningCheck(context.Background(), cs, cd, tt.inCluster)
+			if res.Status != tt.wantStatus || res.Reason != tt.wantReason {
+				t.Errorf("RunPVProvisioningCheck() = %s/%q (%s), want %s/%q",
+					res.Status, res.Reason, res.Message, tt.wantStatus, tt.wantReason)
+			}
+		})
+	}
+}
+
+func TestRunPVProvisioningCheckNoStorageClass(t *testing.T) {
+	cd := &common.ClusterData{Nodes: []corev1.Node{*testutil.Node("n1", "4", "16Gi")}}
+	res := RunPVProvisioningCheck(context.Background(), testutil.NewClientset(), cd, false)
+	if res.Status != common.StatusFail || res.Reason != ReasonNoStorageClasses {
+		t.Errorf("got %s/%q, want Fail/%q", res.Status, res.Reason, ReasonNoStorageClasses)
+	}
+	if res.Remediation == "" {
+		t.Error("a failed PV check should suggest a remediation")
+	}
+}
+
+func TestRunPVProvisioningCheckStorageClassesForbidden(t *testing.T) {
+	cd := &common.ClusterData{
+		Nodes: []corev1.Node{*testutil.Node("n1", "4", "16Gi")},
+		Collection: &common.CollectionStats{Resources: []common.ResourceCollection{
+			{Kind: common.ResourceStorageClasses, Status: common.CollectionForbidden},
+		}},
+	}
+	res := RunPVProvisioningCheck(context.Background(), testutil.NewClientset(), cd, false)
+	if res.Status != common.StatusWarn || res.Reason != ReasonInputsUnavailable {
+		t.Errorf("got %s/%q, want Warn/%q: a missing StorageClass list must not disable persistence",
+			res.Status, res.Reason, ReasonInputsUnavailable)
+	}
+}
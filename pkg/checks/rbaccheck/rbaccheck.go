@@ -0,0 +1,281 @@
+package rbaccheck
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	authorizationv1 "k8s.io/api/authorization/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+
+	"github.com/kubescape/sizing-checker/pkg/common"
+)
+
+// Reason codes reported by the RBAC check.
+const (
+	ReasonOffline                   = "Offline"
+	ReasonReviewFailed              = "ReviewFailed"
+	ReasonMissingPermissions        = "MissingPermissions"
+	ReasonMissingInstallPermissions = "MissingInstallPermissions"
+)
+
+// rule grants verbs on resources of an API group, like a ClusterRole rule.
+type rule struct {
+	group     string
+	resources []string
+	verbs     []string
+}
+
+// checkerRules mirrors the ClusterRole in k8s-manifest.yaml, except for the
+// ConfigMap rule, which is only needed in-cluster (see reportRules).
+var checkerRules = []rule{
+	{"", []string{"pods", "services", "replicationcontrollers", "nodes", "persistentvolumes", "persistentvolumeclaims"}, []string{"get", "list"}},
+	{"apps", []string{"deployments", "replicasets", "daemonsets", "statefulsets"}, []string{"list"}},
+	{"batch", []string{"jobs", "cronjobs"}, []string{"list"}},
+	{"storage.k8s.io", []string{"storageclasses"}, []string{"list"}},
+}
+
+// reportRules are needed by in-cluster runs to store the report ConfigMaps.
+var reportRules = []rule{
+	{"", []string{"configmaps"}, []string{"create", "update", "get", "list", "delete"}},
+}
+
+// probeRules are needed to run the node probe DaemonSet (--node-probe), in
+// its namespace. Listing DaemonSets and Pods is covered by checkerRules.
+var probeRules = []rule{
+	{"apps", []string{"daemonsets"}, []string{"create", "delete"}},
+}
+
+// installNamespace is the namespace the Kubescape operator chart installs into.
+const installNamespace = "kubescape"
+
+// installClusterRules and installNamespacedRules are the permissions needed
+// to install the Kubescape operator Helm chart. They are checked on local
+// runs only, where the checker runs with the credentials of the person who
+// will install Kubescape.
+var installClusterRules = []rule{
+	{"", []string{"namespaces"}, []string{"create"}},
+	{"apiextensions.k8s.io", []string{"customresourcedefinitions"}, []string{"create"}},
+	{"rbac.authorization.k8s.io", []string{"clusterroles", "clusterrolebindings"}, []string{"create"}},
+}
+
+var installNamespacedRules = []rule{
+	{"", []string{"serviceaccounts", "secrets", "configmaps", "services", "persistentvolumeclaims"}, []string{"create"}},
+	{"rbac.authorization.k8s.io", []string{"roles", "rolebindings"}, []string{"create"}},
+	{"apps", []string{"deployments", "daemonsets", "statefulsets"}, []string{"create"}},
+	{"batch", []string{"cronjobs"}, []string{"create"}},
+}
+
+// permission is a single verb on a resource, optionally within a namespace.
+type permission struct {
+	group     string
+	resource  string
+	verb      string
+	namespace string
+}
+
+func (p permission) String() string {
+	resource := p.resource
+	if p.group != "" {
+		resource = p.group + "/" + p.resource
+	}
+	if p.namespace != "" {
+		return fmt.Sprintf("%s %s in namespace %s", p.verb, resource, p.namespace)
+	}
+	return fmt.Sprintf("%s %s", p.verb, resource)
+}
+
+// expand turns rules into the individual permissions they grant.
+func expand(rules []rule, namespace string) []permission {
+	var perms []permission
+	for _, r := range rules {
+		for _, res := range r.resources {
+			for _, verb := range r.verbs {
+				perms = append(perms, permission{group: r.group, resource: res, verb: verb, namespace: namespace})
+			}
+		}
+	}
+	return perms
+}
+
+// RunRBACCheck verifies, before any data is collected, that the checker
+// holds the permissions it needs and, on local runs, that the current user
+// can install Kubescape. probeNamespace is empty unless the node probe runs.
+func RunRBACCheck(ctx context.Context, clientset kubernetes.Interface, inCluster bool, reportNamespace, probeNamespace string) *common.CheckResult {
+	checkerPerms := expand(checkerRules, "")
+	if inCluster {
+		checkerPerms = append(checkerPerms, expand(reportRules, reportNamespace)...)
+	}
+	if probeNamespace != "" {
+		checkerPerms = append(checkerPerms, expand(probeRules, probeNamespace)...)
+	}
+	var installPerms []permission
+	if !inCluster {
+		installPerms = append(expand(installClusterRules, ""), expand(installNamespacedRules, installNamespace)...)
+	}
+
+	reviewer := newReviewer(clientset)
+	missingChecker, err := reviewer.missing(ctx, checkerPerms)
+	if err != nil {
+		return reviewFailed(err)
+	}
+	missingInstall, err := reviewer.missing(ctx, installPerms)
+	if err != nil {
+		return reviewFailed(err)
+	}
+
+	res := &common.CheckResult{Status: common.StatusPass}
+	for _, p := range missingInstall {
+		res.Findings = append(res.Findings, common.Finding{
+			Kind:    "Permission",
+			Name:    p.String(),
+			Status:  common.StatusWarn,
+			Reason:  ReasonMissingInstallPermissions,
+			Message: "needed to install the Kubescape operator chart",
+		})
+	}
+	for _, p := range missingChecker {
+		res.Findings = append(res.Findings, common.Finding{
+			Kind:    "Permission",
+			Name:    p.String(),
+			Status:  common.StatusFail,
+			Reason:  ReasonMissingPermissions,
+			Message: "needed by the checker; the report will be based on partial data",
+		})
+	}
+
+	if len(missingChecker) > 0 {
+		res.Escalate(common.StatusFail, ReasonMissingPermissions,
+			fmt.Sprintf("Missing %d of %d permissions the checker needs: %s",
+				len(missingChecker), len(checkerPerms), joinPermissions(missingChecker)),
+			"Apply the ClusterRole and ClusterRoleBinding (and, for --node-probe, the node probe Role and RoleBinding) from k8s-manifest.yaml to the identity running the checker.")
+	}
+	if len(missingInstall) > 0 {
+		res.Escalate(common.StatusWarn, ReasonMissingInstallPermissions,
+			fmt.Sprintf("Missing %d of %d permissions needed to install Kubescape", len(missingInstall), len(installPerms)),
+			"Install Kubescape with a cluster-admin (or equivalent) identity.")
+	}
+	if res.Status == common.StatusPass {
+		res.Message = fmt.Sprintf("All %d required permissions granted", len(checkerPerms)+len(installPerms))
+	}
+	return res
+}
+
+func reviewFailed(err error) *common.CheckResult {
+	log.Printf("RBAC check: %v", err)
+	return &common.CheckResult{
+		Status:  common.StatusError,
+		Reason:  ReasonReviewFailed,
+		Message: fmt.Sprintf("Could not review the checker's permissions: %v", err),
+	}
+}
+
+func joinPermissions(perms []permission) string {
+	names := make([]string, 0, len(perms))
+	for _, p := range perms {
+		names = append(names, p.String())
+	}
+	return strings.Join(names, ", ")
+}
+
+// reviewer answers permission questions with one SelfSubjectRulesReview per
+// namespace, falling back to a SelfSubjectAccessReview for every permission
+// the rules do not grant, since rule reviews may be incomplete (e.g. with
+// webhook authorizers). Cluster-wide permissions always use an access
+// review: a rules review also returns the rules of RoleBindings, which do not
+// grant access across namespaces.
+type reviewer struct {
+	clientset kubernetes.Interface
+	rules     map[string][]authorizationv1.ResourceRule // by namespace
+}
+
+func newReviewer(clientset kubernetes.Interface) *reviewer {
+	return &reviewer{clientset: clientset, rules: map[string][]authorizationv1.ResourceRule{}}
+}
+
+// missing returns the permissions that are not granted.
+func (r *reviewer) missing(ctx context.Context, perms []permission) ([]permission, error) {
+	var missing []permission
+	for _, p := range perms {
+		if r.grantedByRules(ctx, p) {
+			continue
+		}
+		allowed, err := r.accessReview(ctx, p)
+		if err != nil {
+			return nil, err
+		}
+		if !allowed {
+			missing = append(missing, p)
+		}
+	}
+	return missing, nil
+}
+
+// grantedByRules reports whether the rules of p's namespace grant p.
+func (r *reviewer) grantedByRules(ctx context.Context, p permission) bool {
+	ns := p.namespace
+	if ns == "" {
+		return false
+	}
+	rules, ok := r.rules[ns]
+	if !ok {
+		review, err := r.clientset.AuthorizationV1().SelfSubjectRulesReviews().Create(ctx,
+			&authorizationv1.SelfSubjectRulesReview{Spec: authorizationv1.SelfSubjectRulesReviewSpec{Namespace: ns}},
+			metav1.CreateOptions{})
+		if err == nil {
+			rules = review.Status.ResourceRules
+		}
+		r.rules[ns] = rules
+	}
+	for _, rule := range rules {
+		if len(rule.ResourceNames) == 0 &&
+			matches(rule.Verbs, p.verb) && matches(rule.APIGroups, p.group) && matches(rule.Resources, p.resource) {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *reviewer) accessReview(ctx context.Context, p permission) (bool, error) {
+	review, err := r.clientset.AuthorizationV1().SelfSubjectAccessReviews().Create(ctx,
+		&authorizationv1.SelfSubjectAccessReview{Spec: authorizationv1.SelfSubjectAccessReviewSpec{
+			ResourceAttributes: &authorizationv1.ResourceAttributes{
+				Namespace: p.namespace,
+				Verb:      p.verb,
+				Group:     p.group,
+				Resource:  p.resource,
+			},
+		}},
+		metav1.CreateOptions{})
+	if err != nil {
+		return false, fmt.Errorf("access review for %q failed: %w", p.String(), err)
+	}
+	return review.Status.Allowed, nil
+}
+
+// matches reports whether values contains value or the "*" wildcard.
+func matches(values []string, value string) bool {
+	for _, v := range values {
+		if v == value || v == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+// Check exposes the RBAC check through the common.Check interface. It runs
+// as a preflight check, before the cluster data is collected.
+type Check struct{}
+
+func (Check) Name() string        { return common.RBACCheckName }
+func (Check) Description() string { return "RBAC Check" }
+func (Check) Preflight() bool     { return true }
+
+func (Check) Run(ctx context.Context, env *common.CheckEnv) *common.CheckResult {
+	if env.Offline {
+		return &common.CheckResult{Status: common.StatusSkip, Reason: ReasonOffline, Message: "Skipped"}
+	}
+	return RunRBACCheck(ctx, env.Clientset, env.InCluster, env.ReportNamespace, env.NodeProbeNamespace)
+}
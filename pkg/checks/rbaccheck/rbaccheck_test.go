@@ -0,0 +1,168 @@
+package rbaccheck
+
+import (
+	"context"
+	"os"
+	"sort"
+	"strings"
+	"testing"
+
+	authorizationv1 "k8s.io/api/authorization/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	k8stesting "k8s.io/client-go/testing"
+	sigsyaml "sigs.k8s.io/yaml"
+
+	"github.com/kubescape/sizing-checker/pkg/common"
+	"github.com/kubescape/sizing-checker/pkg/testutil"
+)
+
+// authorizer answers access reviews from a set of denied permissions and
+// rules reviews from a fixed rule list, counting the access reviews.
+type authorizer struct {
+	denied       map[string]bool // by permission.String()
+	rules        []authorizationv1.ResourceRule
+	accessChecks int
+}
+
+func (a *authorizer) install(cs k8stesting.FakeClient) {
+	cs.PrependReactor("create", "selfsubjectaccessreviews", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		review := action.(k8stesting.CreateAction).GetObject().(*authorizationv1.SelfSubjectAccessReview)
+		attrs := review.Spec.ResourceAttributes
+		p := permission{group: attrs.Group, resource: attrs.Resource, verb: attrs.Verb, namespace: attrs.Namespace}
+		a.accessChecks++
+		review.Status.Allowed = !a.denied[p.String()]
+		return true, review, nil
+	})
+	cs.PrependReactor("create", "selfsubjectrulesreviews", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		review := action.(k8stesting.CreateAction).GetObject().(*authorizationv1.SelfSubjectRulesReview)
+		review.Status.ResourceRules = a.rules
+		return true, review, nil
+	})
+}
+
+func TestRunRBACCheck(t *testing.T) {
+	tests := []struct {
+		name           string
+		inCluster      bool
+		probeNamespace string
+		denied         []string
+		wantStatus     common.CheckStatus
+		wantReason     string
+	}{
+		{name: "all granted locally", wantStatus: common.StatusPass},
+		{name: "all granted in-cluster", inCluster: true, wantStatus: common.StatusPass},
+		{
+			name:       "cannot list cronjobs",
+			denied:     []string{"list batch/cronjobs"},
+			wantStatus: common.StatusFail,
+			wantReason: ReasonMissingPermissions,
+		},
+		{
+			name:       "cannot install",
+			denied:     []string{"create apiextensions.k8s.io/customresourcedefinitions"},
+			wantStatus: common.StatusWarn,
+			wantReason: ReasonMissingInstallPermissions,
+		},
+		{
+			name:       "install permissions are not checked in-cluster",
+			inCluster:  true,
+			denied:     []string{"create apiextensions.k8s.io/customresourcedefinitions"},
+			wantStatus: common.StatusPass,
+		},
+		{
+			name:       "cannot write the report",
+			inCluster:  true,
+			denied:     []string{"delete configmaps in namespace reports"},
+			wantStatus: common.StatusFail,
+			wantReason: ReasonMissingPermissions,
+		},
+		{
+			name:           "cannot run the node probe",
+			probeNamespace: "probes",
+			denied:         []string{"create apps/daemonsets in namespace probes"},
+			wantStatus:     common.StatusFail,
+			wantReason:     ReasonMissingPermissions,
+		},
+		{
+			name:       "node probe permissions are only checked with --node-probe",
+			denied:     []string{"create apps/daemonsets in namespace probes"},
+			wantStatus: common.StatusPass,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := &authorizer{denied: map[string]bool{}}
+			for _, d := range tt.denied {
+				a.denied[d] = true
+			}
+			cs := testutil.NewClientset()
+			a.install(cs)
+
+			res := RunRBACCheck(context.Background(), cs, tt.inCluster, "reports", tt.probeNamespace)
+			if res.Status != tt.wantStatus || res.Reason != tt.wantReason {
+				t.Fatalf("RunRBACCheck() = %s/%q (%s), want %s/%q", res.Status, res.Reason, res.Message, tt.wantStatus, tt.wantReason)
+			}
+			if len(res.Findings) != len(tt.denied) && tt.wantStatus != common.StatusPass {
+				t.Errorf("got %d findings, want %d: %+v", len(res.Findings), len(tt.denied), res.Findings)
+			}
+		})
+	}
+}
+
+func TestRunRBACCheckUsesRulesReview(t *testing.T) {
+	a := &authorizer{rules: []authorizationv1.ResourceRule{{Verbs: []string{"*"}, APIGroups: []string{"*"}, Resources: []string{"*"}}}}
+	cs := testutil.NewClientset()
+	a.install(cs)
+
+	res := RunRBACCheck(context.Background(), cs, true, "reports", "")
+	if res.Status != common.StatusPass {
+		t.Fatalf("RunRBACCheck() = %s (%s), want Pass", res.Status, res.Message)
+	}
+	// The namespaced ConfigMap permissions are granted by the rules review;
+	// cluster-wide permissions always need an access review.
+	if want := len(expand(checkerRules, "")); a.accessChecks != want {
+		t.Errorf("made %d access reviews, want %d", a.accessChecks, want)
+	}
+}
+
+// TestCheckerRulesMatchManifest keeps checkerRules and reportRules in sync
+// with the ClusterRole the checker is deployed with.
+func TestCheckerRulesMatchManifest(t *testing.T) {
+	data, err := os.ReadFile("../../../k8s-manifest.yaml")
+	if err != nil {
+		t.Fatalf("reading manifest: %v", err)
+	}
+	var role *rbacv1.ClusterRole
+	for _, doc := range strings.Split(string(data), "\n---") {
+		var obj rbacv1.ClusterRole
+		if err := sigsyaml.Unmarshal([]byte(doc), &obj); err == nil && obj.Kind == "ClusterRole" {
+			role = &obj
+		}
+	}
+	if role == nil {
+		t.Fatal("no ClusterRole in k8s-manifest.yaml")
+	}
+
+	var fromManifest []string
+	for _, r := range role.Rules {
+		for _, group := range r.APIGroups {
+			fromManifest = append(fromManifest, permissionNames(expand([]rule{{group, r.Resources, r.Verbs}}, ""))...)
+		}
+	}
+	fromCode := permissionNames(expand(append(append([]rule{}, checkerRules...), reportRules...), ""))
+	sort.Strings(fromManifest)
+	sort.Strings(fromCode)
+	if strings.Join(fromManifest, "\n") != strings.Join(fromCode, "\n") {
+		t.Errorf("checker rules differ from the manifest ClusterRole:\nmanifest:\n%s\ncode:\n%s",
+			strings.Join(fromManifest, "\n"), strings.Join(fromCode, "\n"))
+	}
+}
+
+func permissionNames(perms []permission) []string {
+	names := make([]string, 0, len(perms))
+	for _, p := range perms {
+		names = append(names, p.String())
+	}
+	return names
+}
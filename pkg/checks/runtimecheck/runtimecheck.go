@@ -0,0 +1,247 @@
+package runtimecheck
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/kubescape/sizing-checker/pkg/common"
+	"github.com/kubescape/sizing-checker/pkg/nodeprobe"
+)
+
+// Reason codes reported by the container runtime check.
+const (
+	ReasonUnsupportedRuntime = "UnsupportedRuntime"
+	ReasonUnknownRuntime     = "UnknownRuntime"
+	ReasonNodesUnavailable   = "NodesUnavailable"
+)
+
+// Container runtimes, as named in the node's containerRuntimeVersion.
+const (
+	runtimeContainerd = "containerd"
+	runtimeCRIO       = "cri-o"
+	runtimeDocker     = "docker"
+)
+
+// runtimeRule flags a container runtime, optionally combined with a cgroup
+// version, that the node-agent does not support (below minVersion, or at all
+// when minVersion is empty).
+type runtimeRule struct {
+	runtime    string
+	cgroup     string // "" matches any cgroup version, including unknown
+	minVersion string
+	status     common.CheckStatus
+	why        string
+}
+
+// runtimeRules are evaluated in order on every node; all matching rules are reported.
+var runtimeRules = []runtimeRule{
+	{runtime: runtimeContainerd, minVersion: "1.5", status: common.StatusFail,
+		why: "the node-agent reads container metadata through the CRI v1 API, available from containerd 1.5"},
+	{runtime: runtimeCRIO, minVersion: "1.20", status: common.StatusFail,
+		why: "the node-agent reads container metadata through the CRI v1 API, available from CRI-O 1.20"},
+	{runtime: runtimeDocker, cgroup: common.CgroupV2, minVersion: "20.10", status: common.StatusFail,
+		why: "Docker Engine supports cgroup v2 from 20.10"},
+	{runtime: runtimeContainerd, cgroup: common.CgroupV2, minVersion: "1.6", status: common.StatusWarn,
+		why: "containerd before 1.6 has incomplete cgroup v2 support, so container attribution may fail"},
+	{runtime: runtimeDocker, status: common.StatusWarn,
+		why: "Docker Engine is only reachable through cri-dockerd; container enrichment is best effort"},
+}
+
+// nodeRuntime is what is known about the container runtime of one node.
+type nodeRuntime struct {
+	node    string
+	pool    string
+	runtime string // e.g. "containerd"; the raw value when it is not a known runtime
+	version string // e.g. "1.7.22"
+	cgroup  string // v1, v2 or "" when unknown
+}
+
+// poolResult aggregates the nodes of one node pool.
+type poolResult struct {
+	name     string
+	nodes    int
+	runtimes map[string]bool // e.g. "containerd 1.7.22"
+	cgroups  map[string]bool
+	status   common.CheckStatus
+	reason   string
+	problems []string
+}
+
+// RunRuntimeCheck evaluates the container runtime and cgroup version of every
+// node the node-agent runs on against runtimeRules, and reports the result
+// per node pool, grouping nodes by poolLabel (--node-pool-label) first.
+// Cgroup versions come from the node probe results and, for in-cluster runs,
+// from the node the checker runs on (localNode, localCgroup).
+func RunRuntimeCheck(clusterData *common.ClusterData, poolLabel, localNode, localCgroup string) *common.CheckResult {
+	res := &common.CheckResult{Status: common.StatusPass}
+	if missing := clusterData.MissingResources(common.ResourceNodes); len(missing) > 0 {
+		res.Escalate(common.StatusWarn, ReasonNodesUnavailable,
+			fmt.Sprintf("Container runtimes unknown: could not collect %s", common.DescribeMissing(missing)),
+			"Grant the checker list access to nodes (see the ClusterRole in k8s-manifest.yaml).")
+		return res
+	}
+
+	cgroups := map[string]string{}
+	for _, p := range clusterData.NodeProbes {
+		if p.Status == common.NodeProbeOK && p.CgroupVersion != "" {
+			cgroups[p.Node] = p.CgroupVersion
+		}
+	}
+	if localNode != "" && localCgroup != "" {
+		cgroups[localNode] = localCgroup
+	}
+
+	pools := map[string]*poolResult{}
+	for i := range clusterData.Nodes {
+		node := &clusterData.Nodes[i]
+		if !common.NodeAgentSupported(node) {
+			continue // reported by the node platform check
+		}
+		nr := parseRuntime(node, poolLabel)
+		nr.cgroup = cgroups[node.Name]
+
+		poolName := nr.pool
+		if poolName == "" {
+			poolName = "(no pool)"
+		}
+		pool := pools[poolName]
+		if pool == nil {
+			pool = &poolResult{name: poolName, runtimes: map[string]bool{}, cgroups: map[string]bool{}, status: common.StatusPass}
+			pools[poolName] = pool
+		}
+		pool.nodes++
+		pool.runtimes[strings.TrimSpace(nr.runtime+" "+nr.version)] = true
+		if nr.cgroup != "" {
+			pool.cgroups["cgroup "+nr.cgroup] = true
+		}
+
+		for _, p := range evaluateNode(nr) {
+			res.Findings = append(res.Findings, p)
+			if p.Status.Severity() > pool.status.Severity() {
+				pool.status, pool.reason = p.Status, p.Reason
+			}
+			pool.problems = common.AppendUnique(pool.problems, p.Message)
+		}
+	}
+	if len(pools) == 0 {
+		res.Message = "No nodes to run the node-agent on"
+		return res
+	}
+
+	var summaries []string
+	for _, name := range common.SortedKeys(pools) {
+		pool := pools[name]
+		summary := fmt.Sprintf("%s: %s", name, describePool(pool))
+		summaries = append(summaries, summary)
+		message := summary
+		if len(pool.problems) > 0 {
+			message += "; " + strings.Join(pool.problems, "; ")
+		}
+		res.Findings = append(res.Findings, common.Finding{
+			Kind:    "NodePool",
+			Name:    name,
+			Status:  pool.status,
+			Reason:  pool.reason,
+			Message: message,
+		})
+		if pool.status != common.StatusPass {
+			res.Escalate(pool.status, pool.reason,
+				fmt.Sprintf("Node pool %s: %s", name, strings.Join(pool.problems, "; ")),
+				"Upgrade the container runtime of these node pools (or move to a node image with containerd 1.6+ or CRI-O 1.20+), or exclude them from the node-agent.")
+		}
+	}
+	if res.Status == common.StatusPass {
+		res.Message = "Supported container runtimes on all node pools: " + strings.Join(summaries, " | ")
+	}
+	return res
+}
+
+// evaluateNode returns a finding for every runtime rule a node breaks.
+func evaluateNode(nr nodeRuntime) []common.Finding {
+	finding := func(status common.CheckStatus, reason, message string) common.Finding {
+		return common.Finding{Kind: "Node", Name: nr.node, Status: status, Reason: reason, Message: message}
+	}
+	switch nr.runtime {
+	case runtimeContainerd, runtimeCRIO, runtimeDocker:
+	default:
+		return []common.Finding{finding(common.StatusWarn, ReasonUnknownRuntime,
+			fmt.Sprintf("container runtime %q is not tested with the node-agent", nr.runtime))}
+	}
+
+	var findings []common.Finding
+	for _, rule := range runtimeRules {
+		if rule.runtime != nr.runtime || (rule.cgroup != "" && rule.cgroup != nr.cgroup) {
+			continue
+		}
+		if rule.minVersion != "" {
+			ok, err := common.VersionAtLeast(nr.version, rule.minVersion)
+			if err != nil || ok {
+				continue
+			}
+		}
+		desc := strings.TrimSpace(nr.runtime + " " + nr.version)
+		if rule.cgroup != "" {
+			desc += " with cgroup " + rule.cgroup
+		}
+		findings = append(findings, finding(rule.status, ReasonUnsupportedRuntime, fmt.Sprintf("%s: %s", desc, rule.why)))
+	}
+	return findings
+}
+
+// parseRuntime splits the containerRuntimeVersion of a node, e.g.
+// "containerd://1.7.22-0ubuntu1" into "containerd" and "1.7.22-0ubuntu1".
+func parseRuntime(node *corev1.Node, poolLabel string) nodeRuntime {
+	nr := nodeRuntime{node: node.Name}
+	_, nr.pool = common.NodePoolLabel(node, poolLabel)
+	raw := node.Status.NodeInfo.ContainerRuntimeVersion
+	name, version, ok := strings.Cut(raw, "://")
+	if !ok {
+		nr.runtime = raw
+		if raw == "" {
+			nr.runtime = "unknown"
+		}
+		return nr
+	}
+	nr.runtime = strings.ToLower(name)
+	if nr.runtime == "crio" {
+		nr.runtime = runtimeCRIO
+	}
+	nr.version = strings.TrimPrefix(version, "v")
+	return nr
+}
+
+// describePool renders the node count, runtimes and cgroup versions of a
+// pool, e.g. "3 nodes, containerd 1.7.22, cgroup v2".
+func describePool(pool *poolResult) string {
+	parts := []string{fmt.Sprintf("%d node", pool.nodes)}
+	if pool.nodes != 1 {
+		parts[0] += "s"
+	}
+	parts = append(parts, common.SortedKeys(pool.runtimes)...)
+	if len(pool.cgroups) == 0 {
+		parts = append(parts, "cgroup unknown")
+	} else {
+		parts = append(parts, common.SortedKeys(pool.cgroups)...)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// Check exposes the container runtime check through the common.Check interface.
+type Check struct{}
+
+func (Check) Name() string        { return common.RuntimeCheckName }
+func (Check) Description() string { return "Container Runtime Check" }
+
+func (Check) Run(ctx context.Context, env *common.CheckEnv) *common.CheckResult {
+	// In-cluster, the Job knows the cgroup version of the node it runs on
+	var localNode, localCgroup string
+	if env.InCluster && !env.Offline {
+		localNode = os.Getenv("NODE_NAME")
+		localCgroup = nodeprobe.CgroupVersion()
+	}
+	return RunRuntimeCheck(env.ClusterData, env.NodePoolLabel, localNode, localCgroup)
+}
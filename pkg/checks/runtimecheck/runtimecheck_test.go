@@ -0,0 +1,83 @@
+package runtimecheck
+
+import (
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/kubescape/sizing-checker/pkg/common"
+	"github.com/kubescape/sizing-checker/pkg/testutil"
+)
+
+func pool(name string) testutil.NodeOption {
+	return testutil.WithLabels(map[string]string{"cloud.google.com/gke-nodepool": name})
+}
+
+func TestRunRuntimeCheckSupported(t *testing.T) {
+	cd := &common.ClusterData{
+		Nodes: []corev1.Node{
+			*testutil.Node("n1", "4", "16Gi", pool("default")),
+			*testutil.Node("n2", "4", "16Gi", pool("default"), testutil.WithRuntime("cri-o://1.29.1")),
+			*testutil.Node("win", "4", "16Gi", testutil.WithOS("windows", "amd64"), testutil.WithRuntime("containerd://1.6.0")),
+		},
+		NodeProbes: []common.NodeProbe{{Node: "n1", Status: common.NodeProbeOK, CgroupVersion: common.CgroupV2}},
+	}
+	res := RunRuntimeCheck(cd, "", "", "")
+	if res.Status != common.StatusPass {
+		t.Fatalf("RunRuntimeCheck() = %s: %s, want Pass", res.Status, res.Message)
+	}
+	want := "default: 2 nodes, containerd 1.7.22, cri-o 1.29.1, cgroup v2"
+	if !strings.Contains(res.Message, want) {
+		t.Errorf("message = %q, want it to contain %q", res.Message, want)
+	}
+	if len(res.Findings) != 1 || res.Findings[0].Kind != "NodePool" || res.Findings[0].Status != common.StatusPass {
+		t.Errorf("findings = %+v, want a single passing NodePool finding", res.Findings)
+	}
+}
+
+func TestRunRuntimeCheckUnsupported(t *testing.T) {
+	cd := &common.ClusterData{Nodes: []corev1.Node{
+		*testutil.Node("new", "4", "16Gi", pool("default")),
+		*testutil.Node("old", "4", "16Gi", pool("legacy"), testutil.WithRuntime("containerd://1.4.13")),
+		*testutil.Node("v2", "4", "16Gi", pool("docker"), testutil.WithRuntime("docker://19.3.15")),
+		*testutil.Node("odd", "4", "16Gi", pool("docker"), testutil.WithRuntime("")),
+	}}
+	res := RunRuntimeCheck(cd, "", "v2", common.CgroupV2)
+	if res.Status != common.StatusFail || res.Reason != ReasonUnsupportedRuntime {
+		t.Fatalf("RunRuntimeCheck() = %s/%q, want Fail/%q", res.Status, res.Reason, ReasonUnsupportedRuntime)
+	}
+
+	byName := map[string][]common.Finding{}
+	for _, f := range res.Findings {
+		byName[f.Kind+"/"+f.Name] = append(byName[f.Kind+"/"+f.Name], f)
+	}
+	if f := byName["Node/old"]; len(f) != 1 || f[0].Status != common.StatusFail || !strings.Contains(f[0].Message, "containerd 1.4.13") {
+		t.Errorf("old findings = %+v, want a Fail for containerd 1.4.13", f)
+	}
+	// Docker 19.03 on cgroup v2 fails, and Docker is always best effort
+	if f := byName["Node/v2"]; len(f) != 2 || f[0].Status != common.StatusFail || !strings.Contains(f[0].Message, "with cgroup v2") {
+		t.Errorf("v2 findings = %+v, want a Fail for Docker on cgroup v2 and a Warn", f)
+	}
+	if f := byName["Node/odd"]; len(f) != 1 || f[0].Reason != ReasonUnknownRuntime {
+		t.Errorf("odd findings = %+v, want an unknown runtime", f)
+	}
+	for pool, want := range map[string]common.CheckStatus{"default": common.StatusPass, "legacy": common.StatusFail, "docker": common.StatusFail} {
+		if f := byName["NodePool/"+pool]; len(f) != 1 || f[0].Status != want {
+			t.Errorf("pool %s = %+v, want %s", pool, f, want)
+		}
+	}
+	if byName["Node/new"] != nil {
+		t.Errorf("unexpected finding for node new: %+v", byName["Node/new"])
+	}
+}
+
+func TestRunRuntimeCheckNodesUnavailable(t *testing.T) {
+	cd := &common.ClusterData{Collection: &common.CollectionStats{Resources: []common.ResourceCollection{
+		{Kind: common.ResourceNodes, Status: common.CollectionForbidden},
+	}}}
+	res := RunRuntimeCheck(cd, "", "", "")
+	if res.Status != common.StatusWarn || res.Reason != ReasonNodesUnavailable {
+		t.Errorf("RunRuntimeCheck() = %s/%q, want Warn/%q", res.Status, res.Reason, ReasonNodesUnavailable)
+	}
+}
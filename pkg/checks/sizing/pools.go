@@ -0,0 +1,116 @@
+package sizing
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/kubescape/sizing-checker/pkg/common"
+)
+
+// nodeAgentComponent is the profile component that runs on every node, and
+// is therefore sized per node pool.
+const nodeAgentComponent = "nodeAgent"
+
+// allocate returns the resources of a component: the formula result where it
+// exceeds the default, the default otherwise.
+func allocate(cp common.ComponentProfile, inputs sizingInputs) map[string]string {
+	final := map[string]string{}
+	for key, def := range cp.Defaults {
+		final[key] = def
+		if f, ok := cp.Formulas[key]; ok {
+			final[key] = compareAndChoose(def, evaluate(f, key, inputs))
+		}
+	}
+	return final
+}
+
+// sizeNodePools sizes the node-agent for the largest node of each node pool,
+// grouping the nodes the node-agent can run on by poolLabel or else the
+// well-known pool labels. The pools listed in excludedPools get no node-agent.
+// It returns nil when there are fewer than two pools, and whether one
+// node-agent DaemonSet per pool is worth it: the pools need different
+// resources and each of them can be selected by its label.
+func sizeNodePools(data *common.ClusterData, cp common.ComponentProfile, inputs sizingInputs, poolLabel string, excludedPools []string) ([]common.NodePoolSizing, bool) {
+	excluded := map[string]bool{}
+	for _, p := range excludedPools {
+		excluded[p] = true
+	}
+
+	byPool := map[string]*common.NodePoolSizing{}
+	for i := range data.Nodes {
+		node := &data.Nodes[i]
+		if !common.NodeAgentSupported(node) {
+			continue // no node-agent to size
+		}
+		label, pool := common.NodePoolLabel(node, poolLabel)
+		if pool != "" && excluded[pool] {
+			continue
+		}
+		p := byPool[label+"="+pool]
+		if p == nil {
+			p = &common.NodePoolSizing{Pool: pool, Label: label}
+			byPool[label+"="+pool] = p
+		}
+		p.Nodes++
+		if cpu := int(node.Status.Capacity.Cpu().MilliValue()); cpu > p.MaxNodeCPUCapacity {
+			p.MaxNodeCPUCapacity = cpu
+		}
+		if mem := int(node.Status.Capacity.Memory().Value() / mebibyte); mem > p.MaxNodeMemoryMB {
+			p.MaxNodeMemoryMB = mem
+		}
+	}
+	if len(byPool) < 2 {
+		return nil, false
+	}
+
+	pools := make([]common.NodePoolSizing, 0, len(byPool))
+	for _, p := range byPool {
+		in := inputs
+		in.nodeCPUMilli, in.nodeMemMiB = p.MaxNodeCPUCapacity, p.MaxNodeMemoryMB
+		p.Resources = allocate(cp, in)
+		pools = append(pools, *p)
+	}
+	sort.Slice(pools, func(i, j int) bool { return pools[i].Pool < pools[j].Pool })
+
+	selectable := true
+	for _, p := range pools {
+		selectable = selectable && p.Label != ""
+	}
+	return pools, selectable && poolsDiffer(pools)
+}
+
+// poolsDiffer reports whether the node pools need different node-agent resources.
+func poolsDiffer(pools []common.NodePoolSizing) bool {
+	for _, p := range pools {
+		if !reflect.DeepEqual(p.Resources, pools[0].Resources) {
+			return true
+		}
+	}
+	return false
+}
+
+// poolFindings reports the node-agent resources of each node pool.
+func poolFindings(pools []common.NodePoolSizing, status common.CheckStatus, reason string) []common.Finding {
+	findings := make([]common.Finding, 0, len(pools))
+	for _, p := range pools {
+		name := p.Pool
+		if name == "" {
+			name = "(no pool)"
+		}
+		var resources []string
+		for _, key := range sortedKeys(p.Resources) {
+			resources = append(resources, fmt.Sprintf("%s %s", key, p.Resources[key]))
+		}
+		findings = append(findings, common.Finding{
+			Kind:   "NodePool",
+			Name:   name,
+			Status: status,
+			Reason: reason,
+			Message: fmt.Sprintf("%d nodes, largest %dm CPU / %dMi: node-agent %s",
+				p.Nodes, p.MaxNodeCPUCapacity, p.MaxNodeMemoryMB, strings.Join(resources, ", ")),
+		})
+	}
+	return findings
+}
@@ -0,0 +1,106 @@
+package sizing
+
+import (
+	"context"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/kubescape/sizing-checker/pkg/common"
+	"github.com/kubescape/sizing-checker/pkg/testutil"
+)
+
+func TestSizeNodePools(t *testing.T) {
+	inPool := func(name, cpu, mem, label, pool string) corev1.Node {
+		return *testutil.Node(name, cpu, mem, testutil.WithLabels(map[string]string{label: pool}))
+	}
+	const gke = "cloud.google.com/gke-nodepool"
+
+	tests := []struct {
+		name         string
+		nodes        []corev1.Node
+		poolLabel    string
+		excluded     []string
+		wantPools    map[string]string // pool -> node-agent cpuReq
+		wantMultiple bool
+		wantMismatch bool
+	}{
+		{
+			name:  "single pool",
+			nodes: []corev1.Node{inPool("a", "2", "4Gi", gke, "default"), inPool("b", "32", "128Gi", gke, "default")},
+		},
+		{
+			name:         "pools of different sizes",
+			nodes:        []corev1.Node{inPool("a", "2", "4Gi", gke, "small"), inPool("b", "2", "4Gi", gke, "small"), inPool("c", "32", "128Gi", gke, "big")},
+			wantPools:    map[string]string{"small": "100m", "big": "800m"},
+			wantMultiple: true,
+		},
+		{
+			name:      "pools of the same size",
+			nodes:     []corev1.Node{inPool("a", "2", "4Gi", gke, "one"), inPool("b", "2", "4Gi", gke, "two")},
+			wantPools: map[string]string{"one": "100m", "two": "100m"},
+		},
+		{
+			name:         "unlabelled nodes",
+			nodes:        []corev1.Node{*testutil.Node("a", "2", "4Gi"), inPool("c", "32", "128Gi", gke, "big")},
+			wantPools:    map[string]string{"": "100m", "big": "800m"},
+			wantMismatch: true,
+		},
+		{
+			name:         "custom pool label",
+			nodes:        []corev1.Node{inPool("a", "2", "4Gi", "example.com/tier", "edge"), inPool("c", "16", "64Gi", "example.com/tier", "core")},
+			poolLabel:    "example.com/tier",
+			wantPools:    map[string]string{"edge": "100m", "core": "400m"},
+			wantMultiple: true,
+		},
+		{
+			name: "excluded pools get no DaemonSet",
+			nodes: []corev1.Node{inPool("a", "2", "4Gi", gke, "small"), inPool("c", "16", "64Gi", gke, "big"),
+				inPool("g", "32", "128Gi", gke, "gpu")},
+			excluded:     []string{"gpu"},
+			wantPools:    map[string]string{"small": "100m", "big": "400m"},
+			wantMultiple: true,
+		},
+		{
+			name: "windows nodes are skipped",
+			nodes: []corev1.Node{inPool("a", "2", "4Gi", gke, "linux"), *testutil.Node("w", "32", "128Gi",
+				testutil.WithOS("windows", "amd64"), testutil.WithLabels(map[string]string{gke: "win"}))},
+		},
+		{
+			name: "unsupported architectures are skipped",
+			nodes: []corev1.Node{inPool("a", "2", "4Gi", gke, "x86"), *testutil.Node("z", "32", "128Gi",
+				testutil.WithOS("linux", "s390x"), testutil.WithLabels(map[string]string{gke: "mainframe"}))},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cd := &common.ClusterData{Nodes: tt.nodes}
+			res := RunSizingChecker(cd, nil, tt.poolLabel, tt.excluded)
+			if len(res.NodeAgentPools) != len(tt.wantPools) {
+				t.Fatalf("NodeAgentPools = %+v, want %d pools", res.NodeAgentPools, len(tt.wantPools))
+			}
+			for _, p := range res.NodeAgentPools {
+				if want, ok := tt.wantPools[p.Pool]; !ok || p.Resources["cpuReq"] != want {
+					t.Errorf("pool %q: cpuReq = %s, want %s", p.Pool, p.Resources["cpuReq"], want)
+				}
+			}
+			if res.MultipleNodeAgentDaemonSets != tt.wantMultiple {
+				t.Errorf("MultipleNodeAgentDaemonSets = %v, want %v", res.MultipleNodeAgentDaemonSets, tt.wantMultiple)
+			}
+
+			check := Check{}.Run(context.Background(), &common.CheckEnv{ClusterData: cd, NodePoolLabel: tt.poolLabel, ExcludedNodePools: tt.excluded})
+			if mismatch := check.Reason == ReasonPoolSizingMismatch || hasReason(check, ReasonPoolSizingMismatch); mismatch != tt.wantMismatch {
+				t.Errorf("Run() reason %q, message %q: mismatch = %v, want %v", check.Reason, check.Message, mismatch, tt.wantMismatch)
+			}
+		})
+	}
+}
+
+func hasReason(res *common.CheckResult, reason string) bool {
+	for _, f := range res.Findings {
+		if f.Reason == reason {
+			return true
+		}
+	}
+	return false
+}
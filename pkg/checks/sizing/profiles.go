@@ -0,0 +1,207 @@
+package sizing
+
+import (
+	_ "embed"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"golang.org/x/mod/semver"
+	sigsyaml "sigs.k8s.io/yaml"
+
+	"github.com/kubescape/sizing-checker/pkg/common"
+)
+
+//go:embed sizing_profiles.yaml
+var embeddedProfiles []byte
+
+// resourceKeys are the resources a component profile may size.
+var resourceKeys = map[string]bool{"cpuReq": true, "cpuLim": true, "memReq": true, "memLim": true}
+
+type profileFile struct {
+	Profiles []common.SizingProfile `json:"profiles"`
+}
+
+// LoadProfile returns the sizing profile for a Kubescape chart version: the
+// newest profile whose chartVersion is not newer than chartVersion, or the
+// newest profile when chartVersion is empty. The embedded profiles are
+// extended by those of the file at path, if set; a profile of the file
+// replaces an embedded one of the same chart version. Every profile inherits
+// what it does not change from the next older one.
+func LoadProfile(path, chartVersion string) (*common.SizingProfile, error) {
+	profiles, err := parseProfiles(embeddedProfiles)
+	if err == nil {
+		profiles, err = inheritProfiles(profiles, nil)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("embedded sizing profiles: %w", err)
+	}
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("could not read the sizing profiles: %w", err)
+		}
+		custom, err := parseProfiles(data)
+		if err == nil {
+			custom, err = inheritProfiles(custom, profiles)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		profiles = mergeProfiles(profiles, custom)
+	}
+	return selectProfile(profiles, chartVersion)
+}
+
+// DefaultProfile returns the newest embedded sizing profile.
+func DefaultProfile() *common.SizingProfile {
+	profile, err := LoadProfile("", "")
+	if err != nil {
+		panic(err) // the embedded profiles are covered by the tests
+	}
+	return profile
+}
+
+// parseProfiles parses and validates a sizing profiles file.
+func parseProfiles(data []byte) ([]common.SizingProfile, error) {
+	var file profileFile
+	if err := sigsyaml.UnmarshalStrict(data, &file); err != nil {
+		return nil, fmt.Errorf("invalid sizing profiles: %w", err)
+	}
+	if len(file.Profiles) == 0 {
+		return nil, fmt.Errorf("no sizing profiles defined")
+	}
+	for _, p := range file.Profiles {
+		if !semver.IsValid(chartSemver(p.ChartVersion)) {
+			return nil, fmt.Errorf("sizing profile %q: chartVersion is not a semantic version", p.ChartVersion)
+		}
+		for comp, cp := range p.Components {
+			for key, val := range cp.Defaults {
+				if !resourceKeys[key] {
+					return nil, fmt.Errorf("sizing profile %s: %s: unknown resource %q", p.ChartVersion, comp, key)
+				}
+				if _, err := parseResource(val); err != nil {
+					return nil, fmt.Errorf("sizing profile %s: %s.%s: %w", p.ChartVersion, comp, key, err)
+				}
+			}
+		}
+	}
+	return file.Profiles, nil
+}
+
+// inheritProfiles completes each profile with the components of the next
+// older one, among base and profiles, that it neither sizes nor lists as
+// removed. A component sized by both is merged resource by resource, so a
+// profile only needs to hold what changed.
+func inheritProfiles(profiles, base []common.SizingProfile) ([]common.SizingProfile, error) {
+	sorted := append([]common.SizingProfile(nil), profiles...)
+	sort.Slice(sorted, func(i, j int) bool {
+		// oldest first, so that every parent is complete
+		return semver.Compare(chartSemver(sorted[i].ChartVersion), chartSemver(sorted[j].ChartVersion)) < 0
+	})
+
+	resolved := make([]common.SizingProfile, 0, len(sorted))
+	for _, p := range sorted {
+		parent := olderProfile(append(append([]common.SizingProfile(nil), base...), resolved...), p.ChartVersion)
+		components := map[string]common.ComponentProfile{}
+		if parent != nil {
+			for comp, cp := range parent.Components {
+				components[comp] = cp
+			}
+		}
+		for _, comp := range p.Removed {
+			if _, ok := components[comp]; !ok {
+				return nil, fmt.Errorf("sizing profile %s: removes %s, which no older profile sizes", p.ChartVersion, comp)
+			}
+			delete(components, comp)
+		}
+		for comp, cp := range p.Components {
+			inherited := components[comp]
+			components[comp] = common.ComponentProfile{
+				Defaults: mergeMaps(inherited.Defaults, cp.Defaults),
+				Formulas: mergeMaps(inherited.Formulas, cp.Formulas),
+			}
+		}
+		for comp, cp := range components {
+			for key := range cp.Formulas {
+				if _, ok := cp.Defaults[key]; !ok {
+					return nil, fmt.Errorf("sizing profile %s: %s: formula %q has no default", p.ChartVersion, comp, key)
+				}
+			}
+		}
+		p.Components = components
+		resolved = append(resolved, p)
+	}
+	return resolved, nil
+}
+
+// olderProfile returns the newest of profiles older than chartVersion, or nil.
+func olderProfile(profiles []common.SizingProfile, chartVersion string) *common.SizingProfile {
+	var older *common.SizingProfile
+	for i := range profiles {
+		v := chartSemver(profiles[i].ChartVersion)
+		if semver.Compare(v, chartSemver(chartVersion)) < 0 &&
+			(older == nil || semver.Compare(v, chartSemver(older.ChartVersion)) > 0) {
+			older = &profiles[i]
+		}
+	}
+	return older
+}
+
+// mergeMaps returns a copy of base with the entries of override set.
+func mergeMaps[V any](base, override map[string]V) map[string]V {
+	if len(base) == 0 && len(override) == 0 {
+		return nil
+	}
+	merged := make(map[string]V, len(base)+len(override))
+	for k, v := range base {
+		merged[k] = v
+	}
+	for k, v := range override {
+		merged[k] = v
+	}
+	return merged
+}
+
+// mergeProfiles adds the custom profiles to base, replacing those of the same
+// chart version.
+func mergeProfiles(base, custom []common.SizingProfile) []common.SizingProfile {
+	merged := map[string]common.SizingProfile{}
+	for _, p := range append(base, custom...) {
+		merged[semver.Canonical(chartSemver(p.ChartVersion))] = p
+	}
+	profiles := make([]common.SizingProfile, 0, len(merged))
+	for _, p := range merged {
+		profiles = append(profiles, p)
+	}
+	return profiles
+}
+
+// selectProfile picks the profile applying to chartVersion.
+func selectProfile(profiles []common.SizingProfile, chartVersion string) (*common.SizingProfile, error) {
+	sort.Slice(profiles, func(i, j int) bool {
+		// newest first
+		return semver.Compare(chartSemver(profiles[i].ChartVersion), chartSemver(profiles[j].ChartVersion)) > 0
+	})
+	if chartVersion == "" {
+		return &profiles[0], nil
+	}
+	v := chartSemver(chartVersion)
+	if !semver.IsValid(v) {
+		return nil, fmt.Errorf("invalid chart version %q: not a semantic version", chartVersion)
+	}
+	for i := range profiles {
+		if semver.Compare(v, chartSemver(profiles[i].ChartVersion)) >= 0 {
+			return &profiles[i], nil
+		}
+	}
+	return nil, fmt.Errorf("no sizing profile for chart version %s (the oldest is %s)",
+		chartVersion, profiles[len(profiles)-1].ChartVersion)
+}
+
+// chartSemver returns a chart version in the "v"-prefixed form of the semver
+// package; Helm chart versions usually come without the prefix.
+func chartSemver(v string) string {
+	return "v" + strings.TrimPrefix(v, "v")
+}
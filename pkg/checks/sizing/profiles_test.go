@@ -0,0 +1,182 @@
+package sizing
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/kubescape/sizing-checker/pkg/common"
+	"github.com/kubescape/sizing-checker/pkg/testutil"
+)
+
+const customProfiles = `profiles:
+  - chartVersion: "1.20.0"
+    components:
+      nodeAgent:
+        defaults: {cpuReq: 150m, memReq: 256Mi}
+        formulas:
+          cpuReq: {nodeCPUFraction: 0.05}
+  - chartVersion: "v1.24"
+    components:
+      nodeAgent:
+        defaults: {cpuReq: 200m, memReq: 300Mi}
+`
+
+func TestLoadProfile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "profiles.yaml")
+	if err := os.WriteFile(path, []byte(customProfiles), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name         string
+		path         string
+		chartVersion string
+		want         string
+		wantErr      string
+	}{
+		{name: "embedded newest", want: "1.25.0"},
+		{name: "embedded for a newer release", chartVersion: "1.25.3", want: "1.25.0"},
+		{name: "embedded in between", chartVersion: "1.24.1", want: "1.22.0"},
+		{name: "embedded for an older release", chartVersion: "1.18.2", want: "1.16.0"},
+		{name: "embedded pre-release", chartVersion: "1.22.0-rc.1", want: "1.16.0"},
+		{name: "embedded newest with custom", path: path, want: "1.25.0"},
+		{name: "custom newest", path: path, chartVersion: "1.24.5", want: "v1.24"},
+		{name: "custom exact", path: path, chartVersion: "1.20.0", want: "1.20.0"},
+		{name: "custom in between", path: path, chartVersion: "v1.21.4", want: "1.20.0"},
+		{name: "embedded between custom", path: path, chartVersion: "1.23.9", want: "1.22.0"},
+		{name: "falls back to embedded", path: path, chartVersion: "1.19.2", want: "1.16.0"},
+		{name: "too old", chartVersion: "1.15.9", wantErr: "no sizing profile for chart version 1.15.9"},
+		{name: "invalid version", chartVersion: "latest", wantErr: "invalid chart version"},
+		{name: "not semver", chartVersion: "1.22.0.1", wantErr: "invalid chart version"},
+		{name: "missing file", path: filepath.Join(t.TempDir(), "missing.yaml"), wantErr: "could not read"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			profile, err := LoadProfile(tt.path, tt.chartVersion)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("LoadProfile() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if profile.ChartVersion != tt.want {
+				t.Errorf("LoadProfile() = profile %s, want %s", profile.ChartVersion, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadProfileReleases(t *testing.T) {
+	older, err := LoadProfile("", "1.18.2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	newer, err := LoadProfile("", "1.25.3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if older.ChartVersion == newer.ChartVersion {
+		t.Fatalf("chart versions 1.18.2 and 1.25.3 both select profile %s", older.ChartVersion)
+	}
+	// The releases ship different node-agent defaults
+	oldLim := older.Components["nodeAgent"].Defaults["memLim"]
+	newLim := newer.Components["nodeAgent"].Defaults["memLim"]
+	if oldLim != "700Mi" || newLim != "1400Mi" {
+		t.Errorf("node-agent memLim = %s and %s, want 700Mi and 1400Mi", oldLim, newLim)
+	}
+	// Only the releases that ship the kollector size it
+	if _, ok := older.Components["kollector"]; !ok {
+		t.Errorf("profile %s does not size the kollector", older.ChartVersion)
+	}
+	if _, ok := newer.Components["kollector"]; ok {
+		t.Errorf("profile %s sizes the kollector", newer.ChartVersion)
+	}
+	if _, ok := newer.Components["synchronizer"]; !ok {
+		t.Errorf("profile %s does not size the synchronizer", newer.ChartVersion)
+	}
+}
+
+func TestParseProfilesInvalid(t *testing.T) {
+	tests := map[string]string{
+		"no profiles":     "profiles: []\n",
+		"unknown field":   "profiles:\n  - chartVersion: \"1.0\"\n    component: {}\n",
+		"bad version":     "profiles:\n  - chartVersion: next\n",
+		"not semver":      "profiles:\n  - chartVersion: \"1.22.0.1\"\n",
+		"unknown key":     "profiles:\n  - chartVersion: \"1.0\"\n    components:\n      storage:\n        defaults: {diskReq: 1Gi}\n",
+		"bad quantity":    "profiles:\n  - chartVersion: \"1.0\"\n    components:\n      storage:\n        defaults: {memReq: lots}\n",
+		"formula default": "profiles:\n  - chartVersion: \"1.0\"\n    components:\n      storage:\n        defaults: {memReq: 1Gi}\n        formulas:\n          memLim: {perResource: 1}\n",
+		"removed unknown": "profiles:\n  - chartVersion: \"1.0\"\n    removed: [kollector]\n",
+	}
+	for name, data := range tests {
+		profiles, err := parseProfiles([]byte(data))
+		if err == nil {
+			_, err = inheritProfiles(profiles, nil)
+		}
+		if err == nil {
+			t.Errorf("%s: parsing the profiles succeeded, want an error", name)
+		}
+	}
+}
+
+func TestLoadProfileInherits(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "profiles.yaml")
+	if err := os.WriteFile(path, []byte(customProfiles), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	profile, err := LoadProfile(path, "1.20.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The custom profile overrides two node-agent defaults and one formula
+	// of 1.16.0, and inherits everything else
+	nodeAgent := profile.Components["nodeAgent"]
+	wantDefaults := map[string]string{"cpuReq": "150m", "cpuLim": "500m", "memReq": "256Mi", "memLim": "700Mi"}
+	if !reflect.DeepEqual(nodeAgent.Defaults, wantDefaults) {
+		t.Errorf("nodeAgent defaults = %v, want %v", nodeAgent.Defaults, wantDefaults)
+	}
+	if f := nodeAgent.Formulas["cpuReq"]; f.NodeCPUFraction != 0.05 {
+		t.Errorf("nodeAgent.cpuReq formula = %+v, want nodeCPUFraction 0.05", f)
+	}
+	if f := nodeAgent.Formulas["memLim"]; f.NodeMemoryFraction != 0.10 {
+		t.Errorf("nodeAgent.memLim formula = %+v, want the inherited nodeMemoryFraction 0.10", f)
+	}
+	for _, comp := range []string{"storage", "kubevuln", "kollector"} {
+		if _, ok := profile.Components[comp]; !ok {
+			t.Errorf("profile %s does not inherit %s", profile.ChartVersion, comp)
+		}
+	}
+
+	// The embedded profiles do not inherit from the custom ones
+	embedded, err := LoadProfile(path, "1.22.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := embedded.Components["nodeAgent"].Defaults["cpuReq"]; got != "100m" {
+		t.Errorf("profile 1.22.0 nodeAgent.cpuReq = %s, want 100m", got)
+	}
+}
+
+func TestRunSizingCheckerProfile(t *testing.T) {
+	profile, err := parseProfiles([]byte(customProfiles))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cd := &common.ClusterData{Nodes: []corev1.Node{*testutil.Node("n1", "8", "32Gi")}}
+	res := RunSizingChecker(cd, &profile[0], "", nil)
+	if res.ProfileVersion != "1.20.0" {
+		t.Errorf("ProfileVersion = %s, want 1.20.0", res.ProfileVersion)
+	}
+	// 5% of 8 CPUs, memory has no formula and keeps its default
+	want := map[string]map[string]string{"nodeAgent": {"cpuReq": "400m", "memReq": "256Mi"}}
+	if got := res.FinalResourceAllocations; !reflect.DeepEqual(got, want) {
+		t.Errorf("FinalResourceAllocations = %v, want %v", got, want)
+	}
+}
@@ -2,73 +2,85 @@ package sizing
 
 import (
 	"fmt"
-	"strconv"
+	"math"
 	"strings"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+
+	"github.com/kubescape/sizing-checker/pkg/common"
 )
 
-var defaultResourceAllocations = map[string]map[string]string{
-	"nodeAgent": {
-		"cpuReq": "100m",
-		"cpuLim": "500m",
-		"memReq": "180Mi",
-		"memLim": "700Mi",
-	},
-	"storage": {
-		"memReq": "400Mi",
-		"memLim": "1500Mi",
-	},
-	"kubevuln": {
-		"memReq": "1000Mi",
-		"memLim": "5000Mi",
-	},
-}
+const mebibyte = 1024 * 1024
 
-func calculateNodeAgentCPU(nodeCPUMilli int) (string, string) {
-	req := float64(nodeCPUMilli) * 0.025
-	lim := float64(nodeCPUMilli) * 0.10
-	return fmt.Sprintf("%.0fm", req), fmt.Sprintf("%.0fm", lim)
+// sizingInputs are the cluster figures the sizing formulas scale with.
+type sizingInputs struct {
+	nodeCPUMilli    int
+	nodeMemMiB      int
+	resources       int
+	nodes           int
+	largestImageMiB int
 }
 
-func calculateNodeAgentMemory(nodeMemMB int) (string, string) {
-	req := float64(nodeMemMB) * 0.025
-	lim := float64(nodeMemMB) * 0.10
-	return fmt.Sprintf("%.0fMi", req), fmt.Sprintf("%.0fMi", lim)
+// evaluate computes a resource of the formula, in millicores for the CPU
+// resources (cpuReq, cpuLim) and MiB for the memory ones.
+func evaluate(f common.SizingFormula, key string, in sizingInputs) resource.Quantity {
+	v := f.Base +
+		f.NodeCPUFraction*float64(in.nodeCPUMilli) +
+		f.NodeMemoryFraction*float64(in.nodeMemMiB) +
+		f.PerResource*float64(in.resources) +
+		f.PerNode*float64(in.nodes) +
+		f.PerImageMiB*float64(in.largestImageMiB)
+	if strings.HasPrefix(key, "cpu") {
+		return milliCPU(v)
+	}
+	return mebibytes(v)
 }
 
-func calculateStorageMemory(total int) (string, string) {
-	r := float64(total) * 0.2
-	l := float64(total) * 0.8
-	return fmt.Sprintf("%.0fMi", r), fmt.Sprintf("%.0fMi", l)
+// milliCPU returns a CPU quantity, rounded to the nearest millicore.
+func milliCPU(milli float64) resource.Quantity {
+	return *resource.NewMilliQuantity(int64(math.Round(milli)), resource.DecimalSI)
 }
 
-func calculateKubevulnMemory(largestImgMB int) (string, string) {
-	limit := float64(largestImgMB) + 400.0
-	req := limit / 4.0
-	return fmt.Sprintf("%.0fMi", req), fmt.Sprintf("%.0fMi", limit)
+// mebibytes returns a memory quantity, rounded to the nearest MiB.
+func mebibytes(mib float64) resource.Quantity {
+	return *resource.NewQuantity(int64(math.Round(mib))*mebibyte, resource.BinarySI)
 }
 
-func compareAndChoose(defaultVal, recommendedVal string) string {
-	defVal, defUnit := parseResource(defaultVal)
-	recVal, recUnit := parseResource(recommendedVal)
-	if defUnit != recUnit {
+// compareAndChoose returns the recommended value, in its canonical unit, when
+// it is larger than the default, and the default value unchanged otherwise.
+// Values are compared as quantities, so "2Gi" is larger than "1500Mi" and "1"
+// CPU larger than "500m". A default that does not parse is kept.
+func compareAndChoose(defaultVal string, recommended resource.Quantity) string {
+	def, err := parseResource(defaultVal)
+	if err != nil || recommended.Cmp(def) <= 0 {
 		return defaultVal
 	}
-	if recVal > defVal {
-		return recommendedVal
+	return formatResource(recommended)
+}
+
+// parseResource parses a Kubernetes quantity such as "500m", "1", "1500Mi" or "2Gi".
+func parseResource(val string) (resource.Quantity, error) {
+	q, err := resource.ParseQuantity(val)
+	if err != nil {
+		return resource.Quantity{}, fmt.Errorf("invalid resource quantity %q: %w", val, err)
 	}
-	return defaultVal
+	return q, nil
 }
 
-func parseResource(val string) (float64, string) {
-	if strings.HasSuffix(val, "m") {
-		num := strings.TrimSuffix(val, "m")
-		f, _ := strconv.ParseFloat(num, 64)
-		return f, "m"
-	} else if strings.HasSuffix(val, "Mi") {
-		num := strings.TrimSuffix(val, "Mi")
-		f, _ := strconv.ParseFloat(num, 64)
-		return f, "Mi"
+// formatResource renders a quantity in its canonical unit: whole GiB ("2Gi")
+// or MiB ("1500Mi"), rounded up, for memory, which the calculations above
+// create in BinarySI format, and whole CPUs ("2") or millicores ("1500m") for CPU.
+func formatResource(q resource.Quantity) string {
+	if q.Format == resource.BinarySI {
+		mib := (q.Value() + mebibyte - 1) / mebibyte
+		if mib >= 1024 && mib%1024 == 0 {
+			return fmt.Sprintf("%dGi", mib/1024)
+		}
+		return fmt.Sprintf("%dMi", mib)
+	}
+	milli := q.MilliValue()
+	if milli%1000 == 0 {
+		return fmt.Sprintf("%d", milli/1000)
 	}
-	f, _ := strconv.ParseFloat(val, 64)
-	return f, ""
+	return fmt.Sprintf("%dm", milli)
 }
@@ -1,43 +1,87 @@
 package sizing
 
 import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
 	"github.com/kubescape/sizing-checker/pkg/common"
 )
 
-func RunSizingChecker(data *common.ClusterData) *common.SizingResult {
+// Reason codes reported by the sizing check.
+const (
+	// ReasonAdjustmentsRecommended is reported when the recommended allocations differ from the chart defaults.
+	ReasonAdjustmentsRecommended = "AdjustmentsRecommended"
+	// ReasonIncompleteInputs is reported when some sizing inputs could not be collected.
+	ReasonIncompleteInputs = "IncompleteInputs"
+	// ReasonPoolSizingMismatch is reported when the node pools need different
+	// node-agent resources that cannot be deployed per pool.
+	ReasonPoolSizingMismatch = "PoolSizingMismatch"
+)
+
+// countedResources are the kinds whose object counts drive the storage sizing.
+var countedResources = []string{
+	common.ResourcePods, common.ResourceServices, common.ResourceDeployments, common.ResourceReplicaSets,
+	common.ResourceStatefulSets, common.ResourceDaemonSets, common.ResourceJobs, common.ResourceCronJobs,
+}
+
+// RunSizingChecker computes the recommended resources of the components of
+// the sizing profile (the newest embedded one when profile is nil), and of
+// the node-agent per node pool (grouped by poolLabel, if set, or else the
+// well-known pool labels), leaving out the pools listed in excludedPools.
+func RunSizingChecker(data *common.ClusterData, profile *common.SizingProfile, poolLabel string, excludedPools []string) *common.SizingResult {
+	if profile == nil {
+		profile = DefaultProfile()
+	}
 	totalResources := countAllResources(data)
 	maxCPU, maxMem, largestImageMB := getNodeStats(data)
+	inputs := sizingInputs{
+		nodeCPUMilli:    maxCPU,
+		nodeMemMiB:      maxMem,
+		resources:       totalResources,
+		nodes:           len(data.Nodes),
+		largestImageMiB: largestImageMB,
+	}
+
+	defaultResourceAllocations := map[string]map[string]string{}
+	finalResourceAllocations := map[string]map[string]string{}
+	for comp, cp := range profile.Components {
+		defaultResourceAllocations[comp] = map[string]string{}
+		for key, def := range cp.Defaults {
+			defaultResourceAllocations[comp][key] = def
+		}
+		finalResourceAllocations[comp] = allocate(cp, inputs)
+	}
+	var pools []common.NodePoolSizing
+	var multiple bool
+	if cp, ok := profile.Components[nodeAgentComponent]; ok {
+		pools, multiple = sizeNodePools(data, cp, inputs, poolLabel, excludedPools)
+	}
 
-	recNodeAgentCPUReq, recNodeAgentCPULim := calculateNodeAgentCPU(maxCPU)
-	recNodeAgentMemReq, recNodeAgentMemLim := calculateNodeAgentMemory(maxMem)
-	recStorageMemReq, recStorageMemLim := calculateStorageMemory(totalResources)
-	recKubevulnMemReq, recKubevulnMemLim := calculateKubevulnMemory(largestImageMB)
-
-	finalResourceAllocations := map[string]map[string]string{
-		"nodeAgent": {
-			"cpuReq": compareAndChoose(defaultResourceAllocations["nodeAgent"]["cpuReq"], recNodeAgentCPUReq),
-			"cpuLim": compareAndChoose(defaultResourceAllocations["nodeAgent"]["cpuLim"], recNodeAgentCPULim),
-			"memReq": compareAndChoose(defaultResourceAllocations["nodeAgent"]["memReq"], recNodeAgentMemReq),
-			"memLim": compareAndChoose(defaultResourceAllocations["nodeAgent"]["memLim"], recNodeAgentMemLim),
-		},
-		"storage": {
-			"memReq": compareAndChoose(defaultResourceAllocations["storage"]["memReq"], recStorageMemReq),
-			"memLim": compareAndChoose(defaultResourceAllocations["storage"]["memLim"], recStorageMemLim),
-		},
-		"kubevuln": {
-			"memReq": compareAndChoose(defaultResourceAllocations["kubevuln"]["memReq"], recKubevulnMemReq),
-			"memLim": compareAndChoose(defaultResourceAllocations["kubevuln"]["memLim"], recKubevulnMemLim),
-		},
+	missingNodes := data.MissingResources(common.ResourceNodes)
+	missingCounts := data.MissingResources(countedResources...)
+	confidence := common.SizingConfidenceHigh
+	switch {
+	case len(missingNodes) > 0:
+		confidence = common.SizingConfidenceLow
+	case len(missingCounts) > 0:
+		confidence = common.SizingConfidenceMedium
 	}
 
 	return &common.SizingResult{
-		TotalResources:             totalResources,
-		MaxNodeCPUCapacity:         maxCPU,
-		MaxNodeMemoryMB:            maxMem,
-		LargestContainerImageMB:    largestImageMB,
-		DefaultResourceAllocations: defaultResourceAllocations,
-		FinalResourceAllocations:   finalResourceAllocations,
-		HasSizingAdjustments:       computeHasSizingAdjustments(defaultResourceAllocations, finalResourceAllocations),
+		ProfileVersion:              profile.ChartVersion,
+		Confidence:                  confidence,
+		MissingInputs:               append(missingNodes, missingCounts...),
+		TotalResources:              totalResources,
+		MaxNodeCPUCapacity:          maxCPU,
+		MaxNodeMemoryMB:             maxMem,
+		LargestContainerImageMB:     largestImageMB,
+		DefaultResourceAllocations:  defaultResourceAllocations,
+		FinalResourceAllocations:    finalResourceAllocations,
+		HasSizingAdjustments:        multiple || computeHasSizingAdjustments(defaultResourceAllocations, finalResourceAllocations),
+		NodeAgentPools:              pools,
+		MultipleNodeAgentDaemonSets: multiple,
 	}
 }
 
@@ -50,10 +94,19 @@ func countAllResources(cd *common.ClusterData) int {
 		len(cd.Jobs) + len(cd.CronJobs)
 }
 
-// parse node stats from clusterData
+// parse node stats from clusterData; only the nodes the node-agent can run on
+// count for the largest node, all of them for the largest image
 func getNodeStats(cd *common.ClusterData) (int, int, int) {
 	var maxCPU, maxMem, largestImageBytes int64
 	for _, node := range cd.Nodes {
+		for _, image := range node.Status.Images {
+			if image.SizeBytes > largestImageBytes {
+				largestImageBytes = image.SizeBytes
+			}
+		}
+		if !common.NodeAgentSupported(&node) {
+			continue
+		}
 		cpuQuantity := node.Status.Capacity.Cpu()
 		memQuantity := node.Status.Capacity.Memory()
 		cpuMilli := cpuQuantity.MilliValue()
@@ -65,11 +118,6 @@ func getNodeStats(cd *common.ClusterData) (int, int, int) {
 		if memMB > maxMem {
 			maxMem = memMB
 		}
-		for _, image := range node.Status.Images {
-			if image.SizeBytes > largestImageBytes {
-				largestImageBytes = image.SizeBytes
-			}
-		}
 	}
 	return int(maxCPU), int(maxMem), int(largestImageBytes / (1024 * 1024))
 }
@@ -93,3 +141,86 @@ func computeHasSizingAdjustments(defaults, finals map[string]map[string]string)
 	}
 	return false
 }
+
+// Check exposes the sizing checker through the common.Check interface.
+type Check struct{}
+
+func (Check) Name() string        { return common.SizingCheckName }
+func (Check) Description() string { return "Sizing Check" }
+
+func (Check) Run(ctx context.Context, env *common.CheckEnv) *common.CheckResult {
+	res := env.Sizing
+	if res == nil {
+		res = RunSizingChecker(env.ClusterData, env.SizingProfile, env.NodePoolLabel, env.ExcludedNodePools)
+	}
+	result := &common.CheckResult{Status: common.StatusPass, Details: res}
+
+	if len(res.MissingInputs) > 0 {
+		result.Escalate(common.StatusWarn, ReasonIncompleteInputs,
+			fmt.Sprintf("Sizing confidence %s: could not collect %s", res.Confidence, common.DescribeMissing(res.MissingInputs)),
+			"Grant the checker list access to the missing resources (see the ClusterRole in k8s-manifest.yaml) and re-run it.")
+		for _, m := range res.MissingInputs {
+			result.Findings = append(result.Findings, common.Finding{
+				Kind:    "Resource",
+				Name:    m.Kind,
+				Status:  common.StatusWarn,
+				Reason:  ReasonIncompleteInputs,
+				Message: fmt.Sprintf("not collected (%s)", m.Status),
+			})
+		}
+	}
+
+	if res.HasSizingAdjustments {
+		message := "Adjustments recommended"
+		if res.MultipleNodeAgentDaemonSets {
+			message += fmt.Sprintf("; the node-agent is sized per node pool, as %d DaemonSets", len(res.NodeAgentPools))
+		}
+		result.Escalate(common.StatusWarn, ReasonAdjustmentsRecommended, message,
+			"Install Kubescape with the generated recommended-values.yaml.")
+		result.Findings = append(result.Findings, adjustmentFindings(res.DefaultResourceAllocations, res.FinalResourceAllocations)...)
+	}
+	switch {
+	case res.MultipleNodeAgentDaemonSets:
+		result.Findings = append(result.Findings, poolFindings(res.NodeAgentPools, common.StatusWarn, ReasonAdjustmentsRecommended)...)
+	case poolsDiffer(res.NodeAgentPools):
+		// Nodes without a pool label cannot get a DaemonSet of their own
+		result.Escalate(common.StatusWarn, ReasonPoolSizingMismatch,
+			"Node pools need different node-agent resources, but not every node has a pool label, so a single size (for the largest node) is recommended and over-reserves on the smaller nodes",
+			"Label every node with its pool, or pass the label with --node-pool-label, to size the node-agent per pool.")
+		result.Findings = append(result.Findings, poolFindings(res.NodeAgentPools, common.StatusWarn, ReasonPoolSizingMismatch)...)
+	}
+	return result
+}
+
+// adjustmentFindings reports one finding per component whose allocations differ from the defaults.
+func adjustmentFindings(defaults, finals map[string]map[string]string) []common.Finding {
+	var findings []common.Finding
+	for _, comp := range sortedKeys(finals) {
+		var changes []string
+		for _, resKey := range sortedKeys(finals[comp]) {
+			defVal, finalVal := defaults[comp][resKey], finals[comp][resKey]
+			if defVal != finalVal {
+				changes = append(changes, fmt.Sprintf("%s %s -> %s", resKey, defVal, finalVal))
+			}
+		}
+		if len(changes) > 0 {
+			findings = append(findings, common.Finding{
+				Kind:    "Component",
+				Name:    comp,
+				Status:  common.StatusWarn,
+				Reason:  ReasonAdjustmentsRecommended,
+				Message: strings.Join(changes, ", "),
+			})
+		}
+	}
+	return findings
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,174 @@
+package sizing
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+
+	"github.com/kubescape/sizing-checker/pkg/common"
+	"github.com/kubescape/sizing-checker/pkg/testutil"
+)
+
+func TestGetNodeStats(t *testing.T) {
+	cd := &common.ClusterData{Nodes: []corev1.Node{
+		*testutil.Node("small", "2", "4Gi", testutil.WithImage("nginx", 200*1024*1024)),
+		*testutil.Node("large", "16", "64Gi", testutil.WithImage("app", 1500*1024*1024)),
+		*testutil.Node("fractional", "1500m", "512Mi"),
+		// No node-agent on Windows, but its images still count
+		*testutil.Node("win", "64", "256Gi", testutil.WithOS("windows", "amd64"), testutil.WithImage("servercore", 5000*1024*1024)),
+	}}
+
+	maxCPU, maxMem, largestImageMB := getNodeStats(cd)
+	if maxCPU != 16000 {
+		t.Errorf("maxCPU = %d, want 16000", maxCPU)
+	}
+	if maxMem != 65536 {
+		t.Errorf("maxMem = %d, want 65536", maxMem)
+	}
+	if largestImageMB != 5000 {
+		t.Errorf("largestImageMB = %d, want 5000", largestImageMB)
+	}
+}
+
+func TestGetNodeStatsNoNodes(t *testing.T) {
+	maxCPU, maxMem, largestImageMB := getNodeStats(&common.ClusterData{})
+	if maxCPU != 0 || maxMem != 0 || largestImageMB != 0 {
+		t.Errorf("getNodeStats() = %d, %d, %d, want zeros", maxCPU, maxMem, largestImageMB)
+	}
+}
+
+func TestRunSizingChecker(t *testing.T) {
+	t.Run("small cluster keeps defaults", func(t *testing.T) {
+		cd := &common.ClusterData{Nodes: []corev1.Node{*testutil.Node("n1", "2", "4Gi")}}
+		res := RunSizingChecker(cd, nil, "", nil)
+		if res.HasSizingAdjustments {
+			t.Errorf("unexpected adjustments: %v", res.FinalResourceAllocations)
+		}
+	})
+
+	t.Run("large nodes raise node-agent resources", func(t *testing.T) {
+		cd := &common.ClusterData{Nodes: []corev1.Node{*testutil.Node("n1", "32", "128Gi")}}
+		res := RunSizingChecker(cd, nil, "", nil)
+		if !res.HasSizingAdjustments {
+			t.Fatal("expected sizing adjustments for a 32 CPU / 128Gi node")
+		}
+		nodeAgent := res.FinalResourceAllocations["nodeAgent"]
+		want := map[string]string{"cpuReq": "800m", "cpuLim": "3200m", "memReq": "3277Mi", "memLim": "13107Mi"}
+		for k, v := range want {
+			if nodeAgent[k] != v {
+				t.Errorf("nodeAgent.%s = %s, want %s", k, nodeAgent[k], v)
+			}
+		}
+	})
+
+	t.Run("many objects and nodes raise the operator components", func(t *testing.T) {
+		cd := &common.ClusterData{}
+		for i := 0; i < 200; i++ {
+			cd.Nodes = append(cd.Nodes, *testutil.Node(fmt.Sprintf("n%d", i), "2", "4Gi"))
+		}
+		for i := 0; i < 10000; i++ {
+			cd.Pods = append(cd.Pods, *testutil.Pod("default", fmt.Sprintf("p%d", i), "n0", "", ""))
+		}
+		res := RunSizingChecker(cd, nil, "", nil)
+		want := map[string]map[string]string{
+			"kubescape":     {"memReq": "1100Mi", "memLim": "4300Mi"},
+			"synchronizer":  {"memReq": "600Mi", "memLim": "2200Mi"},
+			"gateway":       {"memReq": "25Mi", "memLim": "125Mi"},
+			"otelCollector": {"cpuLim": "2500m", "memReq": "600Mi", "memLim": "2000Mi"},
+		}
+		for comp, resources := range want {
+			for k, v := range resources {
+				if got := res.FinalResourceAllocations[comp][k]; got != v {
+					t.Errorf("%s.%s = %s, want %s", comp, k, got, v)
+				}
+			}
+		}
+	})
+
+	t.Run("large image raises kubevuln memory", func(t *testing.T) {
+		cd := &common.ClusterData{Nodes: []corev1.Node{
+			*testutil.Node("n1", "2", "4Gi", testutil.WithImage("big", 8000*1024*1024)),
+		}}
+		res := RunSizingChecker(cd, nil, "", nil)
+		if got := res.FinalResourceAllocations["kubevuln"]["memLim"]; got != "8400Mi" {
+			t.Errorf("kubevuln.memLim = %s, want 8400Mi", got)
+		}
+	})
+}
+
+func TestCheckRun(t *testing.T) {
+	env := &common.CheckEnv{ClusterData: &common.ClusterData{Nodes: []corev1.Node{*testutil.Node("n1", "32", "128Gi")}}}
+	res := Check{}.Run(context.Background(), env)
+	if res.Status != common.StatusWarn || res.Reason != ReasonAdjustmentsRecommended {
+		t.Errorf("Run() = %s/%s, want Warn/%s", res.Status, res.Reason, ReasonAdjustmentsRecommended)
+	}
+	if _, ok := res.Details.(*common.SizingResult); !ok {
+		t.Errorf("Details = %T, want *common.SizingResult", res.Details)
+	}
+}
+
+func TestCheckRunIncompleteInputs(t *testing.T) {
+	collection := func(kind string, status common.CollectionStatus) *common.CollectionStats {
+		return &common.CollectionStats{Resources: []common.ResourceCollection{
+			{Kind: common.ResourceNodes, Status: common.CollectionOK},
+			{Kind: kind, Status: status},
+		}}
+	}
+	tests := []struct {
+		name           string
+		collection     *common.CollectionStats
+		wantConfidence string
+	}{
+		{"all collected", &common.CollectionStats{}, common.SizingConfidenceHigh},
+		{"dump without statistics", nil, common.SizingConfidenceHigh},
+		{"cronjobs forbidden", collection(common.ResourceCronJobs, common.CollectionForbidden), common.SizingConfidenceMedium},
+		{"nodes timed out", collection(common.ResourceNodes, common.CollectionTimeout), common.SizingConfidenceLow},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cd := &common.ClusterData{Nodes: []corev1.Node{*testutil.Node("n1", "2", "4Gi")}, Collection: tt.collection}
+			res := Check{}.Run(context.Background(), &common.CheckEnv{ClusterData: cd})
+			sr := res.Details.(*common.SizingResult)
+			if sr.Confidence != tt.wantConfidence {
+				t.Errorf("Confidence = %s, want %s", sr.Confidence, tt.wantConfidence)
+			}
+			wantReason := ""
+			if tt.wantConfidence != common.SizingConfidenceHigh {
+				wantReason = ReasonIncompleteInputs
+			}
+			if res.Reason != wantReason {
+				t.Errorf("Reason = %q, want %q", res.Reason, wantReason)
+			}
+		})
+	}
+}
+
+func TestCompareAndChoose(t *testing.T) {
+	tests := []struct {
+		defaultVal  string
+		recommended resource.Quantity
+		want        string
+	}{
+		{"100m", milliCPU(80), "100m"},
+		{"100m", milliCPU(250), "250m"},
+		{"500m", milliCPU(2000), "2"},
+		{"1", milliCPU(500), "1"},
+		{"1", milliCPU(1500), "1500m"},
+		{"180Mi", mebibytes(100), "180Mi"},
+		{"1500Mi", mebibytes(2048), "2Gi"},
+		{"2Gi", mebibytes(1500), "2Gi"},
+		{"1Gi", mebibytes(1500), "1500Mi"},
+		{"2G", mebibytes(1908), "1908Mi"}, // 2G is about 1907.3Mi
+		{"2G", mebibytes(1907), "2G"},
+		{"2Gi", mebibytes(2048), "2Gi"},
+		{"invalid", mebibytes(4096), "invalid"},
+	}
+	for _, tt := range tests {
+		if got := compareAndChoose(tt.defaultVal, tt.recommended); got != tt.want {
+			t.Errorf("compareAndChoose(%q, %s) = %q, want %q", tt.defaultVal, tt.recommended.String(), got, tt.want)
+		}
+	}
+}
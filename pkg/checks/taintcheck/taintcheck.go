@@ -0,0 +1,139 @@
+package taintcheck
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/kubescape/sizing-checker/pkg/common"
+)
+
+// Reason codes reported by the taint check.
+const (
+	ReasonUntoleratedTaints = "UntoleratedTaints"
+	ReasonExcludedPool      = "ExcludedPool"
+	ReasonNodesUnavailable  = "NodesUnavailable"
+)
+
+// RunTaintCheck predicts the nodes the node-agent DaemonSet will not be
+// scheduled on because of their taints, given its default tolerations. It
+// returns the tolerations that give it full coverage as
+// *common.SchedulingAdvice in the result details, except for the taints of the
+// node pools listed in excludedPools, which are left uncovered on purpose.
+// Nodes are grouped into pools by poolLabel (--node-pool-label), then by the
+// well-known provider labels.
+func RunTaintCheck(clusterData *common.ClusterData, poolLabel string, excludedPools []string) *common.CheckResult {
+	res := &common.CheckResult{Status: common.StatusPass}
+	if missing := clusterData.MissingResources(common.ResourceNodes); len(missing) > 0 {
+		res.Escalate(common.StatusWarn, ReasonNodesUnavailable,
+			fmt.Sprintf("Node taints unknown: could not collect %s", common.DescribeMissing(missing)),
+			"Grant the checker list access to nodes (see the ClusterRole in k8s-manifest.yaml).")
+		return res
+	}
+
+	excluded := map[string]bool{}
+	for _, p := range excludedPools {
+		excluded[p] = true
+	}
+
+	advice := &common.SchedulingAdvice{}
+	seen := map[string]bool{} // tolerations already proposed
+	taintCounts := map[string]int{}
+	var uncoveredPools, excludedNodePools []string
+	supported := 0
+	for i := range clusterData.Nodes {
+		node := &clusterData.Nodes[i]
+		if !common.NodeAgentSupported(node) {
+			continue // reported by the node platform check
+		}
+		supported++
+
+		var untolerated []corev1.Taint
+		for _, t := range common.BlockingTaints(node) {
+			if !common.Tolerated(common.NodeAgentTolerations, &t) {
+				untolerated = append(untolerated, t)
+			}
+		}
+		if len(untolerated) == 0 {
+			continue
+		}
+
+		_, pool := common.NodePoolLabel(node, poolLabel)
+		taints := common.DescribeTaints(untolerated)
+		if pool != "" && excluded[pool] {
+			excludedNodePools = append(excludedNodePools, pool)
+			advice.ExcludedPools = common.AppendUnique(advice.ExcludedPools, pool)
+			res.Findings = append(res.Findings, common.Finding{
+				Kind:    "Node",
+				Name:    node.Name,
+				Status:  common.StatusPass,
+				Reason:  ReasonExcludedPool,
+				Message: fmt.Sprintf("node pool %s is excluded on purpose (tainted with %s)", pool, taints),
+			})
+			continue
+		}
+
+		uncoveredPools = append(uncoveredPools, pool)
+		for _, t := range untolerated {
+			taintCounts[t.ToString()]++
+			tol := tolerationFor(t)
+			if key := tol.Key + "=" + tol.Value + ":" + string(tol.Effect); !seen[key] {
+				seen[key] = true
+				advice.Tolerations = append(advice.Tolerations, tol)
+			}
+		}
+		res.Findings = append(res.Findings, common.Finding{
+			Kind:    "Node",
+			Name:    node.Name,
+			Status:  common.StatusWarn,
+			Reason:  ReasonUntoleratedTaints,
+			Message: "the node-agent will not be scheduled: tainted with " + taints,
+		})
+	}
+
+	if len(excludedNodePools) > 0 {
+		sort.Strings(advice.ExcludedPools)
+		res.Details = advice
+	}
+	if len(uncoveredPools) == 0 {
+		res.Message = fmt.Sprintf("The node-agent tolerates the taints of all %d supported nodes", supported)
+		if len(excludedNodePools) > 0 {
+			res.Message += "; excluded on purpose: " + common.DescribePools(excludedNodePools)
+		}
+		return res
+	}
+
+	sort.Slice(advice.Tolerations, func(i, j int) bool {
+		return advice.Tolerations[i].Key < advice.Tolerations[j].Key
+	})
+	res.Details = advice
+	message := fmt.Sprintf("%d of %d nodes have taints the node-agent does not tolerate: %s (pools: %s)",
+		len(uncoveredPools), supported, common.DescribeCounts(taintCounts), common.DescribePools(uncoveredPools))
+	if len(excludedNodePools) > 0 {
+		message += "; excluded on purpose: " + common.DescribePools(excludedNodePools)
+	}
+	res.Escalate(common.StatusWarn, ReasonUntoleratedTaints, message,
+		"Install with recommended-values.yaml, which adds the matching nodeAgent.tolerations, "+
+			"or pass the pools to leave without node-agent to --exclude-node-pools.")
+	return res
+}
+
+// tolerationFor returns the toleration matching exactly one taint.
+func tolerationFor(t corev1.Taint) corev1.Toleration {
+	if t.Value == "" {
+		return corev1.Toleration{Key: t.Key, Operator: corev1.TolerationOpExists, Effect: t.Effect}
+	}
+	return corev1.Toleration{Key: t.Key, Operator: corev1.TolerationOpEqual, Value: t.Value, Effect: t.Effect}
+}
+
+// Check exposes the taint check through the common.Check interface.
+type Check struct{}
+
+func (Check) Name() string        { return common.TaintCheckName }
+func (Check) Description() string { return "Taint and Toleration Check" }
+
+func (Check) Run(ctx context.Context, env *common.CheckEnv) *common.CheckResult {
+	return RunTaintCheck(env.ClusterData, env.NodePoolLabel, env.ExcludedNodePools)
+}
@@ -0,0 +1,144 @@
+package taintcheck
+
+import (
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/kubescape/sizing-checker/pkg/common"
+	"github.com/kubescape/sizing-checker/pkg/testutil"
+)
+
+func TestRunTaintCheck(t *testing.T) {
+	gpu := func(name string) corev1.Node {
+		return *testutil.Node(name, "8", "32Gi",
+			testutil.WithLabels(map[string]string{"cloud.google.com/gke-nodepool": "gpu"}),
+			testutil.WithTaint("nvidia.com/gpu", "", corev1.TaintEffectNoSchedule))
+	}
+	infra := *testutil.Node("infra", "4", "16Gi",
+		testutil.WithLabels(map[string]string{"cloud.google.com/gke-nodepool": "infra"}),
+		testutil.WithTaint("dedicated", "infra", corev1.TaintEffectNoExecute))
+
+	tests := []struct {
+		name            string
+		nodes           []corev1.Node
+		poolLabel       string
+		excluded        []string
+		wantStatus      common.CheckStatus
+		wantTolerations []string
+		wantExcluded    []string
+	}{
+		{
+			name: "no blocking taints",
+			nodes: []corev1.Node{
+				*testutil.Node("n1", "4", "16Gi"),
+				*testutil.Node("n2", "4", "16Gi", testutil.WithTaint("spot", "true", corev1.TaintEffectPreferNoSchedule)),
+				*testutil.Node("n3", "4", "16Gi", testutil.Unschedulable()),
+			},
+			wantStatus: common.StatusPass,
+		},
+		{
+			name:            "tainted pools",
+			nodes:           []corev1.Node{*testutil.Node("n1", "4", "16Gi"), gpu("gpu-1"), gpu("gpu-2"), infra},
+			wantStatus:      common.StatusWarn,
+			wantTolerations: []string{"dedicated=infra:NoExecute", "nvidia.com/gpu:NoSchedule"},
+		},
+		{
+			name:            "excluded pool",
+			nodes:           []corev1.Node{gpu("gpu-1"), infra},
+			excluded:        []string{"gpu"},
+			wantStatus:      common.StatusWarn,
+			wantTolerations: []string{"dedicated=infra:NoExecute"},
+			wantExcluded:    []string{"gpu"},
+		},
+		{
+			name:         "all tainted pools excluded",
+			nodes:        []corev1.Node{gpu("gpu-1"), gpu("gpu-2")},
+			excluded:     []string{"gpu"},
+			wantStatus:   common.StatusPass,
+			wantExcluded: []string{"gpu"},
+		},
+		{
+			name: "excluded pool by custom label",
+			nodes: []corev1.Node{
+				*testutil.Node("gpu-1", "8", "32Gi",
+					testutil.WithLabels(map[string]string{"cloud.google.com/gke-nodepool": "gpu", "team": "ml"}),
+					testutil.WithTaint("nvidia.com/gpu", "", corev1.TaintEffectNoSchedule)),
+				infra,
+			},
+			poolLabel:       "team",
+			excluded:        []string{"ml"},
+			wantStatus:      common.StatusWarn,
+			wantTolerations: []string{"dedicated=infra:NoExecute"},
+			wantExcluded:    []string{"ml"},
+		},
+		{
+			name: "unsupported nodes are skipped",
+			nodes: []corev1.Node{*testutil.Node("win", "4", "16Gi", testutil.WithOS("windows", "amd64"),
+				testutil.WithTaint("os", "windows", corev1.TaintEffectNoSchedule))},
+			wantStatus: common.StatusPass,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := RunTaintCheck(&common.ClusterData{Nodes: tt.nodes}, tt.poolLabel, tt.excluded)
+			if res.Status != tt.wantStatus {
+				t.Fatalf("RunTaintCheck() = %s, want %s: %s", res.Status, tt.wantStatus, res.Message)
+			}
+			advice, _ := res.Details.(*common.SchedulingAdvice)
+			if advice == nil {
+				advice = &common.SchedulingAdvice{}
+			}
+			var tolerations []string
+			for _, tol := range advice.Tolerations {
+				tolerations = append(tolerations, describeToleration(tol))
+			}
+			if got, want := strings.Join(tolerations, ","), strings.Join(tt.wantTolerations, ","); got != want {
+				t.Errorf("tolerations = %s, want %s", got, want)
+			}
+			if got, want := strings.Join(advice.ExcludedPools, ","), strings.Join(tt.wantExcluded, ","); got != want {
+				t.Errorf("excluded pools = %s, want %s", got, want)
+			}
+			// The proposed tolerations must let the node-agent onto every
+			// node outside the excluded pools
+			for i := range tt.nodes {
+				node := &tt.nodes[i]
+				_, pool := common.NodePoolLabel(node, tt.poolLabel)
+				if !common.NodeAgentSupported(node) || containsString(tt.excluded, pool) {
+					continue
+				}
+				for _, taint := range common.BlockingTaints(node) {
+					if !common.Tolerated(advice.Tolerations, &taint) {
+						t.Errorf("taint %s of node %s is not tolerated", taint.ToString(), node.Name)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestRunTaintCheckNodesUnavailable(t *testing.T) {
+	cd := &common.ClusterData{Collection: &common.CollectionStats{Resources: []common.ResourceCollection{
+		{Kind: common.ResourceNodes, Status: common.CollectionForbidden},
+	}}}
+	if res := RunTaintCheck(cd, "", nil); res.Status != common.StatusWarn || res.Reason != ReasonNodesUnavailable {
+		t.Errorf("RunTaintCheck() = %s/%q, want Warn/%s", res.Status, res.Reason, ReasonNodesUnavailable)
+	}
+}
+
+func describeToleration(tol corev1.Toleration) string {
+	if tol.Operator == corev1.TolerationOpExists {
+		return tol.Key + ":" + string(tol.Effect)
+	}
+	return tol.Key + "=" + tol.Value + ":" + string(tol.Effect)
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
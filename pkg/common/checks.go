@@ -0,0 +1,186 @@
+package common
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"k8s.io/client-go/kubernetes"
+)
+
+// Names of the built-in checks whose results feed the report summary and the
+// recommended Helm values.
+const (
+	SizingCheckName         = "sizing"
+	PVProvisioningCheckName = "pv-provisioning"
+	ConnectivityCheckName   = "connectivity"
+	EbpfCheckName           = "ebpf"
+	RBACCheckName           = "rbac"
+	RuntimeCheckName        = "container-runtime"
+	PlatformCheckName       = "node-platform"
+	TaintCheckName          = "taints"
+	HeadroomCheckName       = "node-headroom"
+)
+
+// CheckEnv carries everything a Check may need to run.
+type CheckEnv struct {
+	// Clientset is nil when Offline is set.
+	Clientset   kubernetes.Interface
+	ClusterData *ClusterData
+	InCluster   bool
+	// Offline is set when ClusterData was loaded from a dump (--from-dump)
+	// and the cluster cannot be reached.
+	Offline bool
+	// ReportNamespace is the namespace in-cluster runs store the report in.
+	ReportNamespace string
+	// NodeProbeNamespace is the namespace of the node probe DaemonSet; it is
+	// empty unless --node-probe is set.
+	NodeProbeNamespace string
+	// ExcludedNodePools are the node pools meant to run without the
+	// node-agent (--exclude-node-pools).
+	ExcludedNodePools []string
+	// SizingProfile is the sizing profile selected with --chart-version;
+	// nil means the newest embedded one.
+	SizingProfile *SizingProfile
+	// NodePoolLabel is the node label the node-agent is sized per pool by
+	// (--node-pool-label), besides the well-known pool labels.
+	NodePoolLabel string
+	// Sizing is the node-agent sizing of ClusterData, computed once before
+	// the checks run so the sizing and headroom checks agree on it.
+	Sizing *SizingResult
+}
+
+// Check is a single prerequisite check. The checker ships a set of built-in
+// checks; additional (e.g. in-house) checks can be added with RegisterCheck.
+type Check interface {
+	// Name returns a short, stable identifier such as "pv-provisioning".
+	Name() string
+	// Description returns the human-readable label shown in the report.
+	Description() string
+	// Run executes the check and returns its result. It must not return nil.
+	Run(ctx context.Context, env *CheckEnv) *CheckResult
+}
+
+// DefaultCheckTimeout is the time budget of a check that does not implement
+// TimeoutCheck.
+const DefaultCheckTimeout = 2 * time.Minute
+
+// ReasonTimeout is reported when a check does not finish within its timeout.
+const ReasonTimeout = "Timeout"
+
+// TimeoutCheck is implemented by checks that need a time budget other than
+// the one passed to RunChecks.
+type TimeoutCheck interface {
+	Timeout() time.Duration
+}
+
+// PreflightCheck is implemented by checks that run before the cluster data
+// is collected, e.g. to verify access to the cluster. Their CheckEnv has no
+// ClusterData.
+type PreflightCheck interface {
+	Preflight() bool
+}
+
+// SplitPreflightChecks separates the preflight checks from the others,
+// keeping their order.
+func SplitPreflightChecks(checks []Check) (preflight, rest []Check) {
+	for _, c := range checks {
+		if pc, ok := c.(PreflightCheck); ok && pc.Preflight() {
+			preflight = append(preflight, c)
+		} else {
+			rest = append(rest, c)
+		}
+	}
+	return preflight, rest
+}
+
+var registeredChecks []Check
+
+// RegisterCheck adds a check to the registry. It is meant to be called from
+// an init function of the package implementing the check, which is then
+// blank-imported by the checker binary. It panics if a check with the same
+// name has already been registered.
+func RegisterCheck(c Check) {
+	for _, existing := range registeredChecks {
+		if existing.Name() == c.Name() {
+			panic(fmt.Sprintf("check %q is already registered", c.Name()))
+		}
+	}
+	registeredChecks = append(registeredChecks, c)
+}
+
+// RegisteredChecks returns the checks added with RegisterCheck, in registration order.
+func RegisteredChecks() []Check {
+	return append([]Check(nil), registeredChecks...)
+}
+
+// RunChecks runs the given checks concurrently and returns their results in
+// the order of checks. Each check gets its own timeout (defaultTimeout unless
+// it implements TimeoutCheck); a check that does not return in time is
+// reported as Error with ReasonTimeout.
+func RunChecks(ctx context.Context, checks []Check, env *CheckEnv, defaultTimeout time.Duration) []*CheckResult {
+	results := make([]*CheckResult, len(checks))
+	var wg sync.WaitGroup
+	for i, c := range checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = runCheck(ctx, c, env, defaultTimeout)
+		}()
+	}
+	wg.Wait()
+	return results
+}
+
+// runCheck runs a single check within its timeout and fills in the common
+// result fields.
+func runCheck(ctx context.Context, c Check, env *CheckEnv, timeout time.Duration) *CheckResult {
+	if tc, ok := c.(TimeoutCheck); ok {
+		timeout = tc.Timeout()
+	}
+	checkCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	start := time.Now()
+	done := make(chan *CheckResult, 1)
+	go func() { done <- c.Run(checkCtx, env) }()
+
+	var res *CheckResult
+	select {
+	case res = <-done:
+		if res == nil {
+			res = &CheckResult{Status: StatusError, Reason: "NoResult", Message: "Check returned no result"}
+		}
+	case <-checkCtx.Done():
+		// The check ignores its context; leave it running and report the timeout.
+		log.Printf("Check %s did not finish within %s", c.Name(), timeout)
+		res = &CheckResult{
+			Status:  StatusError,
+			Reason:  ReasonTimeout,
+			Message: fmt.Sprintf("Check did not finish within %s", timeout),
+		}
+		if ctx.Err() != nil {
+			res.Message = "Check was interrupted by the global --timeout"
+		}
+	}
+
+	if res.Status == StatusPass && res.Message == "" {
+		res.Message = "Passed"
+	}
+	res.Name = c.Name()
+	res.Description = c.Description()
+	res.DurationMs = time.Since(start).Milliseconds()
+	return res
+}
+
+// FindCheckResult returns the result of the check with the given name, or nil.
+func FindCheckResult(results []*CheckResult, name string) *CheckResult {
+	for _, r := range results {
+		if r.Name == name {
+			return r
+		}
+	}
+	return nil
+}
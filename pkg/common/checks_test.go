@@ -0,0 +1,90 @@
+package common
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// stubCheck returns result after delay, ignoring its context.
+type stubCheck struct {
+	name    string
+	delay   time.Duration
+	result  *CheckResult
+	timeout time.Duration
+}
+
+func (c stubCheck) Name() string        { return c.name }
+func (c stubCheck) Description() string { return c.name + " check" }
+
+func (c stubCheck) Run(context.Context, *CheckEnv) *CheckResult {
+	time.Sleep(c.delay)
+	return c.result
+}
+
+// timedCheck is a stubCheck with its own time budget.
+type timedCheck struct{ stubCheck }
+
+func (c timedCheck) Timeout() time.Duration { return c.timeout }
+
+func TestRunChecks(t *testing.T) {
+	checks := []Check{
+		stubCheck{name: "slow", delay: 200 * time.Millisecond, result: &CheckResult{Status: StatusPass}},
+		stubCheck{name: "fast", result: &CheckResult{Status: StatusWarn, Message: "careful"}},
+		stubCheck{name: "nil"},
+		timedCheck{stubCheck{name: "hung", delay: time.Second, result: &CheckResult{Status: StatusPass}, timeout: 50 * time.Millisecond}},
+	}
+
+	start := time.Now()
+	results := RunChecks(context.Background(), checks, &CheckEnv{}, 500*time.Millisecond)
+	if elapsed := time.Since(start); elapsed > 700*time.Millisecond {
+		t.Errorf("checks did not run concurrently: took %s", elapsed)
+	}
+
+	want := []struct {
+		name   string
+		status CheckStatus
+		reason string
+	}{
+		{"slow", StatusPass, ""},
+		{"fast", StatusWarn, ""},
+		{"nil", StatusError, "NoResult"},
+		{"hung", StatusError, ReasonTimeout},
+	}
+	for i, w := range want {
+		r := results[i]
+		if r.Name != w.name || r.Status != w.status || r.Reason != w.reason {
+			t.Errorf("results[%d] = %s/%s/%q, want %s/%s/%q", i, r.Name, r.Status, r.Reason, w.name, w.status, w.reason)
+		}
+	}
+	if results[0].Message != "Passed" || results[0].Description != "slow check" {
+		t.Errorf("common fields not filled in: %+v", results[0])
+	}
+	if results[0].DurationMs < 200 {
+		t.Errorf("slow check DurationMs = %d, want >= 200", results[0].DurationMs)
+	}
+}
+
+func TestRunChecksDefaultTimeout(t *testing.T) {
+	checks := []Check{stubCheck{name: "slow", delay: time.Second, result: &CheckResult{Status: StatusPass}}}
+	results := RunChecks(context.Background(), checks, &CheckEnv{}, 50*time.Millisecond)
+	if results[0].Reason != ReasonTimeout {
+		t.Errorf("got %s/%q, want a timeout", results[0].Status, results[0].Reason)
+	}
+}
+
+// preflightCheck is a stubCheck that runs before collection.
+type preflightCheck struct{ stubCheck }
+
+func (preflightCheck) Preflight() bool { return true }
+
+func TestSplitPreflightChecks(t *testing.T) {
+	checks := []Check{stubCheck{name: "a"}, preflightCheck{stubCheck{name: "b"}}, stubCheck{name: "c"}}
+	preflight, rest := SplitPreflightChecks(checks)
+	if len(preflight) != 1 || preflight[0].Name() != "b" {
+		t.Errorf("preflight = %v, want [b]", preflight)
+	}
+	if len(rest) != 2 || rest[0].Name() != "a" || rest[1].Name() != "c" {
+		t.Errorf("rest = %v, want [a c]", rest)
+	}
+}
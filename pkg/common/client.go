@@ -1,42 +1,77 @@
 package common
 
 import (
-	"log"
-	"os"
-	"path/filepath"
+	"fmt"
+	"sort"
 
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
 )
 
-func BuildKubeClient(kubeconfigPath string) (*kubernetes.Clientset, bool) {
-	inCluster := true
-
-	// Try in-cluster config first
-	config, err := rest.InClusterConfig()
-	if err != nil {
-		// Not running in a cluster, so fallback to local kubeconfig
-		inCluster = false
+// KubeClientOptions selects the cluster and the identity the checker uses.
+type KubeClientOptions struct {
+	// Kubeconfig is the kubeconfig file; empty means $KUBECONFIG or $HOME/.kube/config.
+	Kubeconfig string
+	// Context is the kubeconfig context to use; empty means the current
+	// context, or the in-cluster config when running in a Pod.
+	Context string
+	// As and AsGroups impersonate a user and groups, like kubectl --as/--as-group.
+	As       string
+	AsGroups []string
+}
 
-		// If user did not provide --kubeconfig, fallback to ~/.kube/config
-		if kubeconfigPath == "" {
-			kubeconfigPath = filepath.Join(os.Getenv("HOME"), ".kube", "config")
-		}
+// BuildKubeClient creates a clientset for opts and reports whether it uses the
+// in-cluster config. The in-cluster config is only tried when no context is
+// selected.
+func BuildKubeClient(opts KubeClientOptions) (kubernetes.Interface, bool, error) {
+	inCluster := false
+	var config *rest.Config
+	var err error
 
-		config, err = clientcmd.BuildConfigFromFlags("", kubeconfigPath)
+	// Try in-cluster config first
+	if opts.Context == "" {
+		config, err = rest.InClusterConfig()
+		inCluster = err == nil
+	}
+	if !inCluster {
+		// Not running in a cluster (or a context was asked for), so use the kubeconfig
+		config, err = loadKubeconfig(opts.Kubeconfig, opts.Context).ClientConfig()
 		if err != nil {
-			log.Printf("Could not load in-cluster or local kubeconfig: %v", err)
-			return nil, inCluster
+			return nil, false, fmt.Errorf("could not load in-cluster or local kubeconfig: %w", err)
 		}
 	}
 
+	if opts.As != "" || len(opts.AsGroups) > 0 {
+		config.Impersonate = rest.ImpersonationConfig{UserName: opts.As, Groups: opts.AsGroups}
+	}
+
 	// Build the clientset
 	clientset, err := kubernetes.NewForConfig(config)
 	if err != nil {
-		log.Printf("Failed to create Kubernetes clientset: %v", err)
-		return nil, inCluster
+		return nil, inCluster, fmt.Errorf("failed to create Kubernetes clientset: %w", err)
 	}
+	return clientset, inCluster, nil
+}
+
+// KubeContexts returns the context names defined in the kubeconfig, sorted.
+func KubeContexts(kubeconfigPath string) ([]string, error) {
+	raw, err := loadKubeconfig(kubeconfigPath, "").RawConfig()
+	if err != nil {
+		return nil, fmt.Errorf("could not load kubeconfig: %w", err)
+	}
+	contexts := make([]string, 0, len(raw.Contexts))
+	for name := range raw.Contexts {
+		contexts = append(contexts, name)
+	}
+	sort.Strings(contexts)
+	return contexts, nil
+}
 
-	return clientset, inCluster
+// loadKubeconfig follows kubectl's loading rules: an explicit path wins over
+// $KUBECONFIG, which wins over $HOME/.kube/config.
+func loadKubeconfig(path, context string) clientcmd.ClientConfig {
+	rules := clientcmd.NewDefaultClientConfigLoadingRules()
+	rules.ExplicitPath = path
+	return clientcmd.NewNonInteractiveDeferredLoadingClientConfig(rules, &clientcmd.ConfigOverrides{CurrentContext: context})
 }
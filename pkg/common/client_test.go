@@ -0,0 +1,67 @@
+package common
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+const testKubeconfig = `apiVersion: v1
+kind: Config
+current-context: dev
+clusters:
+- name: dev
+  cluster: {server: "https://dev.example.com"}
+- name: prod
+  cluster: {server: "https://prod.example.com"}
+users:
+- name: admin
+  user: {token: secret}
+contexts:
+- name: dev
+  context: {cluster: dev, user: admin}
+- name: arn:aws:eks:eu-west-1:123456789012:cluster/prod
+  context: {cluster: prod, user: admin}
+`
+
+func writeKubeconfig(t *testing.T) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config")
+	if err := os.WriteFile(path, []byte(testKubeconfig), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestKubeContexts(t *testing.T) {
+	contexts, err := KubeContexts(writeKubeconfig(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"arn:aws:eks:eu-west-1:123456789012:cluster/prod", "dev"}
+	if !reflect.DeepEqual(contexts, want) {
+		t.Errorf("KubeContexts() = %v, want %v", contexts, want)
+	}
+}
+
+func TestBuildKubeClient(t *testing.T) {
+	path := writeKubeconfig(t)
+
+	clientset, inCluster, err := BuildKubeClient(KubeClientOptions{
+		Kubeconfig: path,
+		Context:    "arn:aws:eks:eu-west-1:123456789012:cluster/prod",
+		As:         "auditor",
+		AsGroups:   []string{"readers"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if clientset == nil || inCluster {
+		t.Errorf("BuildKubeClient() = %v, %v, want a local clientset", clientset, inCluster)
+	}
+
+	if _, _, err := BuildKubeClient(KubeClientOptions{Kubeconfig: path, Context: "missing"}); err == nil {
+		t.Error("expected an error for an unknown context")
+	}
+}
@@ -0,0 +1,94 @@
+package common
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+)
+
+// CollectionStatus is the outcome of collecting one resource kind.
+type CollectionStatus string
+
+const (
+	CollectionOK        CollectionStatus = "ok"
+	CollectionForbidden CollectionStatus = "forbidden"  // RBAC denied the request
+	CollectionTimeout   CollectionStatus = "timeout"    // the request or the global --timeout expired
+	CollectionNotServed CollectionStatus = "not-served" // the API server does not serve the resource
+	CollectionError     CollectionStatus = "error"
+)
+
+// Resource kinds collected by CollectClusterData.
+const (
+	ResourceServerVersion  = "serverversion"
+	ResourceNodes          = "nodes"
+	ResourcePods           = "pods"
+	ResourceServices       = "services"
+	ResourceDeployments    = "deployments"
+	ResourceReplicaSets    = "replicasets"
+	ResourceStatefulSets   = "statefulsets"
+	ResourceDaemonSets     = "daemonsets"
+	ResourceJobs           = "jobs"
+	ResourceCronJobs       = "cronjobs"
+	ResourceStorageClasses = "storageclasses"
+)
+
+// classifyCollectionError maps a List (or discovery) error to a CollectionStatus.
+func classifyCollectionError(err error) CollectionStatus {
+	switch {
+	case err == nil:
+		return CollectionOK
+	case apierrors.IsForbidden(err), apierrors.IsUnauthorized(err):
+		return CollectionForbidden
+	case errors.Is(err, context.DeadlineExceeded), errors.Is(err, context.Canceled),
+		apierrors.IsTimeout(err), apierrors.IsServerTimeout(err):
+		return CollectionTimeout
+	case apierrors.IsNotFound(err), apierrors.IsMethodNotSupported(err), meta.IsNoMatchError(err):
+		return CollectionNotServed
+	default:
+		return CollectionError
+	}
+}
+
+// Collected reports whether the given resource kind was collected. Data
+// loaded from a dump without collection statistics counts as collected.
+func (cd *ClusterData) Collected(kind string) bool {
+	if cd == nil || cd.Collection == nil {
+		return cd != nil
+	}
+	for _, r := range cd.Collection.Resources {
+		if r.Kind == kind {
+			return r.Status == CollectionOK
+		}
+	}
+	return true
+}
+
+// MissingResources returns the collection outcome of every kind in kinds
+// that was not collected, in the order of kinds.
+func (cd *ClusterData) MissingResources(kinds ...string) []ResourceCollection {
+	if cd == nil || cd.Collection == nil {
+		return nil
+	}
+	var missing []ResourceCollection
+	for _, kind := range kinds {
+		for _, r := range cd.Collection.Resources {
+			if r.Kind == kind && r.Status != CollectionOK {
+				missing = append(missing, r)
+			}
+		}
+	}
+	return missing
+}
+
+// DescribeMissing renders missing resources as "cronjobs (forbidden), jobs (timeout)".
+func DescribeMissing(missing []ResourceCollection) string {
+	parts := make([]string, 0, len(missing))
+	for _, r := range missing {
+		parts = append(parts, fmt.Sprintf("%s (%s)", r.Kind, r.Status))
+	}
+	return strings.Join(parts, ", ")
+}
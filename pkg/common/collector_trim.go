@@ -0,0 +1,146 @@
+package common
+
+import (
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// The trim* methods run on every listed object before it is kept. They always
+// drop managedFields; with CollectOptions.Slim they also drop everything the
+// checks do not read:
+//   - nodes keep their labels, taints, capacity, allocatable, conditions,
+//     node info and their largest image only;
+//   - pods keep their node, scheduling constraints, container resources and phase;
+//   - DaemonSets keep their scheduling constraints and status;
+//   - the other workloads and services are only counted, so only their
+//     metadata is kept.
+
+func (c *collector) trimMeta(m *metav1.ObjectMeta) {
+	m.ManagedFields = nil
+	if c.opts.Slim {
+		m.Annotations = nil
+	}
+}
+
+func (c *collector) trimNode(n *corev1.Node) {
+	c.trimMeta(&n.ObjectMeta)
+	if !c.opts.Slim {
+		return
+	}
+	var largest *corev1.ContainerImage
+	for i := range n.Status.Images {
+		if largest == nil || n.Status.Images[i].SizeBytes > largest.SizeBytes {
+			largest = &n.Status.Images[i]
+		}
+	}
+	n.Status.Images = nil
+	if largest != nil {
+		img := corev1.ContainerImage{SizeBytes: largest.SizeBytes}
+		if len(largest.Names) > 0 {
+			img.Names = largest.Names[:1]
+		}
+		n.Status.Images = []corev1.ContainerImage{img}
+	}
+	n.Status.VolumesInUse = nil
+	n.Status.VolumesAttached = nil
+	n.Status.Addresses = nil
+}
+
+func (c *collector) trimPod(p *corev1.Pod) {
+	c.trimMeta(&p.ObjectMeta)
+	if !c.opts.Slim {
+		return
+	}
+	p.Spec = corev1.PodSpec{
+		NodeName:          p.Spec.NodeName,
+		NodeSelector:      p.Spec.NodeSelector,
+		Affinity:          p.Spec.Affinity,
+		Tolerations:       p.Spec.Tolerations,
+		PriorityClassName: p.Spec.PriorityClassName,
+		Overhead:          p.Spec.Overhead,
+		InitContainers:    slimContainers(p.Spec.InitContainers),
+		Containers:        slimContainers(p.Spec.Containers),
+	}
+	p.Status = corev1.PodStatus{Phase: p.Status.Phase, QOSClass: p.Status.QOSClass}
+}
+
+// slimContainers keeps the name, image and resources of each container.
+func slimContainers(containers []corev1.Container) []corev1.Container {
+	if containers == nil {
+		return nil
+	}
+	out := make([]corev1.Container, len(containers))
+	for i, ctr := range containers {
+		out[i] = corev1.Container{
+			Name:          ctr.Name,
+			Image:         ctr.Image,
+			Resources:     ctr.Resources,
+			RestartPolicy: ctr.RestartPolicy,
+		}
+	}
+	return out
+}
+
+func (c *collector) trimDaemonSet(ds *appsv1.DaemonSet) {
+	c.trimMeta(&ds.ObjectMeta)
+	if !c.opts.Slim {
+		return
+	}
+	podSpec := ds.Spec.Template.Spec
+	ds.Spec = appsv1.DaemonSetSpec{Selector: ds.Spec.Selector}
+	ds.Spec.Template.Spec = corev1.PodSpec{
+		NodeSelector: podSpec.NodeSelector,
+		Affinity:     podSpec.Affinity,
+		Tolerations:  podSpec.Tolerations,
+	}
+}
+
+func (c *collector) trimService(s *corev1.Service) {
+	c.trimMeta(&s.ObjectMeta)
+	if c.opts.Slim {
+		*s = corev1.Service{ObjectMeta: s.ObjectMeta}
+	}
+}
+
+func (c *collector) trimDeployment(d *appsv1.Deployment) {
+	c.trimMeta(&d.ObjectMeta)
+	if c.opts.Slim {
+		*d = appsv1.Deployment{ObjectMeta: d.ObjectMeta}
+	}
+}
+
+func (c *collector) trimReplicaSet(rs *appsv1.ReplicaSet) {
+	c.trimMeta(&rs.ObjectMeta)
+	if c.opts.Slim {
+		*rs = appsv1.ReplicaSet{ObjectMeta: rs.ObjectMeta}
+	}
+}
+
+func (c *collector) trimStatefulSet(sts *appsv1.StatefulSet) {
+	c.trimMeta(&sts.ObjectMeta)
+	if c.opts.Slim {
+		*sts = appsv1.StatefulSet{ObjectMeta: sts.ObjectMeta}
+	}
+}
+
+func (c *collector) trimJob(j *batchv1.Job) {
+	c.trimMeta(&j.ObjectMeta)
+	if c.opts.Slim {
+		*j = batchv1.Job{ObjectMeta: j.ObjectMeta}
+	}
+}
+
+func (c *collector) trimCronJob(cj *batchv1.CronJob) {
+	c.trimMeta(&cj.ObjectMeta)
+	if c.opts.Slim {
+		*cj = batchv1.CronJob{ObjectMeta: cj.ObjectMeta}
+	}
+}
+
+func (c *collector) trimStorageClass(sc *storagev1.StorageClass) {
+	// StorageClasses are few, small, and their annotations mark the default class.
+	sc.ManagedFields = nil
+}
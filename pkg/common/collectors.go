@@ -2,159 +2,289 @@ package common
 
 import (
 	"context"
+	"fmt"
 	"log"
+	"runtime"
 	"strings"
+	"sync"
+	"time"
 
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
 	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes"
 )
 
-func CollectClusterData(ctx context.Context, clientset *kubernetes.Clientset) (*ClusterData, error) {
+// collectedKinds lists the resource kinds CollectClusterData collects.
+var collectedKinds = []string{
+	ResourceServerVersion, ResourceNodes, ResourcePods, ResourceServices, ResourceDeployments, ResourceReplicaSets,
+	ResourceStatefulSets, ResourceDaemonSets, ResourceJobs, ResourceCronJobs, ResourceStorageClasses,
+}
+
+const (
+	// DefaultPageSize is the number of objects requested per List call.
+	DefaultPageSize = 500
+	// DefaultCollectConcurrency is the number of kinds listed in parallel.
+	DefaultCollectConcurrency = 4
+)
+
+// CollectOptions controls how CollectClusterData lists the cluster objects.
+type CollectOptions struct {
+	// PageSize is the Limit of each List call; 0 lists every kind in one call.
+	PageSize int64
+	// Concurrency bounds the number of kinds listed in parallel.
+	Concurrency int
+	// Slim keeps only the fields the checks need, and metadata only for the
+	// kinds that are just counted, to bound memory on very large clusters.
+	Slim bool
+}
+
+// CollectClusterData lists the cluster objects page by page, several kinds at
+// a time. Each page is trimmed (see collector_trim.go) before the next one is
+// requested, so only the kept fields accumulate in memory.
+//
+// A kind that cannot be listed (e.g. forbidden by RBAC) is left empty and its
+// status is recorded in ClusterData.Collection; the checks decide how much
+// they can still conclude. An error is only returned when the API server
+// cannot be reached.
+func CollectClusterData(ctx context.Context, clientset kubernetes.Interface, opts CollectOptions) (*ClusterData, error) {
 	cd := &ClusterData{}
+	c := &collector{opts: opts, stats: &CollectionStats{PageSize: opts.PageSize, Slim: opts.Slim}}
+	cd.Collection = c.stats
+	c.sampleMemory()
 
-	// 1) Get the Kubernetes version
+	// 1) Get the Kubernetes version. Any failure other than a denied or
+	// timed-out request means the API server cannot be reached at all.
+	start := time.Now()
 	kubeVersion, err := clientset.Discovery().ServerVersion()
+	version := ResourceCollection{Kind: ResourceServerVersion, Status: classifyCollectionError(err), DurationMs: time.Since(start).Milliseconds()}
 	if err != nil {
-		log.Fatalf("Cannot access cluster: %v", err)
+		if version.Status == CollectionError {
+			return cd, fmt.Errorf("cannot access cluster: %w", err)
+		}
+		version.Error = err.Error()
+		log.Printf("Failed to get the server version (%s): %v", version.Status, err)
+	} else {
+		version.Count = 1
+		cd.ClusterDetails.Version = kubeVersion.String()
 	}
-	cd.ClusterDetails.Version = kubeVersion.String()
-
-	// 2) List nodes
-	nodes, err := clientset.CoreV1().Nodes().List(ctx, metav1.ListOptions{})
-	if err != nil {
-		log.Fatalf("Failed to list nodes: %v", err)
-		return cd, err
+	c.stats.Resources = append(c.stats.Resources, version)
+
+	// 2) List every resource kind, a few at a time; each lister fills its own
+	// field and a kind that cannot be listed is recorded and left empty
+	listers := []resourceLister{
+		newLister(c, ResourceNodes, &cd.Nodes, func(ctx context.Context, o metav1.ListOptions) ([]corev1.Node, string, error) {
+			l, err := clientset.CoreV1().Nodes().List(ctx, o)
+			if err != nil {
+				return nil, "", err
+			}
+			return l.Items, l.Continue, nil
+		}, c.trimNode),
+		newLister(c, ResourcePods, &cd.Pods, func(ctx context.Context, o metav1.ListOptions) ([]corev1.Pod, string, error) {
+			l, err := clientset.CoreV1().Pods("").List(ctx, o)
+			if err != nil {
+				return nil, "", err
+			}
+			return l.Items, l.Continue, nil
+		}, c.trimPod),
+		newLister(c, ResourceServices, &cd.Services, func(ctx context.Context, o metav1.ListOptions) ([]corev1.Service, string, error) {
+			l, err := clientset.CoreV1().Services("").List(ctx, o)
+			if err != nil {
+				return nil, "", err
+			}
+			return l.Items, l.Continue, nil
+		}, c.trimService),
+		newLister(c, ResourceDeployments, &cd.Deployments, func(ctx context.Context, o metav1.ListOptions) ([]appsv1.Deployment, string, error) {
+			l, err := clientset.AppsV1().Deployments("").List(ctx, o)
+			if err != nil {
+				return nil, "", err
+			}
+			return l.Items, l.Continue, nil
+		}, c.trimDeployment),
+		newLister(c, ResourceReplicaSets, &cd.ReplicaSets, func(ctx context.Context, o metav1.ListOptions) ([]appsv1.ReplicaSet, string, error) {
+			l, err := clientset.AppsV1().ReplicaSets("").List(ctx, o)
+			if err != nil {
+				return nil, "", err
+			}
+			return l.Items, l.Continue, nil
+		}, c.trimReplicaSet),
+		newLister(c, ResourceStatefulSets, &cd.StatefulSets, func(ctx context.Context, o metav1.ListOptions) ([]appsv1.StatefulSet, string, error) {
+			l, err := clientset.AppsV1().StatefulSets("").List(ctx, o)
+			if err != nil {
+				return nil, "", err
+			}
+			return l.Items, l.Continue, nil
+		}, c.trimStatefulSet),
+		newLister(c, ResourceDaemonSets, &cd.DaemonSets, func(ctx context.Context, o metav1.ListOptions) ([]appsv1.DaemonSet, string, error) {
+			l, err := clientset.AppsV1().DaemonSets("").List(ctx, o)
+			if err != nil {
+				return nil, "", err
+			}
+			return l.Items, l.Continue, nil
+		}, c.trimDaemonSet),
+		newLister(c, ResourceJobs, &cd.Jobs, func(ctx context.Context, o metav1.ListOptions) ([]batchv1.Job, string, error) {
+			l, err := clientset.BatchV1().Jobs("").List(ctx, o)
+			if err != nil {
+				return nil, "", err
+			}
+			return l.Items, l.Continue, nil
+		}, c.trimJob),
+		newLister(c, ResourceCronJobs, &cd.CronJobs, func(ctx context.Context, o metav1.ListOptions) ([]batchv1.CronJob, string, error) {
+			l, err := clientset.BatchV1().CronJobs("").List(ctx, o)
+			if err != nil {
+				return nil, "", err
+			}
+			return l.Items, l.Continue, nil
+		}, c.trimCronJob),
+		newLister(c, ResourceStorageClasses, &cd.StorageClasses, func(ctx context.Context, o metav1.ListOptions) ([]storagev1.StorageClass, string, error) {
+			l, err := clientset.StorageV1().StorageClasses().List(ctx, o)
+			if err != nil {
+				return nil, "", err
+			}
+			return l.Items, l.Continue, nil
+		}, c.trimStorageClass),
 	}
-	cd.Nodes = nodes.Items
+	c.stats.Resources = append(c.stats.Resources, c.runListers(ctx, listers)...)
 
-	gatherNodeInfoSummaries(&cd.NodeInfoSummaries, nodes.Items)
+	gatherNodeInfoSummaries(&cd.NodeInfoSummaries, cd.Nodes)
 
 	// 3) Detect Cloud Provider & Distribution from nodes
-	cd.ClusterDetails.CloudProvider = detectCloudProvider(nodes.Items)
-	cd.ClusterDetails.K8sDistribution = detectK8sDistribution(nodes.Items)
+	cd.ClusterDetails.CloudProvider = detectCloudProvider(cd.Nodes)
+	cd.ClusterDetails.K8sDistribution = detectK8sDistribution(cd.Nodes)
 
 	// 4) Calculate total node count & total vCPUs
-	cd.ClusterDetails.TotalNodeCount = len(nodes.Items)
+	cd.ClusterDetails.TotalNodeCount = len(cd.Nodes)
 	var totalMilliCPU int64
-	for _, node := range nodes.Items {
+	for _, node := range cd.Nodes {
 		totalMilliCPU += node.Status.Capacity.Cpu().MilliValue()
 	}
 	cd.ClusterDetails.TotalVCPUCount = int(totalMilliCPU / 1000)
 
-	// 5) List other resources
-	pods, err := clientset.CoreV1().Pods("").List(ctx, metav1.ListOptions{})
-	if err != nil {
-		log.Printf("Failed to list pods: %v", err)
-		return cd, err
-	}
-	cd.Pods = pods.Items
-
-	services, err := clientset.CoreV1().Services("").List(ctx, metav1.ListOptions{})
-	if err != nil {
-		log.Printf("Failed to list services: %v", err)
-		return cd, err
-	}
-	cd.Services = services.Items
-
-	deployments, err := clientset.AppsV1().Deployments("").List(ctx, metav1.ListOptions{})
-	if err != nil {
-		log.Printf("Failed to list deployments: %v", err)
-		return cd, err
-	}
-	cd.Deployments = deployments.Items
-
-	replicasets, err := clientset.AppsV1().ReplicaSets("").List(ctx, metav1.ListOptions{})
-	if err != nil {
-		log.Printf("Failed to list replicasets: %v", err)
-		return cd, err
-	}
-	cd.ReplicaSets = replicasets.Items
-
-	statefulsets, err := clientset.AppsV1().StatefulSets("").List(ctx, metav1.ListOptions{})
-	if err != nil {
-		log.Printf("Failed to list statefulsets: %v", err)
-		return cd, err
-	}
-	cd.StatefulSets = statefulsets.Items
-
-	daemonsets, err := clientset.AppsV1().DaemonSets("").List(ctx, metav1.ListOptions{})
-	if err != nil {
-		log.Printf("Failed to list daemonsets: %v", err)
-		return cd, err
-	}
-	cd.DaemonSets = daemonsets.Items
-
-	jobs, err := clientset.BatchV1().Jobs("").List(ctx, metav1.ListOptions{})
-	if err != nil {
-		log.Printf("Failed to list jobs: %v", err)
-		return cd, err
-	}
-	cd.Jobs = jobs.Items
-
-	cronjobs, err := clientset.BatchV1().CronJobs("").List(ctx, metav1.ListOptions{})
-	if err != nil {
-		log.Printf("Failed to list cronjobs: %v", err)
-		return cd, err
+	c.sampleMemory()
+	log.Printf("Collected cluster data in %d list calls (page size %d, slim %t); peak heap %d MiB",
+		c.stats.Pages, c.stats.PageSize, c.stats.Slim, c.stats.PeakMemoryMiB)
+	if missing := cd.MissingResources(collectedKinds...); len(missing) > 0 {
+		log.Printf("Could not collect: %s; the report is based on partial data", DescribeMissing(missing))
 	}
-	cd.CronJobs = cronjobs.Items
 
-	storageClasses, err := clientset.StorageV1().StorageClasses().List(ctx, metav1.ListOptions{})
-	if err != nil {
-		log.Printf("Failed to list storageclasses: %v", err)
-		return cd, err
-	}
-	cd.StorageClasses = storageClasses.Items
+	return cd, nil
+}
 
-	stripManagedFields(cd)
+// resourceLister lists one kind of object into ClusterData and returns the
+// number of objects listed.
+type resourceLister struct {
+	kind string
+	list func(ctx context.Context) (int, error)
+}
 
-	return cd, nil
+// newLister returns a resourceLister that lists all pages of a kind into dst.
+func newLister[T any](c *collector, kind string, dst *[]T, list listFunc[T], trim func(*T)) resourceLister {
+	return resourceLister{kind: kind, list: func(ctx context.Context) (int, error) {
+		items, err := listAll(ctx, c, kind, list, trim)
+		*dst = items
+		return len(items), err
+	}}
 }
 
-func stripManagedFields(cd *ClusterData) {
-	// Remove from Nodes
-	for i := range cd.Nodes {
-		cd.Nodes[i].ManagedFields = nil
+// runListers runs the listers with at most opts.Concurrency in flight and
+// returns the outcome of each, in lister order.
+func (c *collector) runListers(ctx context.Context, listers []resourceLister) []ResourceCollection {
+	concurrency := c.opts.Concurrency
+	if concurrency <= 0 {
+		concurrency = 1
 	}
-
-	// Remove from Pods
-	for i := range cd.Pods {
-		cd.Pods[i].ManagedFields = nil
+	sem := make(chan struct{}, concurrency)
+	results := make([]ResourceCollection, len(listers))
+	var wg sync.WaitGroup
+	for i, l := range listers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			start := time.Now()
+			count, err := l.list(ctx)
+			results[i] = ResourceCollection{
+				Kind:       l.kind,
+				Status:     classifyCollectionError(err),
+				Count:      count,
+				DurationMs: time.Since(start).Milliseconds(),
+			}
+			if err != nil {
+				results[i].Error = err.Error()
+				log.Printf("Failed to list %s (%s): %v", l.kind, results[i].Status, err)
+			}
+		}()
 	}
+	wg.Wait()
+	return results
+}
 
-	// Remove from Services
-	for i := range cd.Services {
-		cd.Services[i].ManagedFields = nil
-	}
+// collector carries the collection options and statistics across List calls.
+type collector struct {
+	opts CollectOptions
 
-	// Remove from Deployments
-	for i := range cd.Deployments {
-		cd.Deployments[i].ManagedFields = nil
-	}
+	mu    sync.Mutex // guards stats
+	stats *CollectionStats
+}
 
-	// Remove from ReplicaSets
-	for i := range cd.ReplicaSets {
-		cd.ReplicaSets[i].ManagedFields = nil
-	}
+// pageDone counts a List call and samples the memory usage.
+func (c *collector) pageDone() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.stats.Pages++
+	c.sampleMemoryLocked()
+}
 
-	// Remove from StatefulSets
-	for i := range cd.StatefulSets {
-		cd.StatefulSets[i].ManagedFields = nil
-	}
+// sampleMemory records the current heap size if it is the largest seen so far.
+func (c *collector) sampleMemory() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.sampleMemoryLocked()
+}
 
-	// Remove from DaemonSets
-	for i := range cd.DaemonSets {
-		cd.DaemonSets[i].ManagedFields = nil
+func (c *collector) sampleMemoryLocked() {
+	var ms runtime.MemStats
+	runtime.ReadMemStats(&ms)
+	if mib := int(ms.HeapAlloc / (1024 * 1024)); mib > c.stats.PeakMemoryMiB {
+		c.stats.PeakMemoryMiB = mib
 	}
+}
 
-	// Remove from Jobs
-	for i := range cd.Jobs {
-		cd.Jobs[i].ManagedFields = nil
-	}
+// listFunc fetches one page of a list and returns its items and continue token.
+type listFunc[T any] func(ctx context.Context, opts metav1.ListOptions) ([]T, string, error)
+
+// listAll follows the continue tokens of a paginated list until every page is
+// read, trimming each item as it arrives. If a continue token expires
+// mid-way, the listing restarts once from the beginning.
+func listAll[T any](ctx context.Context, c *collector, kind string, list listFunc[T], trim func(*T)) ([]T, error) {
+	var items []T
+	opts := metav1.ListOptions{Limit: c.opts.PageSize}
+	restarted := false
+	for {
+		page, cont, err := list(ctx, opts)
+		if err != nil && apierrors.IsResourceExpired(err) && opts.Continue != "" && !restarted {
+			log.Printf("Continue token for %s expired; restarting the list", kind)
+			items, opts.Continue, restarted = nil, "", true
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		for i := range page {
+			trim(&page[i])
+		}
+		items = append(items, page...)
+		c.pageDone()
 
-	// Remove from CronJobs
-	for i := range cd.CronJobs {
-		cd.CronJobs[i].ManagedFields = nil
+		if cont == "" {
+			return items, nil
+		}
+		opts.Continue = cont
 	}
 }
 
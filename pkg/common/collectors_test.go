@@ -0,0 +1,283 @@
+package common
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	k8stesting "k8s.io/client-go/testing"
+
+	"github.com/kubescape/sizing-checker/pkg/testutil"
+)
+
+func TestCollectClusterData(t *testing.T) {
+	objects := []runtime.Object{
+		testutil.Node("linux-1", "4", "16Gi",
+			testutil.WithProviderID("aws:///eu-west-1a/i-0123"),
+			testutil.WithLabels(map[string]string{"eks.amazonaws.com/nodegroup": "general"})),
+		testutil.Node("linux-2", "8", "32Gi",
+			testutil.WithProviderID("aws:///eu-west-1b/i-0456"),
+			testutil.WithLabels(map[string]string{"eks.amazonaws.com/nodegroup": "general"})),
+		testutil.Node("win-1", "2", "8Gi", testutil.WithOS("windows", "amd64")),
+		testutil.Pod("default", "web", "linux-1", "100m", "128Mi"),
+		testutil.Pod("kube-system", "dns", "linux-2", "", ""),
+		testutil.StorageClass("gp3", "ebs.csi.aws.com", true),
+	}
+	objects = append(objects, testutil.Workloads("default")...)
+	cs := testutil.NewClientset(objects...)
+
+	cd, err := CollectClusterData(context.Background(), cs, CollectOptions{PageSize: DefaultPageSize})
+	if err != nil {
+		t.Fatalf("CollectClusterData returned error: %v", err)
+	}
+
+	if cd.ClusterDetails.Version != testutil.DefaultServerVersion {
+		t.Errorf("Version = %q, want %q", cd.ClusterDetails.Version, testutil.DefaultServerVersion)
+	}
+	if cd.ClusterDetails.CloudProvider != "AWS" {
+		t.Errorf("CloudProvider = %q, want AWS", cd.ClusterDetails.CloudProvider)
+	}
+	if cd.ClusterDetails.K8sDistribution != "EKS" {
+		t.Errorf("K8sDistribution = %q, want EKS", cd.ClusterDetails.K8sDistribution)
+	}
+	if cd.ClusterDetails.TotalNodeCount != 3 {
+		t.Errorf("TotalNodeCount = %d, want 3", cd.ClusterDetails.TotalNodeCount)
+	}
+	if cd.ClusterDetails.TotalVCPUCount != 14 {
+		t.Errorf("TotalVCPUCount = %d, want 14", cd.ClusterDetails.TotalVCPUCount)
+	}
+
+	counts := map[string]int{
+		"pods":           len(cd.Pods),
+		"services":       len(cd.Services),
+		"deployments":    len(cd.Deployments),
+		"replicasets":    len(cd.ReplicaSets),
+		"statefulsets":   len(cd.StatefulSets),
+		"daemonsets":     len(cd.DaemonSets),
+		"jobs":           len(cd.Jobs),
+		"cronjobs":       len(cd.CronJobs),
+		"storageclasses": len(cd.StorageClasses),
+	}
+	want := map[string]int{
+		"pods": 2, "services": 1, "deployments": 1, "replicasets": 1, "statefulsets": 1,
+		"daemonsets": 1, "jobs": 1, "cronjobs": 1, "storageclasses": 1,
+	}
+	for kind, n := range want {
+		if counts[kind] != n {
+			t.Errorf("collected %d %s, want %d", counts[kind], kind, n)
+		}
+	}
+
+	os := cd.NodeInfoSummaries.OperatingSystemCounts
+	if os["linux"] != 2 || os["windows"] != 1 {
+		t.Errorf("OperatingSystemCounts = %v, want linux:2 windows:1", os)
+	}
+}
+
+// pagedPods serves pods in pages of the requested Limit. The fake clientset
+// ignores Limit and Continue, so the paging is emulated by a reactor. When
+// expireOnce is set, the first continue token is rejected as expired.
+func pagedPods(cs k8stesting.FakeClient, total int, expireOnce bool) *[]listCall {
+	var calls []listCall
+	cs.PrependReactor("list", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		opts := action.(k8stesting.ListActionImpl).ListOptions
+		calls = append(calls, listCall{Limit: opts.Limit, Continue: opts.Continue})
+
+		start := 0
+		if opts.Continue != "" {
+			if expireOnce {
+				expireOnce = false
+				return true, nil, apierrors.NewResourceExpired("continue token expired")
+			}
+			fmt.Sscanf(opts.Continue, "%d", &start)
+		}
+		end := min(start+int(opts.Limit), total)
+		list := &corev1.PodList{}
+		for i := start; i < end; i++ {
+			list.Items = append(list.Items, *testutil.Pod("default", fmt.Sprintf("pod-%d", i), "n1", "10m", "16Mi"))
+		}
+		if end < total {
+			list.Continue = fmt.Sprint(end)
+		}
+		return true, list, nil
+	})
+	return &calls
+}
+
+// listCall records the paging options of a List call.
+type listCall struct {
+	Limit    int64
+	Continue string
+}
+
+func TestCollectClusterDataPaginates(t *testing.T) {
+	for _, expire := range []bool{false, true} {
+		t.Run(fmt.Sprintf("expired token %t", expire), func(t *testing.T) {
+			cs := testutil.NewClientset(testutil.Node("n1", "4", "16Gi"))
+			calls := pagedPods(cs, 25, expire)
+
+			cd, err := CollectClusterData(context.Background(), cs, CollectOptions{PageSize: 10})
+			if err != nil {
+				t.Fatalf("CollectClusterData returned error: %v", err)
+			}
+			if len(cd.Pods) != 25 {
+				t.Errorf("collected %d pods, want 25", len(cd.Pods))
+			}
+			if cd.Pods[24].Name != "pod-24" {
+				t.Errorf("last pod = %s, want pod-24", cd.Pods[24].Name)
+			}
+
+			wantCalls := 3
+			if expire {
+				wantCalls = 5 // page 1, expired page 2, then pages 1-3 again
+			}
+			if len(*calls) != wantCalls {
+				t.Errorf("made %d pod list calls, want %d: %+v", len(*calls), wantCalls, *calls)
+			}
+			for _, c := range *calls {
+				if c.Limit != 10 {
+					t.Errorf("list call with Limit %d, want 10", c.Limit)
+				}
+			}
+			if cd.Collection == nil || cd.Collection.PageSize != 10 || cd.Collection.PeakMemoryMiB <= 0 {
+				t.Errorf("unexpected collection stats: %+v", cd.Collection)
+			}
+		})
+	}
+}
+
+func TestCollectClusterDataSlim(t *testing.T) {
+	node := testutil.Node("n1", "4", "16Gi",
+		testutil.WithImage("small", 10*1024*1024),
+		testutil.WithImage("large", 900*1024*1024),
+		testutil.WithTaint("dedicated", "gpu", corev1.TaintEffectNoSchedule))
+	node.Annotations = map[string]string{"node.alpha.kubernetes.io/ttl": "0"}
+	pod := testutil.Pod("default", "web", "n1", "100m", "128Mi")
+	pod.Annotations = map[string]string{"kubectl.kubernetes.io/last-applied-configuration": "{}"}
+	pod.Spec.Volumes = []corev1.Volume{{Name: "data"}}
+	pod.Spec.Containers[0].Env = []corev1.EnvVar{{Name: "FOO", Value: "bar"}}
+
+	objects := append([]runtime.Object{node, pod}, testutil.Workloads("default")...)
+	cs := testutil.NewClientset(objects...)
+
+	cd, err := CollectClusterData(context.Background(), cs, CollectOptions{Slim: true})
+	if err != nil {
+		t.Fatalf("CollectClusterData returned error: %v", err)
+	}
+
+	n := cd.Nodes[0]
+	if n.Annotations != nil {
+		t.Errorf("node annotations kept: %v", n.Annotations)
+	}
+	if len(n.Status.Images) != 1 || n.Status.Images[0].Names[0] != "large" {
+		t.Errorf("node images = %+v, want only the largest image", n.Status.Images)
+	}
+	if len(n.Spec.Taints) != 1 || n.Status.Capacity.Cpu().MilliValue() != 4000 {
+		t.Errorf("node taints or capacity lost: %+v", n)
+	}
+
+	p := cd.Pods[0]
+	if p.Annotations != nil || p.Spec.Volumes != nil || p.Spec.Containers[0].Env != nil {
+		t.Errorf("pod not slimmed: %+v", p)
+	}
+	if p.Spec.NodeName != "n1" || p.Spec.Containers[0].Resources.Requests.Cpu().MilliValue() != 100 {
+		t.Errorf("pod lost the fields the checks need: %+v", p.Spec)
+	}
+
+	if len(cd.Deployments) != 1 || cd.Deployments[0].Name != "deploy" {
+		t.Errorf("deployments = %+v, want one metadata-only deployment", cd.Deployments)
+	}
+}
+
+func TestCollectClusterDataDegradesOnForbidden(t *testing.T) {
+	objects := append([]runtime.Object{testutil.Node("n1", "4", "16Gi")}, testutil.Workloads("default")...)
+	cs := testutil.NewClientset(objects...)
+	cs.PrependReactor("list", "cronjobs", func(k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, apierrors.NewForbidden(schema.GroupResource{Group: "batch", Resource: "cronjobs"}, "", fmt.Errorf("denied"))
+	})
+	cs.PrependReactor("list", "jobs", func(k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, apierrors.NewNotFound(schema.GroupResource{Group: "batch", Resource: "jobs"}, "")
+	})
+
+	cd, err := CollectClusterData(context.Background(), cs, CollectOptions{PageSize: DefaultPageSize, Concurrency: 2})
+	if err != nil {
+		t.Fatalf("CollectClusterData returned error: %v", err)
+	}
+	if len(cd.Nodes) != 1 || len(cd.Deployments) != 1 || len(cd.StatefulSets) != 1 {
+		t.Errorf("kinds listed after the forbidden one are missing: %d nodes, %d deployments, %d statefulsets",
+			len(cd.Nodes), len(cd.Deployments), len(cd.StatefulSets))
+	}
+
+	statuses := map[string]CollectionStatus{}
+	for _, r := range cd.Collection.Resources {
+		statuses[r.Kind] = r.Status
+	}
+	if len(statuses) != len(collectedKinds) {
+		t.Errorf("got statuses for %d kinds, want %d: %v", len(statuses), len(collectedKinds), statuses)
+	}
+	if statuses[ResourceCronJobs] != CollectionForbidden || statuses[ResourceJobs] != CollectionNotServed ||
+		statuses[ResourceNodes] != CollectionOK {
+		t.Errorf("unexpected statuses: %v", statuses)
+	}
+	if cd.Collected(ResourceCronJobs) || !cd.Collected(ResourcePods) {
+		t.Error("Collected() does not reflect the statuses")
+	}
+	if got := DescribeMissing(cd.MissingResources(collectedKinds...)); got != "jobs (not-served), cronjobs (forbidden)" {
+		t.Errorf("MissingResources() = %q", got)
+	}
+}
+
+func TestCollectClusterDataUnreachable(t *testing.T) {
+	cs := testutil.NewClientset()
+	cs.PrependReactor("get", "version", func(k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, fmt.Errorf("connection refused")
+	})
+	if _, err := CollectClusterData(context.Background(), cs, CollectOptions{}); err == nil {
+		t.Error("expected an error when the API server cannot be reached")
+	}
+}
+
+func TestDetectCloudProvider(t *testing.T) {
+	tests := []struct {
+		providerID string
+		want       string
+	}{
+		{"aws:///eu-west-1a/i-0123", "AWS"},
+		{"gce://project/europe-west1-b/node-1", "GCP"},
+		{"azure:///subscriptions/0000/resourceGroups/rg", "Azure"},
+		{"digitalocean://12345", "DigitalOcean"},
+		{"kind://docker/kind/kind-control-plane", "Unknown"},
+		{"", "Unknown"},
+	}
+	for _, tt := range tests {
+		nodes := []corev1.Node{*testutil.Node("n", "1", "1Gi", testutil.WithProviderID(tt.providerID))}
+		if got := detectCloudProvider(nodes); got != tt.want {
+			t.Errorf("detectCloudProvider(%q) = %q, want %q", tt.providerID, got, tt.want)
+		}
+	}
+}
+
+func TestDetectK8sDistribution(t *testing.T) {
+	tests := []struct {
+		label string
+		want  string
+	}{
+		{"eks.amazonaws.com/nodegroup", "EKS"},
+		{"cloud.google.com/gke-nodepool", "GKE"},
+		{"kubernetes.azure.com/cluster", "AKS"},
+		{"node.openshift.io/os_id", "OpenShift"},
+		{"rke.cattle.io/machine", "RKE"},
+		{"doks.digitalocean.com/node-pool", "DOKS"},
+		{"example.com/unrelated", "Unknown"},
+	}
+	for _, tt := range tests {
+		nodes := []corev1.Node{*testutil.Node("n", "1", "1Gi", testutil.WithLabels(map[string]string{tt.label: "x"}))}
+		if got := detectK8sDistribution(nodes); got != tt.want {
+			t.Errorf("detectK8sDistribution(%q) = %q, want %q", tt.label, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,282 @@
+package common
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	DefaultConfigMapName      = "kubescape-prerequisites-report"
+	DefaultConfigMapNamespace = "default"
+
+	// configMapBudget keeps every ConfigMap comfortably below the 1MiB limit,
+	// leaving room for metadata.
+	configMapBudget = 900 * 1024
+
+	// reportLabel is set on every ConfigMap of a report (main and parts) so
+	// parts left over from a previous, larger run can be cleaned up.
+	reportLabel = "kubescape.io/prerequisites-report"
+)
+
+// ConfigMap storage modes.
+const (
+	// ConfigMapModeAuto stores artifacts as plain data when they fit and
+	// gzip-compresses the ones that don't.
+	ConfigMapModeAuto = "auto"
+	// ConfigMapModeGzip gzip-compresses every artifact into binaryData.
+	ConfigMapModeGzip = "gzip"
+	// ConfigMapModePlain stores artifacts uncompressed and skips those that
+	// do not fit into a single ConfigMap.
+	ConfigMapModePlain = "plain"
+)
+
+// ConfigMapOptions controls where and how in-cluster runs store the report.
+type ConfigMapOptions struct {
+	Name      string
+	Namespace string
+	Labels    map[string]string
+	Mode      string
+}
+
+// NewConfigMapOptions builds ConfigMapOptions from the --configmap-* flag
+// values. Labels is a comma-separated list of key=value pairs.
+func NewConfigMapOptions(name, namespace, labels, mode string) (ConfigMapOptions, error) {
+	opts := ConfigMapOptions{Name: name, Namespace: namespace, Labels: map[string]string{}, Mode: mode}
+
+	// The name is also used as a label value on every ConfigMap of the report
+	errs := validation.IsDNS1123Subdomain(name)
+	errs = append(errs, validation.IsValidLabelValue(name)...)
+	if len(errs) > 0 {
+		return opts, fmt.Errorf("invalid --configmap-name %q: %s", name, strings.Join(errs, "; "))
+	}
+	if errs := validation.IsDNS1123Label(namespace); len(errs) > 0 {
+		return opts, fmt.Errorf("invalid --configmap-namespace %q: %s", namespace, strings.Join(errs, "; "))
+	}
+	switch mode {
+	case ConfigMapModeAuto, ConfigMapModeGzip, ConfigMapModePlain:
+	default:
+		return opts, fmt.Errorf("invalid --configmap-mode %q (expected auto, gzip or plain)", mode)
+	}
+
+	for _, entry := range strings.Split(labels, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		k, v, ok := strings.Cut(entry, "=")
+		if !ok {
+			return opts, fmt.Errorf("invalid --configmap-labels entry %q (expected key=value)", entry)
+		}
+		if errs := validation.IsQualifiedName(k); len(errs) > 0 {
+			return opts, fmt.Errorf("invalid label key %q: %s", k, strings.Join(errs, "; "))
+		}
+		if errs := validation.IsValidLabelValue(v); len(errs) > 0 {
+			return opts, fmt.Errorf("invalid label value %q: %s", v, strings.Join(errs, "; "))
+		}
+		opts.Labels[k] = v
+	}
+	return opts, nil
+}
+
+// configMapEntry is a single data or binaryData key of a report ConfigMap.
+type configMapEntry struct {
+	artifact OutputFile
+	key      string
+	data     []byte
+	binary   bool
+}
+
+// storedArtifact records where the pieces of an artifact ended up.
+type storedArtifact struct {
+	file   OutputFile
+	pieces []storedPiece
+	binary bool
+}
+
+type storedPiece struct {
+	configMap string
+	key       string
+}
+
+// buildConfigMapEntries turns the rendered artifacts into ConfigMap entries
+// according to the storage mode. Artifacts too large for a single ConfigMap
+// are split into numbered chunks, unless the mode is plain, in which case
+// they are skipped and returned separately.
+func buildConfigMapEntries(files []OutputFile, mode string) ([]configMapEntry, []OutputFile) {
+	var entries []configMapEntry
+	var skipped []OutputFile
+
+	for _, f := range files {
+		content := []byte(f.Content)
+		compress := mode == ConfigMapModeGzip || (mode == ConfigMapModeAuto && len(content) > configMapBudget)
+		if !compress {
+			if len(content) > configMapBudget {
+				skipped = append(skipped, f)
+				continue
+			}
+			entries = append(entries, configMapEntry{artifact: f, key: f.Name, data: content})
+			continue
+		}
+
+		gz, err := gzipBytes(content)
+		if err != nil {
+			log.Printf("Failed to compress %s: %v", f.Name, err)
+			skipped = append(skipped, f)
+			continue
+		}
+		key := f.Name + ".gz"
+		if len(gz) <= configMapBudget {
+			entries = append(entries, configMapEntry{artifact: f, key: key, data: gz, binary: true})
+			continue
+		}
+		for i := 0; len(gz) > 0; i++ {
+			n := min(len(gz), configMapBudget)
+			entries = append(entries, configMapEntry{
+				artifact: f,
+				key:      fmt.Sprintf("%s.%03d", key, i),
+				data:     gz[:n],
+				binary:   true,
+			})
+			gz = gz[n:]
+		}
+	}
+	return entries, skipped
+}
+
+// packConfigMaps distributes the entries over as few ConfigMaps as possible,
+// keeping their order. The first ConfigMap is named opts.Name, the following
+// ones <name>-part-1, <name>-part-2, ...
+func packConfigMaps(entries []configMapEntry, opts ConfigMapOptions) ([]*corev1.ConfigMap, []*storedArtifact) {
+	newConfigMap := func(index int) *corev1.ConfigMap {
+		name := opts.Name
+		if index > 0 {
+			name = fmt.Sprintf("%s-part-%d", opts.Name, index)
+		}
+		labels := map[string]string{reportLabel: opts.Name}
+		for k, v := range opts.Labels {
+			labels[k] = v
+		}
+		return &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: opts.Namespace, Labels: labels},
+			Data:       map[string]string{},
+			BinaryData: map[string][]byte{},
+		}
+	}
+
+	configMaps := []*corev1.ConfigMap{newConfigMap(0)}
+	var artifacts []*storedArtifact
+	byName := map[string]*storedArtifact{}
+	used := 0
+
+	for _, e := range entries {
+		if used+len(e.data) > configMapBudget && used > 0 {
+			configMaps = append(configMaps, newConfigMap(len(configMaps)))
+			used = 0
+		}
+		cm := configMaps[len(configMaps)-1]
+		if e.binary {
+			cm.BinaryData[e.key] = e.data
+		} else {
+			cm.Data[e.key] = string(e.data)
+		}
+		used += len(e.data)
+
+		sa, ok := byName[e.artifact.Name]
+		if !ok {
+			sa = &storedArtifact{file: e.artifact, binary: e.binary}
+			byName[e.artifact.Name] = sa
+			artifacts = append(artifacts, sa)
+		}
+		sa.pieces = append(sa.pieces, storedPiece{configMap: cm.Name, key: e.key})
+	}
+
+	if len(configMaps) > 1 {
+		configMaps[0].Annotations = map[string]string{
+			reportLabel + "-parts": fmt.Sprintf("%d", len(configMaps)-1),
+		}
+	}
+	return configMaps, artifacts
+}
+
+// writeReportConfigMaps creates or updates the report ConfigMaps and removes
+// parts left over from a previous run.
+func writeReportConfigMaps(ctx context.Context, clientset kubernetes.Interface, configMaps []*corev1.ConfigMap, opts ConfigMapOptions) error {
+	cmClient := clientset.CoreV1().ConfigMaps(opts.Namespace)
+	written := map[string]bool{}
+
+	for _, cm := range configMaps {
+		// Create or Update
+		_, err := cmClient.Create(ctx, cm, metav1.CreateOptions{})
+		if apierrors.IsAlreadyExists(err) {
+			_, err = cmClient.Update(ctx, cm, metav1.UpdateOptions{})
+		}
+		if err != nil {
+			return fmt.Errorf("failed to write ConfigMap %s/%s: %w", opts.Namespace, cm.Name, err)
+		}
+		written[cm.Name] = true
+	}
+
+	stale, err := cmClient.List(ctx, metav1.ListOptions{LabelSelector: reportLabel + "=" + opts.Name})
+	if err != nil {
+		log.Printf("Could not list previous report ConfigMaps for cleanup: %v", err)
+		return nil
+	}
+	for _, cm := range stale.Items {
+		if written[cm.Name] {
+			continue
+		}
+		if err := cmClient.Delete(ctx, cm.Name, metav1.DeleteOptions{}); err != nil && !apierrors.IsNotFound(err) {
+			log.Printf("Could not delete stale report ConfigMap %s: %v", cm.Name, err)
+		}
+	}
+	return nil
+}
+
+// exportCommand returns a shell command that restores an artifact from the ConfigMaps.
+func exportCommand(sa *storedArtifact, namespace string) string {
+	if !sa.binary {
+		p := sa.pieces[0]
+		return fmt.Sprintf("kubectl get configmap %s -n %s -o go-template='{{ index .data \"%s\" }}' > %s",
+			p.configMap, namespace, p.key, sa.file.Name)
+	}
+
+	var gets []string
+	for _, p := range sa.pieces {
+		gets = append(gets, fmt.Sprintf("kubectl get configmap %s -n %s -o go-template='{{ index .binaryData \"%s\" }}' | base64 -d",
+			p.configMap, namespace, p.key))
+	}
+	if len(gets) == 1 {
+		return fmt.Sprintf("%s | gunzip > %s", gets[0], sa.file.Name)
+	}
+	return fmt.Sprintf("( %s ) | gunzip > %s", strings.Join(gets, "; "), sa.file.Name)
+}
+
+func gzipBytes(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(data); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func configMapNames(configMaps []*corev1.ConfigMap) []string {
+	names := make([]string, 0, len(configMaps))
+	for _, cm := range configMaps {
+		names = append(names, cm.Name)
+	}
+	return names
+}
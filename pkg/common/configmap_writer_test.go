@@ -0,0 +1,174 @@
+package common
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/base64"
+	"io"
+	"math/rand"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+var testConfigMapOptions = ConfigMapOptions{
+	Name:      "report",
+	Namespace: "kubescape",
+	Labels:    map[string]string{"team": "platform"},
+	Mode:      ConfigMapModeAuto,
+}
+
+// incompressible returns about n bytes of text that gzip barely shrinks.
+func incompressible(n int) string {
+	raw := make([]byte, n*3/4)
+	rand.New(rand.NewSource(1)).Read(raw)
+	return base64.StdEncoding.EncodeToString(raw)
+}
+
+func gunzip(t *testing.T, data []byte) string {
+	t.Helper()
+	zr, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := io.ReadAll(zr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(out)
+}
+
+func TestPackConfigMapsFits(t *testing.T) {
+	files := []OutputFile{
+		{Artifact: ArtifactJSON, Name: "report.json", Content: `{"ok":true}`},
+		{Artifact: ArtifactValues, Name: "values.yaml", Content: "nodeAgent: {}\n"},
+	}
+	entries, skipped := buildConfigMapEntries(files, ConfigMapModeAuto)
+	if len(skipped) != 0 {
+		t.Fatalf("skipped = %v", skipped)
+	}
+	configMaps, stored := packConfigMaps(entries, testConfigMapOptions)
+	if len(configMaps) != 1 {
+		t.Fatalf("got %d ConfigMaps, want 1", len(configMaps))
+	}
+	cm := configMaps[0]
+	if cm.Name != "report" || cm.Namespace != "kubescape" || cm.Annotations != nil {
+		t.Errorf("ConfigMap = %s/%s, annotations %v", cm.Namespace, cm.Name, cm.Annotations)
+	}
+	if cm.Labels[reportLabel] != "report" || cm.Labels["team"] != "platform" {
+		t.Errorf("labels = %v", cm.Labels)
+	}
+	if cm.Data["report.json"] != `{"ok":true}` || len(cm.BinaryData) != 0 {
+		t.Errorf("data = %v, binaryData = %v", cm.Data, cm.BinaryData)
+	}
+
+	want := `kubectl get configmap report -n kubescape -o go-template='{{ index .data "report.json" }}' > report.json`
+	if got := exportCommand(stored[0], "kubescape"); got != want {
+		t.Errorf("exportCommand() =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestPackConfigMapsGzip(t *testing.T) {
+	files := []OutputFile{{Artifact: ArtifactJSON, Name: "report.json", Content: `{"ok":true}`}}
+	entries, _ := buildConfigMapEntries(files, ConfigMapModeGzip)
+	configMaps, stored := packConfigMaps(entries, testConfigMapOptions)
+
+	data, ok := configMaps[0].BinaryData["report.json.gz"]
+	if !ok {
+		t.Fatalf("binaryData = %v, want report.json.gz", configMaps[0].BinaryData)
+	}
+	if got := gunzip(t, data); got != `{"ok":true}` {
+		t.Errorf("gunzipped = %q", got)
+	}
+
+	want := `kubectl get configmap report -n kubescape -o go-template='{{ index .binaryData "report.json.gz" }}' | base64 -d | gunzip > report.json`
+	if got := exportCommand(stored[0], "kubescape"); got != want {
+		t.Errorf("exportCommand() =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestPackConfigMapsSplit(t *testing.T) {
+	content := incompressible(configMapBudget * 3 / 2)
+	files := []OutputFile{{Artifact: ArtifactDump, Name: "dump.yaml", Content: content}}
+
+	if _, skipped := buildConfigMapEntries(files, ConfigMapModePlain); len(skipped) != 1 {
+		t.Errorf("plain mode: skipped = %d artifacts, want 1", len(skipped))
+	}
+
+	entries, skipped := buildConfigMapEntries(files, ConfigMapModeAuto)
+	if len(skipped) != 0 {
+		t.Fatalf("skipped = %v", skipped)
+	}
+	configMaps, stored := packConfigMaps(entries, testConfigMapOptions)
+	if len(configMaps) != 2 {
+		t.Fatalf("got %d ConfigMaps, want 2", len(configMaps))
+	}
+	if configMaps[1].Name != "report-part-1" || configMaps[1].Labels[reportLabel] != "report" {
+		t.Errorf("part = %s, labels %v", configMaps[1].Name, configMaps[1].Labels)
+	}
+	if got := configMaps[0].Annotations[reportLabel+"-parts"]; got != "1" {
+		t.Errorf("parts annotation = %q, want 1", got)
+	}
+
+	// The chunks, concatenated in order, are the gzipped artifact
+	var gz []byte
+	for _, p := range stored[0].pieces {
+		for _, cm := range configMaps {
+			if cm.Name == p.configMap {
+				if len(cm.BinaryData[p.key]) > configMapBudget {
+					t.Errorf("%s/%s exceeds the budget", cm.Name, p.key)
+				}
+				gz = append(gz, cm.BinaryData[p.key]...)
+			}
+		}
+	}
+	if got := gunzip(t, gz); got != content {
+		t.Errorf("reassembled artifact differs: %d bytes, want %d", len(got), len(content))
+	}
+
+	want := `( kubectl get configmap report -n kubescape -o go-template='{{ index .binaryData "dump.yaml.gz.000" }}' | base64 -d; ` +
+		`kubectl get configmap report-part-1 -n kubescape -o go-template='{{ index .binaryData "dump.yaml.gz.001" }}' | base64 -d ) | gunzip > dump.yaml`
+	if got := exportCommand(stored[0], "kubescape"); got != want {
+		t.Errorf("exportCommand() =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestWriteReportConfigMapsRemovesStaleParts(t *testing.T) {
+	existing := func(name, report string) *corev1.ConfigMap {
+		return &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{
+			Name: name, Namespace: "kubescape", Labels: map[string]string{reportLabel: report},
+		}}
+	}
+	// A previous, larger run left two parts behind
+	clientset := fake.NewClientset(
+		existing("report", "report"),
+		existing("report-part-1", "report"),
+		existing("report-part-2", "report"),
+		existing("other-part-1", "other"),
+	)
+
+	files := []OutputFile{{Artifact: ArtifactJSON, Name: "report.json", Content: `{"ok":true}`}}
+	entries, _ := buildConfigMapEntries(files, ConfigMapModeAuto)
+	configMaps, _ := packConfigMaps(entries, testConfigMapOptions)
+	if err := writeReportConfigMaps(context.Background(), clientset, configMaps, testConfigMapOptions); err != nil {
+		t.Fatal(err)
+	}
+
+	list, err := clientset.CoreV1().ConfigMaps("kubescape").List(context.Background(), metav1.ListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, cm := range list.Items {
+		names = append(names, cm.Name)
+		if cm.Name == "report" && cm.Data["report.json"] != `{"ok":true}` {
+			t.Errorf("report was not updated: %v", cm.Data)
+		}
+	}
+	if len(names) != 2 || names[0] != "other-part-1" || names[1] != "report" {
+		t.Errorf("ConfigMaps after the rerun = %v, want [other-part-1 report]", names)
+	}
+}
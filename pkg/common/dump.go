@@ -0,0 +1,37 @@
+package common
+
+import (
+	"fmt"
+	"os"
+
+	"sigs.k8s.io/yaml"
+)
+
+// LoadClusterDataFromDump reads a full-cluster-dump.yaml written by
+// BuildFullDumpYAML, so the checks can be re-run without cluster access.
+func LoadClusterDataFromDump(path string) (*ClusterData, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read cluster dump %s: %w", path, err)
+	}
+
+	cd := &ClusterData{}
+	if err := yaml.Unmarshal(raw, cd); err != nil {
+		return nil, fmt.Errorf("failed to parse cluster dump %s: %w", path, err)
+	}
+	// A dump without nodes is only valid when listing them failed, e.g.
+	// with a forbidden error recorded in the collection statistics
+	if len(cd.Nodes) == 0 && len(cd.MissingResources(ResourceNodes)) == 0 {
+		return nil, fmt.Errorf("cluster dump %s contains no nodes", path)
+	}
+
+	// Older dumps may lack the derived summaries; rebuild them from the nodes
+	if cd.NodeInfoSummaries.OperatingSystemCounts == nil {
+		gatherNodeInfoSummaries(&cd.NodeInfoSummaries, cd.Nodes)
+	}
+	if cd.ClusterDetails.TotalNodeCount == 0 {
+		cd.ClusterDetails.TotalNodeCount = len(cd.Nodes)
+	}
+
+	return cd, nil
+}
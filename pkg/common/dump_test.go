@@ -0,0 +1,48 @@
+package common
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadClusterDataFromDump(t *testing.T) {
+	forbidden := &CollectionStats{Resources: []ResourceCollection{
+		{Kind: ResourceNodes, Status: CollectionForbidden, Error: "nodes is forbidden"},
+		{Kind: ResourcePods, Status: CollectionOK},
+	}}
+	tests := []struct {
+		name    string
+		cd      *ClusterData
+		wantErr string
+	}{
+		{name: "forbidden nodes", cd: &ClusterData{Collection: forbidden}},
+		{name: "no nodes", cd: &ClusterData{}, wantErr: "contains no nodes"},
+		{name: "nodes collected but empty", cd: &ClusterData{Collection: &CollectionStats{Resources: []ResourceCollection{
+			{Kind: ResourceNodes, Status: CollectionOK},
+		}}}, wantErr: "contains no nodes"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "full-cluster-dump.yaml")
+			if err := os.WriteFile(path, []byte(BuildFullDumpYAML(tt.cd)), 0o600); err != nil {
+				t.Fatal(err)
+			}
+			cd, err := LoadClusterDataFromDump(path)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("LoadClusterDataFromDump() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			missing := cd.MissingResources(ResourceNodes)
+			if len(missing) != 1 || missing[0].Status != CollectionForbidden {
+				t.Errorf("MissingResources(nodes) = %+v, want the forbidden nodes", missing)
+			}
+		})
+	}
+}
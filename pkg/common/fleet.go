@@ -0,0 +1,232 @@
+package common
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"text/tabwriter"
+	"time"
+)
+
+// FleetSchemaVersion is the version of the fleet-summary.json layout.
+const FleetSchemaVersion = "1.0"
+
+// Files written next to the per-cluster report directories of a multi-cluster run.
+const (
+	FleetSummaryHTMLName = "fleet-summary.html"
+	FleetSummaryJSONName = "fleet-summary.json"
+)
+
+// FleetEntry is the outcome of one cluster of a multi-cluster run.
+type FleetEntry struct {
+	// Cluster is the kubeconfig context the cluster was reached through.
+	Cluster string
+	// Report is nil when the cluster could not be checked; Error says why.
+	Report *ReportData
+	Error  string
+	// OutputError says why the artifacts of a checked cluster could not be
+	// written or stored.
+	OutputError string
+	// ExitCode is the exit code a single-cluster run would have returned.
+	ExitCode int
+	// OutputDir is the directory the cluster's artifacts were written to.
+	OutputDir string
+}
+
+// FleetExitCode returns the highest exit code of the clusters, so a failed
+// check in any cluster fails the whole run.
+func FleetExitCode(entries []FleetEntry) int {
+	code := ExitOK
+	for _, e := range entries {
+		if e.ExitCode > code {
+			code = e.ExitCode
+		}
+	}
+	return code
+}
+
+// FleetReport compares the clusters of a multi-cluster run side by side.
+type FleetReport struct {
+	SchemaVersion  string `json:"schemaVersion"`
+	GenerationTime string `json:"generationTime"`
+	// CheckNames lists every check that ran on any cluster, in run order.
+	CheckNames []string       `json:"checkNames"`
+	Clusters   []FleetCluster `json:"clusters"`
+
+	// ReportFileName is the name of the per-cluster HTML report the page links to.
+	ReportFileName string `json:"-"`
+}
+
+// FleetCluster is one cluster of a FleetReport, built from its ReportData.
+type FleetCluster struct {
+	Name        string `json:"name"`
+	Error       string `json:"error,omitempty"`
+	OutputError string `json:"outputError,omitempty"`
+	ExitCode    int    `json:"exitCode"`
+	// ReportDir is the directory of the cluster's own reports, relative to
+	// the fleet summary.
+	ReportDir  string `json:"reportDir,omitempty"`
+	DumpSource string `json:"dumpSource,omitempty"`
+
+	KubernetesVersion       string `json:"kubernetesVersion,omitempty"`
+	CloudProvider           string `json:"cloudProvider,omitempty"`
+	TotalNodeCount          int    `json:"totalNodeCount"`
+	TotalVCPUCount          int    `json:"totalVCPUCount"`
+	TotalResources          int    `json:"totalResources"`
+	MaxNodeCPUCapacity      int    `json:"maxNodeCPUCapacity"`
+	MaxNodeMemoryMB         int    `json:"maxNodeMemoryMB"`
+	LargestContainerImageMB int    `json:"largestContainerImageMB"`
+	SizingConfidence        string `json:"sizingConfidence,omitempty"`
+	HasSizingAdjustments    bool   `json:"hasSizingAdjustments"`
+
+	// Checks holds the verdict of each check by name; FailedChecks lists the
+	// checks that reported Fail or Error.
+	Checks       map[string]*FleetCheck `json:"checks,omitempty"`
+	FailedChecks []string               `json:"failedChecks,omitempty"`
+
+	// RecommendedValues is the cluster's recommended-values.yaml.
+	RecommendedValues string `json:"recommendedValues,omitempty"`
+}
+
+// FleetCheck is the verdict of one check on one cluster.
+type FleetCheck struct {
+	Status  CheckStatus `json:"status"`
+	Reason  string      `json:"reason,omitempty"`
+	Message string      `json:"message,omitempty"`
+}
+
+// BuildFleetReport aggregates the per-cluster reports of a multi-cluster run.
+// The fleet summary is written to opts.Dir; report directories are recorded
+// relative to it.
+func BuildFleetReport(entries []FleetEntry, opts OutputOptions) *FleetReport {
+	fleet := &FleetReport{
+		SchemaVersion:  FleetSchemaVersion,
+		GenerationTime: time.Now().Format("2006-01-02 15:04:05"),
+		CheckNames:     []string{},
+		Clusters:       make([]FleetCluster, 0, len(entries)),
+	}
+	if opts.Formats[ArtifactHTML] {
+		fleet.ReportFileName = opts.FileNames[ArtifactHTML]
+	}
+	seen := map[string]bool{}
+	for _, e := range entries {
+		c := FleetCluster{Name: e.Cluster, Error: e.Error, OutputError: e.OutputError, ExitCode: e.ExitCode}
+		if r := e.Report; r != nil {
+			if rel, err := filepath.Rel(opts.Dir, e.OutputDir); err == nil && e.OutputDir != "" {
+				c.ReportDir = filepath.ToSlash(rel)
+			}
+			c.DumpSource = r.DumpSource
+			c.KubernetesVersion = r.KubernetesVersion
+			c.CloudProvider = r.CloudProvider
+			c.TotalNodeCount = r.TotalNodeCount
+			c.TotalVCPUCount = r.TotalVCPUCount
+			c.TotalResources = r.TotalResources
+			c.MaxNodeCPUCapacity = r.MaxNodeCPUCapacity
+			c.MaxNodeMemoryMB = r.MaxNodeMemoryMB
+			c.LargestContainerImageMB = r.LargestContainerImageMB
+			c.SizingConfidence = r.SizingConfidence
+			c.HasSizingAdjustments = r.HasSizingAdjustments
+			c.RecommendedValues = BuildValuesYAML(r)
+
+			c.Checks = map[string]*FleetCheck{}
+			for _, res := range r.CheckResults {
+				c.Checks[res.Name] = &FleetCheck{Status: res.Status, Reason: res.Reason, Message: res.Message}
+				if res.Status == StatusFail || res.Status == StatusError {
+					c.FailedChecks = append(c.FailedChecks, res.Name)
+				}
+				if !seen[res.Name] {
+					seen[res.Name] = true
+					fleet.CheckNames = append(fleet.CheckNames, res.Name)
+				}
+			}
+		}
+		fleet.Clusters = append(fleet.Clusters, c)
+	}
+	return fleet
+}
+
+// BuildFleetJSON renders the fleet report as fleet-summary.json.
+func BuildFleetJSON(fleet *FleetReport) string {
+	j, err := json.MarshalIndent(fleet, "", "  ")
+	if err != nil {
+		return fmt.Sprintf("Error building fleet summary: %v", err)
+	}
+	return string(j) + "\n"
+}
+
+// WriteFleetSummary writes the fleet summary page and/or JSON, following the
+// html and json formats of opts, into opts.Dir.
+func WriteFleetSummary(entries []FleetEntry, opts OutputOptions) error {
+	fleet := BuildFleetReport(entries, opts)
+
+	var files []OutputFile
+	if opts.Formats[ArtifactHTML] {
+		files = append(files, OutputFile{Artifact: ArtifactHTML, Name: FleetSummaryHTMLName, Content: BuildHTMLReport(fleet, FleetSummaryHTML)})
+	}
+	if opts.Formats[ArtifactJSON] {
+		files = append(files, OutputFile{Artifact: ArtifactJSON, Name: FleetSummaryJSONName, Content: BuildFleetJSON(fleet)})
+	}
+	if len(files) == 0 {
+		return nil
+	}
+	if err := os.MkdirAll(opts.Dir, 0755); err != nil {
+		return fmt.Errorf("failed to create output directory %s: %w", opts.Dir, err)
+	}
+	for _, f := range files {
+		path := filepath.Join(opts.Dir, f.Name)
+		if err := os.WriteFile(path, []byte(f.Content), 0644); err != nil {
+			return fmt.Errorf("failed to write fleet summary to %s: %w", path, err)
+		}
+		fmt.Println("📋 Fleet summary:", path)
+	}
+	return nil
+}
+
+// PrintFleetSummary prints one line per cluster with its node count, sizing
+// inputs and check verdicts.
+func PrintFleetSummary(entries []FleetEntry) {
+	printSeparator()
+	fmt.Printf("🌐 Fleet summary (%d clusters)\n", len(entries))
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "   CLUSTER\tNODES\tRESOURCES\tPASS\tWARN\tFAIL\tERROR\tEXIT\tREPORT")
+	for _, e := range entries {
+		if e.Report == nil {
+			fmt.Fprintf(w, "   %s\t-\t-\t-\t-\t-\t-\t%d\t%s\n", e.Cluster, e.ExitCode, e.Error)
+			continue
+		}
+		counts := map[CheckStatus]int{}
+		for _, r := range e.Report.CheckResults {
+			counts[r.Status]++
+		}
+		fmt.Fprintf(w, "   %s\t%d\t%d\t%d\t%d\t%d\t%d\t%d\t%s\n", e.Cluster,
+			e.Report.TotalNodeCount, e.Report.TotalResources,
+			counts[StatusPass], counts[StatusWarn], counts[StatusFail], counts[StatusError],
+			e.ExitCode, fleetReportColumn(e))
+	}
+	w.Flush()
+	printSeparator()
+}
+
+// fleetReportColumn is the REPORT column of a checked cluster: its output
+// directory, or why its artifacts could not be written.
+func fleetReportColumn(e FleetEntry) string {
+	if e.OutputError != "" {
+		return e.OutputError
+	}
+	return e.OutputDir
+}
+
+var unsafeFileChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// SafeFileName turns a context name such as
+// "arn:aws:eks:eu-west-1:123456789012:cluster/prod" into a name usable as a
+// file or directory name.
+func SafeFileName(name string) string {
+	safe := unsafeFileChars.ReplaceAllString(name, "_")
+	if safe == "" || safe == "." || safe == ".." {
+		return "_"
+	}
+	return safe
+}
@@ -0,0 +1,130 @@
+package common
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSafeFileName(t *testing.T) {
+	tests := map[string]string{
+		"kind-dev": "kind-dev",
+		"arn:aws:eks:eu-west-1:123456789012:cluster/prod": "arn_aws_eks_eu-west-1_123456789012_cluster_prod",
+		"gke_project_europe-west1_main":                   "gke_project_europe-west1_main",
+		"..":                                              "_",
+		"":                                                "_",
+	}
+	for in, want := range tests {
+		if got := SafeFileName(in); got != want {
+			t.Errorf("SafeFileName(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestFleetExitCode(t *testing.T) {
+	entries := []FleetEntry{
+		{Cluster: "a", ExitCode: ExitOK},
+		{Cluster: "b", ExitCode: ExitRuntimeError},
+		{Cluster: "c", ExitCode: ExitCheckWarn},
+	}
+	if got := FleetExitCode(entries); got != ExitCheckWarn {
+		t.Errorf("FleetExitCode() = %d, want %d", got, ExitCheckWarn)
+	}
+	if got := FleetExitCode(nil); got != ExitOK {
+		t.Errorf("FleetExitCode(nil) = %d, want %d", got, ExitOK)
+	}
+}
+
+func TestBuildFleetReport(t *testing.T) {
+	prod := &ReportData{
+		TotalNodeCount:          12,
+		TotalResources:          3400,
+		LargestContainerImageMB: 2100,
+		DefaultResourceAllocations: map[string]map[string]string{
+			"nodeAgent": {"memReq": "180Mi"},
+		},
+		FinalResourceAllocations: map[string]map[string]string{
+			"nodeAgent": {"memReq": "512Mi"},
+		},
+		HasSizingAdjustments: true,
+		CheckResults: []*CheckResult{
+			{Name: SizingCheckName, Status: StatusWarn},
+			{Name: PVProvisioningCheckName, Status: StatusFail, Reason: "NoDefaultStorageClass"},
+		},
+	}
+	dev := &ReportData{
+		TotalNodeCount: 2,
+		CheckResults: []*CheckResult{
+			{Name: SizingCheckName, Status: StatusPass},
+			{Name: EbpfCheckName, Status: StatusError},
+		},
+	}
+	opts := OutputOptions{
+		Dir:       "/out",
+		Formats:   map[Artifact]bool{ArtifactHTML: true},
+		FileNames: map[Artifact]string{ArtifactHTML: "prerequisites-report.html"},
+	}
+	fleet := BuildFleetReport([]FleetEntry{
+		{Cluster: "prod", Report: prod, ExitCode: ExitCheckFail, OutputDir: "/out/prod"},
+		{Cluster: "dev", Report: dev, OutputDir: "/out/dev", OutputError: "disk full"},
+		{Cluster: "qa", Error: "cannot access cluster", ExitCode: ExitRuntimeError},
+	}, opts)
+
+	wantChecks := []string{SizingCheckName, PVProvisioningCheckName, EbpfCheckName}
+	if !reflect.DeepEqual(fleet.CheckNames, wantChecks) {
+		t.Errorf("CheckNames = %v, want %v", fleet.CheckNames, wantChecks)
+	}
+	if len(fleet.Clusters) != 3 {
+		t.Fatalf("got %d clusters, want 3", len(fleet.Clusters))
+	}
+
+	c := fleet.Clusters[0]
+	if c.ReportDir != "prod" || c.TotalNodeCount != 12 || c.TotalResources != 3400 || c.LargestContainerImageMB != 2100 {
+		t.Errorf("prod = %+v", c)
+	}
+	if !reflect.DeepEqual(c.FailedChecks, []string{PVProvisioningCheckName}) {
+		t.Errorf("prod.FailedChecks = %v", c.FailedChecks)
+	}
+	if !strings.Contains(c.RecommendedValues, "memory: 512Mi") {
+		t.Errorf("prod.RecommendedValues = %q, want the node-agent memory request", c.RecommendedValues)
+	}
+	if got := fleet.Clusters[1].FailedChecks; !reflect.DeepEqual(got, []string{EbpfCheckName}) {
+		t.Errorf("dev.FailedChecks = %v", got)
+	}
+	if qa := fleet.Clusters[2]; qa.ReportDir != "" || qa.Error == "" || qa.Checks != nil {
+		t.Errorf("qa = %+v, want only the error", qa)
+	}
+
+	html := BuildHTMLReport(fleet, FleetSummaryHTML)
+	for _, want := range []string{`<a href="prod/prerequisites-report.html">prod</a>`, "Not checked: cannot access cluster", "Reports not written: disk full", "memory: 512Mi"} {
+		if !strings.Contains(html, want) {
+			t.Errorf("fleet HTML does not contain %q", want)
+		}
+	}
+
+	var decoded FleetReport
+	if err := json.Unmarshal([]byte(BuildFleetJSON(fleet)), &decoded); err != nil {
+		t.Fatalf("fleet JSON does not parse: %v", err)
+	}
+	if decoded.SchemaVersion != FleetSchemaVersion || decoded.Clusters[0].Checks[PVProvisioningCheckName].Status != StatusFail {
+		t.Errorf("decoded fleet JSON = %+v", decoded)
+	}
+}
+
+func TestWriteFleetSummaryError(t *testing.T) {
+	// A file where the output directory should be cannot be written into
+	dir := filepath.Join(t.TempDir(), "fleet")
+	if err := os.WriteFile(dir, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	opts := OutputOptions{Dir: dir, Formats: map[Artifact]bool{ArtifactJSON: true}}
+	if err := WriteFleetSummary([]FleetEntry{{Cluster: "dev"}}, opts); err == nil {
+		t.Error("WriteFleetSummary() succeeded, want an error")
+	}
+	if err := WriteToDisk([]OutputFile{{Artifact: ArtifactJSON, Name: "report.json"}}, opts); err == nil {
+		t.Error("WriteToDisk() succeeded, want an error")
+	}
+}
@@ -1,37 +1,24 @@
 package common
 
 import (
+	"encoding/json"
 	"fmt"
 	"html/template"
 	"sort"
 	"strings"
 	"time"
 
-	"gopkg.in/yaml.v3"
+	sigsyaml "sigs.k8s.io/yaml"
 )
 
 // BuildReportData constructs a ReportData struct from the cluster details and check results.
 // It processes resource allocations, node information, and storage class data to generate
 // a comprehensive report for the user.
-func BuildReportData(
-	cd *ClusterData,
-	sr *SizingResult,
-	pr *PVCheckResult,
-	ccr *ConnectivityResult,
-	er *EbpfResult,
-) *ReportData {
+func BuildReportData(cd *ClusterData, results []*CheckResult) *ReportData {
 
 	report := &ReportData{
-		// Sizing data
-		TotalResources:             sr.TotalResources,
-		MaxNodeCPUCapacity:         sr.MaxNodeCPUCapacity,
-		MaxNodeMemoryMB:            sr.MaxNodeMemoryMB,
-		LargestContainerImageMB:    sr.LargestContainerImageMB,
-		DefaultResourceAllocations: sr.DefaultResourceAllocations,
-		FinalResourceAllocations:   sr.FinalResourceAllocations,
-		HasSizingAdjustments:       sr.HasSizingAdjustments,
-
 		// Basic cluster details
+		ClusterName:       cd.ClusterDetails.Name,
 		KubernetesVersion: cd.ClusterDetails.Version,
 		CloudProvider:     cd.ClusterDetails.CloudProvider,
 		K8sDistribution:   cd.ClusterDetails.K8sDistribution,
@@ -41,9 +28,46 @@ func BuildReportData(
 		GenerationTime:  time.Now().Format("2006-01-02 15:04:05"),
 		FullClusterData: cd,
 
-		PVProvisioningMessage:    pr.ResultMessage,
-		ConnectivityCheckMessage: ccr.ResultMessage,
-		EBPFResultMessage:        er.ResultMessage,
+		CheckResults: results,
+		Collection:   cd.Collection,
+		NodeProbes:   cd.NodeProbes,
+	}
+
+	// Pick up the well-known results that feed the summary and the Helm values
+	if r := FindCheckResult(results, SizingCheckName); r != nil {
+		if sr, ok := r.Details.(*SizingResult); ok {
+			report.TotalResources = sr.TotalResources
+			report.MaxNodeCPUCapacity = sr.MaxNodeCPUCapacity
+			report.MaxNodeMemoryMB = sr.MaxNodeMemoryMB
+			report.LargestContainerImageMB = sr.LargestContainerImageMB
+			report.DefaultResourceAllocations = sr.DefaultResourceAllocations
+			report.FinalResourceAllocations = sr.FinalResourceAllocations
+			report.HasSizingAdjustments = sr.HasSizingAdjustments
+			report.SizingConfidence = sr.Confidence
+			report.MissingInputs = sr.MissingInputs
+			report.SizingProfileVersion = sr.ProfileVersion
+			report.NodeAgentPools = sr.NodeAgentPools
+			report.MultipleNodeAgentDaemonSets = sr.MultipleNodeAgentDaemonSets
+		}
+	}
+	if r := FindCheckResult(results, PVProvisioningCheckName); r != nil {
+		report.PVProvisioningStatus = r.Status
+	}
+	for _, name := range []string{PlatformCheckName, TaintCheckName} {
+		if r := FindCheckResult(results, name); r != nil {
+			if s, ok := r.Details.(*SchedulingAdvice); ok {
+				if report.Scheduling == nil {
+					report.Scheduling = &SchedulingAdvice{}
+				}
+				report.Scheduling.Merge(s)
+			}
+		}
+	}
+	if r := FindCheckResult(results, EbpfCheckName); r != nil {
+		if nf, ok := r.Details.([]NodeFeatures); ok && len(nf) > 0 {
+			report.KernelFeatures = KernelFeatures
+			report.NodeFeatures = nf
+		}
 	}
 
 	// Extract storage class names
@@ -63,25 +87,51 @@ func BuildReportData(
 	report.NodeContainerRuntimeSummary = summarizeMap(ni.ContainerRuntimeVersionCounts, totalNodes)
 	report.NodeKubeletVersionSummary = summarizeMap(ni.KubeletVersionCounts, totalNodes)
 	report.NodeKubeProxyVersionSummary = summarizeMap(ni.KubeProxyVersionCounts, totalNodes)
+	report.NodeInfo = ni
 
 	return report
 }
 
+// BuildFullDumpYAML serializes the collected cluster data. Kubernetes objects are
+// encoded through their JSON tags, so the dump can be read back with
+// LoadClusterDataFromDump (see --from-dump).
 func BuildFullDumpYAML(cd *ClusterData) string {
 	if cd == nil {
 		return "Error building full cluster dump: cluster data is nil"
 	}
-	y, err := yaml.Marshal(cd)
+	y, err := sigsyaml.Marshal(cd)
 	if err != nil {
 		return fmt.Sprintf("Error building full cluster dump: %v", err)
 	}
 	return string(y)
 }
 
-func BuildHTMLReport(data *ReportData, tpl string) string {
+// BuildJSONReport renders the report as a versioned JSON document following ReportJSONSchema.
+func BuildJSONReport(data *ReportData) string {
+	j, err := json.MarshalIndent(JSONReport{SchemaVersion: ReportSchemaVersion, ReportData: data}, "", "  ")
+	if err != nil {
+		return fmt.Sprintf("Error building JSON report: %v", err)
+	}
+	return string(j) + "\n"
+}
+
+// BuildYAMLReport renders the same versioned document as BuildJSONReport, in YAML.
+func BuildYAMLReport(data *ReportData) string {
+	y, err := sigsyaml.Marshal(JSONReport{SchemaVersion: ReportSchemaVersion, ReportData: data})
+	if err != nil {
+		return fmt.Sprintf("Error building YAML report: %v", err)
+	}
+	return string(y)
+}
+
+// BuildHTMLReport renders tpl with data, usually a *ReportData with
+// PrerequisitesReportHTML or a *FleetReport with FleetSummaryHTML.
+func BuildHTMLReport(data any, tpl string) string {
 	// Create a FuncMap and include any functions you want to use in your template
 	funcMap := template.FuncMap{
 		"hasPrefix": strings.HasPrefix,
+		"duration":  formatMillis,
+		"add":       func(a, b int) int { return a + b },
 	}
 
 	// Parse your template with FuncMap
@@ -111,13 +161,13 @@ func BuildReviewValuesHTML(data *ReportData, helmValuesContent string) string {
 
 	// Execute the template with the YAML content and storage classes
 	templateData := struct {
-		RecommendedValues     string
-		StorageClasses        []string
-		PVProvisioningMessage string
+		RecommendedValues    string
+		StorageClasses       []string
+		PVProvisioningStatus CheckStatus
 	}{
-		RecommendedValues:     helmValuesContent,
-		StorageClasses:        data.StorageClasses,
-		PVProvisioningMessage: data.PVProvisioningMessage,
+		RecommendedValues:    helmValuesContent,
+		StorageClasses:       data.StorageClasses,
+		PVProvisioningStatus: data.PVProvisioningStatus,
 	}
 
 	var sb strings.Builder
@@ -133,16 +183,63 @@ func BuildReviewValuesHTML(data *ReportData, helmValuesContent string) string {
 func BuildValuesYAML(d *ReportData) string {
 	overrides := collectOverrides(d)
 
-	if len(overrides) == 0 && d.PVProvisioningMessage != "Failed" {
-		return "# no adjustments are required for the default values\n"
+	// Document the node pools whose taints are left untolerated on purpose
+	var header string
+	if d.Scheduling != nil && len(d.Scheduling.ExcludedPools) > 0 {
+		header = "# The node-agent intentionally does not tolerate the taints of node pools: " +
+			strings.Join(d.Scheduling.ExcludedPools, ", ") + "\n"
+	}
+
+	if len(overrides) == 0 && d.PVProvisioningStatus != StatusFail {
+		return header + "# no adjustments are required for the default values\n"
 	}
 
 	// Add persistence configuration if PV provisioning check failed
-	if d.PVProvisioningMessage == "Failed" {
+	if d.PVProvisioningStatus == StatusFail {
 		overrides["configurations.persistence"] = "disable"
 	}
 
-	return convertOverridesToYAML(overrides)
+	return header + convertOverridesToYAML(overrides)
+}
+
+// resourceValuePaths maps the resource keys of the sizing allocations to the
+// Helm values of a component.
+var resourceValuePaths = map[string]string{
+	"cpuReq": "resources.requests.cpu",
+	"memReq": "resources.requests.memory",
+	"cpuLim": "resources.limits.cpu",
+	"memLim": "resources.limits.memory",
+}
+
+// nodeAgentConfiguration is one node-agent DaemonSet of the chart's
+// nodeAgent.multipleDaemonSets.configurations.
+type nodeAgentConfiguration struct {
+	NodeSelector map[string]string            `json:"nodeSelector"`
+	Resources    map[string]map[string]string `json:"resources"`
+}
+
+// nodeAgentConfigurations returns a node-agent DaemonSet configuration per
+// node pool, selecting its nodes by the pool label.
+func nodeAgentConfigurations(pools []NodePoolSizing) []nodeAgentConfiguration {
+	configs := make([]nodeAgentConfiguration, 0, len(pools))
+	for _, p := range pools {
+		resources := map[string]map[string]string{}
+		for resKey, valuePath := range resourceValuePaths {
+			if v, ok := p.Resources[resKey]; ok {
+				// e.g. "resources.requests.cpu"
+				parts := strings.Split(valuePath, ".")
+				if resources[parts[1]] == nil {
+					resources[parts[1]] = map[string]string{}
+				}
+				resources[parts[1]][parts[2]] = v
+			}
+		}
+		configs = append(configs, nodeAgentConfiguration{
+			NodeSelector: map[string]string{p.Label: p.Pool},
+			Resources:    resources,
+		})
+	}
+	return configs
 }
 
 // collectOverrides gathers all the necessary Helm value overrides based on the report data.
@@ -153,103 +250,72 @@ func collectOverrides(d *ReportData) map[string]string {
 
 	// Compare default vs. final resource allocations for each component
 	for comp, defMap := range d.DefaultResourceAllocations {
-		finalMap := d.FinalResourceAllocations[comp]
-		if finalMap == nil {
-			continue
+		if comp == "nodeAgent" && d.MultipleNodeAgentDaemonSets {
+			continue // sized per DaemonSet below, not for the largest node
 		}
-
-		// CPU request override
-		if finalCPUReq, ok := finalMap["cpuReq"]; ok {
-			if defaultCPUReq, okDef := defMap["cpuReq"]; okDef && finalCPUReq != defaultCPUReq {
-				overrides[fmt.Sprintf("%s.resources.requests.cpu", comp)] = finalCPUReq
-			}
-		}
-
-		// Memory request override
-		if finalMemReq, ok := finalMap["memReq"]; ok {
-			if defaultMemReq, okDef := defMap["memReq"]; okDef && finalMemReq != defaultMemReq {
-				overrides[fmt.Sprintf("%s.resources.requests.memory", comp)] = finalMemReq
+		finalMap := d.FinalResourceAllocations[comp]
+		for resKey, valuePath := range resourceValuePaths {
+			finalVal, ok := finalMap[resKey]
+			if defVal, okDef := defMap[resKey]; ok && okDef && finalVal != defVal {
+				overrides[comp+"."+valuePath] = finalVal
 			}
 		}
+	}
 
-		// CPU limit override
-		if finalCPULim, ok := finalMap["cpuLim"]; ok {
-			if defaultCPULim, okDef := defMap["cpuLim"]; okDef && finalCPULim != defaultCPULim {
-				overrides[fmt.Sprintf("%s.resources.limits.cpu", comp)] = finalCPULim
-			}
-		}
+	// Size the node-agent per node pool
+	if d.MultipleNodeAgentDaemonSets {
+		overrides["nodeAgent.multipleDaemonSets.enabled"] = "true"
+		overrides["nodeAgent.multipleDaemonSets.configurations"] = yamlBlock(nodeAgentConfigurations(d.NodeAgentPools))
+	}
 
-		// Memory limit override
-		if finalMemLim, ok := finalMap["memLim"]; ok {
-			if defaultMemLim, okDef := defMap["memLim"]; okDef && finalMemLim != defaultMemLim {
-				overrides[fmt.Sprintf("%s.resources.limits.memory", comp)] = finalMemLim
-			}
-		}
+	// Keep the components off the nodes they cannot run on
+	for k, v := range schedulingOverrides(d.Scheduling) {
+		overrides[k] = v
 	}
 
 	return overrides
 }
 
 func convertOverridesToYAML(overrides map[string]string) string {
-	// 1. Group by top-level prefix (everything up to the first ".").
-	grouped := make(map[string]map[string]string)
-	for fullKey, value := range overrides {
-		parts := strings.SplitN(fullKey, ".", 2)
-		topLevel := parts[0]
-		subKey := ""
-		if len(parts) > 1 {
-			subKey = parts[1]
-		}
-
-		if _, exists := grouped[topLevel]; !exists {
-			grouped[topLevel] = make(map[string]string)
-		}
-		grouped[topLevel][subKey] = value
+	// Sort the dotted keys so that keys sharing a prefix are adjacent.
+	keys := make([]string, 0, len(overrides))
+	for k := range overrides {
+		keys = append(keys, k)
 	}
+	sort.Strings(keys)
 
-	// 2. Sort top-level keys for consistent output.
-	var topKeys []string
-	for k := range grouped {
-		topKeys = append(topKeys, k)
-	}
-	sort.Strings(topKeys)
-
-	// 3. Build YAML for each top-level group.
+	// Write only the path segments that differ from the previous key, so
+	// shared parents (e.g. nodeAgent.resources) appear once.
 	var sb strings.Builder
-	for _, topKey := range topKeys {
-		sb.WriteString(fmt.Sprintf("%s:\n", topKey))
-
-		subMap := grouped[topKey]
-		var subKeys []string
-		for k := range subMap {
-			subKeys = append(subKeys, k)
+	var prev []string
+	for _, fullKey := range keys {
+		parts := strings.Split(fullKey, ".")
+		shared := 0
+		for shared < len(prev)-1 && shared < len(parts)-1 && prev[shared] == parts[shared] {
+			shared++
 		}
-		sort.Strings(subKeys)
-
-		for _, subKey := range subKeys {
-			val := subMap[subKey]
-			if subKey == "" {
-				// If there's no subKey, just put the value at this level (unlikely in typical usage).
-				sb.WriteString(fmt.Sprintf("  %s\n", val))
-			} else {
-				// Recursively build nested structure.
-				buildNestedYAML(&sb, "  ", subKey, val)
+		for i := shared; i < len(parts)-1; i++ {
+			sb.WriteString(fmt.Sprintf("%s%s:\n", strings.Repeat("  ", i), parts[i]))
+		}
+		last := len(parts) - 1
+		if value := overrides[fullKey]; strings.HasSuffix(value, "\n") {
+			// A structured value (see yamlBlock), nested below its key
+			sb.WriteString(fmt.Sprintf("%s%s:\n", strings.Repeat("  ", last), parts[last]))
+			for _, line := range strings.Split(strings.TrimSuffix(value, "\n"), "\n") {
+				sb.WriteString(fmt.Sprintf("%s%s\n", strings.Repeat("  ", last+1), line))
 			}
+		} else {
+			sb.WriteString(fmt.Sprintf("%s%s: %s\n", strings.Repeat("  ", last), parts[last], value))
 		}
+		prev = parts
 	}
 
 	return sb.String()
 }
 
-func buildNestedYAML(sb *strings.Builder, indent, subKey, value string) {
-	parts := strings.SplitN(subKey, ".", 2)
-	if len(parts) == 1 {
-		sb.WriteString(fmt.Sprintf("%s%s: %s\n", indent, parts[0], value))
-		return
-	}
-
-	sb.WriteString(fmt.Sprintf("%s%s:\n", indent, parts[0]))
-	buildNestedYAML(sb, indent+"  ", parts[1], value)
+// formatMillis renders a millisecond count as a duration, e.g. "1.25s".
+func formatMillis(ms int64) string {
+	return (time.Duration(ms) * time.Millisecond).String()
 }
 
 func summarizeMap(counts map[string]int, totalCount int) string {
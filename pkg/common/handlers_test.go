@@ -0,0 +1,211 @@
+package common
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestBuildValuesYAML(t *testing.T) {
+	defaults := map[string]map[string]string{
+		"nodeAgent": {"cpuReq": "100m", "cpuLim": "500m", "memReq": "180Mi", "memLim": "700Mi"},
+	}
+
+	t.Run("no adjustments", func(t *testing.T) {
+		d := &ReportData{
+			DefaultResourceAllocations: defaults,
+			FinalResourceAllocations:   defaults,
+			PVProvisioningStatus:       StatusPass,
+		}
+		if got := BuildValuesYAML(d); got != "# no adjustments are required for the default values\n" {
+			t.Errorf("unexpected values file:\n%s", got)
+		}
+	})
+
+	t.Run("changed allocations", func(t *testing.T) {
+		d := &ReportData{
+			DefaultResourceAllocations: defaults,
+			FinalResourceAllocations: map[string]map[string]string{
+				"nodeAgent": {"cpuReq": "200m", "cpuLim": "800m", "memReq": "180Mi", "memLim": "700Mi"},
+			},
+			PVProvisioningStatus: StatusPass,
+		}
+		got := BuildValuesYAML(d)
+		want := "nodeAgent:\n" +
+			"  resources:\n" +
+			"    limits:\n" +
+			"      cpu: 800m\n" +
+			"    requests:\n" +
+			"      cpu: 200m\n"
+		if got != want {
+			t.Errorf("BuildValuesYAML() =\n%s\nwant\n%s", got, want)
+		}
+		if strings.Contains(got, "memory") {
+			t.Errorf("unchanged memory values must not be overridden:\n%s", got)
+		}
+	})
+
+	t.Run("persistence disabled on PV failure", func(t *testing.T) {
+		d := &ReportData{
+			DefaultResourceAllocations: defaults,
+			FinalResourceAllocations:   defaults,
+			PVProvisioningStatus:       StatusFail,
+		}
+		got := BuildValuesYAML(d)
+		if !strings.Contains(got, "configurations:\n  persistence: disable\n") {
+			t.Errorf("expected persistence to be disabled, got:\n%s", got)
+		}
+	})
+
+	t.Run("every sized component", func(t *testing.T) {
+		d := &ReportData{
+			DefaultResourceAllocations: map[string]map[string]string{
+				"kubescape":     {"cpuReq": "250m", "memLim": "1000Mi"},
+				"otelCollector": {"cpuLim": "1", "memReq": "500Mi"},
+				"gateway":       {"memLim": "50Mi"},
+			},
+			FinalResourceAllocations: map[string]map[string]string{
+				"kubescape":     {"cpuReq": "250m", "memLim": "4300Mi"},
+				"otelCollector": {"cpuLim": "2500m", "memReq": "600Mi"},
+				"gateway":       {"memLim": "50Mi"},
+			},
+			PVProvisioningStatus: StatusPass,
+		}
+		got := BuildValuesYAML(d)
+		want := "kubescape:\n" +
+			"  resources:\n" +
+			"    limits:\n" +
+			"      memory: 4300Mi\n" +
+			"otelCollector:\n" +
+			"  resources:\n" +
+			"    limits:\n" +
+			"      cpu: 2500m\n" +
+			"    requests:\n" +
+			"      memory: 600Mi\n"
+		if got != want {
+			t.Errorf("BuildValuesYAML() =\n%s\nwant\n%s", got, want)
+		}
+	})
+
+	t.Run("node-agent per node pool", func(t *testing.T) {
+		d := &ReportData{
+			DefaultResourceAllocations: defaults,
+			// Sized for the largest node, which the per-pool DaemonSets replace
+			FinalResourceAllocations: map[string]map[string]string{
+				"nodeAgent": {"cpuReq": "800m", "cpuLim": "3200m", "memReq": "3277Mi", "memLim": "13107Mi"},
+			},
+			PVProvisioningStatus: StatusPass,
+			NodeAgentPools: []NodePoolSizing{
+				{Pool: "big", Label: "cloud.google.com/gke-nodepool", Resources: map[string]string{"cpuReq": "800m", "memReq": "3277Mi"}},
+				{Pool: "small", Label: "cloud.google.com/gke-nodepool", Resources: map[string]string{"cpuReq": "100m", "memReq": "180Mi"}},
+			},
+			MultipleNodeAgentDaemonSets: true,
+		}
+		got := BuildValuesYAML(d)
+		want := "nodeAgent:\n" +
+			"  multipleDaemonSets:\n" +
+			"    configurations:\n" +
+			"      - nodeSelector:\n" +
+			"          cloud.google.com/gke-nodepool: big\n" +
+			"        resources:\n" +
+			"          requests:\n" +
+			"            cpu: 800m\n" +
+			"            memory: 3277Mi\n" +
+			"      - nodeSelector:\n" +
+			"          cloud.google.com/gke-nodepool: small\n" +
+			"        resources:\n" +
+			"          requests:\n" +
+			"            cpu: 100m\n" +
+			"            memory: 180Mi\n" +
+			"    enabled: true\n"
+		if got != want {
+			t.Errorf("BuildValuesYAML() =\n%s\nwant\n%s", got, want)
+		}
+	})
+
+	t.Run("scheduling constraints", func(t *testing.T) {
+		d := &ReportData{
+			DefaultResourceAllocations: defaults,
+			FinalResourceAllocations: map[string]map[string]string{
+				"nodeAgent": {"cpuReq": "200m", "cpuLim": "500m", "memReq": "180Mi", "memLim": "700Mi"},
+			},
+			PVProvisioningStatus: StatusPass,
+			Scheduling:           &SchedulingAdvice{LinuxOnly: true, Architectures: []string{"amd64", "arm64"}},
+		}
+		got := BuildValuesYAML(d)
+		want := "customScheduling:\n" +
+			"  nodeSelector:\n" +
+			"    kubernetes.io/os: linux\n" +
+			"nodeAgent:\n" +
+			"  affinity:\n" +
+			"    nodeAffinity:\n" +
+			"      requiredDuringSchedulingIgnoredDuringExecution:\n" +
+			"        nodeSelectorTerms:\n" +
+			"        - matchExpressions:\n" +
+			"          - key: kubernetes.io/os\n" +
+			"            operator: In\n" +
+			"            values:\n" +
+			"            - linux\n" +
+			"          - key: kubernetes.io/arch\n" +
+			"            operator: In\n" +
+			"            values:\n" +
+			"            - amd64\n" +
+			"            - arm64\n" +
+			"  resources:\n" +
+			"    requests:\n" +
+			"      cpu: 200m\n"
+		if got != want {
+			t.Errorf("BuildValuesYAML() =\n%s\nwant\n%s", got, want)
+		}
+	})
+
+	t.Run("tolerations and excluded pools", func(t *testing.T) {
+		d := &ReportData{
+			DefaultResourceAllocations: defaults,
+			FinalResourceAllocations:   defaults,
+			PVProvisioningStatus:       StatusPass,
+			Scheduling: &SchedulingAdvice{
+				Tolerations: []corev1.Toleration{
+					{Key: "dedicated", Operator: corev1.TolerationOpEqual, Value: "infra", Effect: corev1.TaintEffectNoExecute},
+					{Key: "nvidia.com/gpu", Operator: corev1.TolerationOpExists, Effect: corev1.TaintEffectNoSchedule},
+				},
+				ExcludedPools: []string{"batch"},
+			},
+		}
+		got := BuildValuesYAML(d)
+		want := "# The node-agent intentionally does not tolerate the taints of node pools: batch\n" +
+			"nodeAgent:\n" +
+			"  tolerations:\n" +
+			"    - effect: NoExecute\n" +
+			"      key: dedicated\n" +
+			"      operator: Equal\n" +
+			"      value: infra\n" +
+			"    - effect: NoSchedule\n" +
+			"      key: nvidia.com/gpu\n" +
+			"      operator: Exists\n"
+		if got != want {
+			t.Errorf("BuildValuesYAML() =\n%s\nwant\n%s", got, want)
+		}
+	})
+}
+
+func TestBuildJSONReport(t *testing.T) {
+	d := &ReportData{
+		KubernetesVersion: "v1.31.2",
+		FullClusterData:   &ClusterData{},
+		CheckResults:      []*CheckResult{{Name: "sizing", Status: StatusPass}},
+	}
+
+	var decoded map[string]any
+	if err := json.Unmarshal([]byte(BuildJSONReport(d)), &decoded); err != nil {
+		t.Fatalf("report is not valid JSON: %v", err)
+	}
+	if decoded["schemaVersion"] != ReportSchemaVersion {
+		t.Errorf("schemaVersion = %v, want %s", decoded["schemaVersion"], ReportSchemaVersion)
+	}
+	if _, ok := decoded["fullClusterData"]; ok {
+		t.Error("the JSON report must not embed the full cluster data")
+	}
+}
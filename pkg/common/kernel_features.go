@@ -0,0 +1,244 @@
+package common
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// KernelFeature is a kernel capability the node-agent's runtime detection
+// depends on, and what is lost on nodes without it.
+type KernelFeature struct {
+	Name string `json:"name"`
+	// Flags are the kernel config options that must be set to y.
+	Flags []string `json:"flags,omitempty"`
+	// MinKernel is the oldest kernel release providing the feature, e.g. "5.8".
+	MinKernel     string `json:"minKernel,omitempty"`
+	NeedsBTF      bool   `json:"needsBTF,omitempty"`
+	NeedsCgroupV2 bool   `json:"needsCgroupV2,omitempty"`
+	// Impact is what the eBPF check reports for nodes lacking the feature:
+	// Fail when the node-agent cannot run at all, Warn when it runs degraded,
+	// Pass for features that are only shown in the matrix.
+	Impact CheckStatus `json:"impact"`
+	// Degrades is the Kubescape capability lost without the feature.
+	Degrades string `json:"degrades"`
+}
+
+// KernelFeatures is the feature table the per-node runtime detection matrix
+// is built from. Add rows here to probe and report more features.
+var KernelFeatures = []KernelFeature{
+	// No MinKernel: distribution kernels older than 4.4 may backport eBPF, so
+	// only the config flags decide; the eBPF check warns about such kernels.
+	{Name: "eBPF", Flags: []string{"CONFIG_BPF", "CONFIG_BPF_SYSCALL"}, Impact: StatusFail,
+		Degrades: "the node-agent cannot load eBPF programs: no runtime detection, application profiles or network policies"},
+	{Name: "BPF JIT", Flags: []string{"CONFIG_BPF_JIT"}, Impact: StatusWarn,
+		Degrades: "eBPF programs run in the interpreter, raising the node-agent CPU usage"},
+	{Name: "tracepoints", Flags: []string{"CONFIG_TRACEPOINTS", "CONFIG_FTRACE_SYSCALLS"}, MinKernel: "4.7", Impact: StatusWarn,
+		Degrades: "exec, open and syscall tracing: application profiles, seccomp profiles and most runtime detection rules"},
+	{Name: "kprobes", Flags: []string{"CONFIG_KPROBES", "CONFIG_KPROBE_EVENTS"}, MinKernel: "4.4", Impact: StatusWarn,
+		Degrades: "network, capabilities and link tracing: network policy generation and the related runtime detection rules"},
+	{Name: "ring buffer", MinKernel: "5.8", Impact: StatusWarn,
+		Degrades: "events fall back to per-CPU perf buffers, using more memory and dropping events under load"},
+	{Name: "fentry/fexit", MinKernel: "5.5", NeedsBTF: true, Impact: StatusPass,
+		Degrades: "tracers attach through kprobes instead, at a higher overhead"},
+	{Name: "BPF LSM", Flags: []string{"CONFIG_BPF_LSM"}, MinKernel: "5.7", Impact: StatusPass,
+		Degrades: "LSM hooks are unavailable for detection (also requires bpf in the active LSMs)"},
+	{Name: "cgroup v2", Flags: []string{"CONFIG_CGROUP_BPF"}, NeedsCgroupV2: true, Impact: StatusWarn,
+		Degrades: "events cannot be attributed to containers through their cgroup, degrading workload-level profiles"},
+}
+
+// KernelFeatureFlags returns the kernel config options of all KernelFeatures,
+// in table order.
+func KernelFeatureFlags() []string {
+	var flags []string
+	seen := map[string]bool{}
+	for _, f := range KernelFeatures {
+		for _, flag := range f.Flags {
+			if !seen[flag] {
+				seen[flag] = true
+				flags = append(flags, flag)
+			}
+		}
+	}
+	return flags
+}
+
+// Requirements describes what the feature needs, e.g. "kernel >= 5.7, CONFIG_BPF_LSM=y".
+func (f KernelFeature) Requirements() string {
+	var reqs []string
+	if f.MinKernel != "" {
+		reqs = append(reqs, "kernel >= "+f.MinKernel)
+	}
+	for _, flag := range f.Flags {
+		reqs = append(reqs, flag+"=y")
+	}
+	if f.NeedsBTF {
+		reqs = append(reqs, "BTF")
+	}
+	if f.NeedsCgroupV2 {
+		reqs = append(reqs, "cgroup v2")
+	}
+	return strings.Join(reqs, ", ")
+}
+
+// FeatureSupport tells whether a kernel feature is usable on a node.
+type FeatureSupport string
+
+const (
+	FeatureSupported   FeatureSupport = "supported"
+	FeatureUnsupported FeatureSupport = "unsupported"
+	FeatureUnknown     FeatureSupport = "unknown" // e.g. the kernel config was not probed
+)
+
+// FeatureVerdict is the support of one KernelFeature on one node.
+type FeatureVerdict struct {
+	Feature string         `json:"feature"`
+	Support FeatureSupport `json:"support"`
+	// Reason lists the unmet or unknown requirements.
+	Reason string `json:"reason,omitempty"`
+}
+
+// NodeFeatures holds the verdict of every KernelFeatures entry for a Linux node.
+type NodeFeatures struct {
+	Node          string `json:"node"`
+	Pool          string `json:"pool,omitempty"`
+	KernelRelease string `json:"kernelRelease,omitempty"`
+	// Probed is set when the verdicts are based on a node probe result rather
+	// than on the kernel version alone.
+	Probed   bool             `json:"probed"`
+	Features []FeatureVerdict `json:"features"`
+}
+
+// EvaluateNodeFeatures evaluates KernelFeatures on every Linux node, using its
+// node probe result when there is one. Without a probe result only the kernel
+// version can be checked, and the other requirements are unknown.
+func EvaluateNodeFeatures(nodes []corev1.Node, probes []NodeProbe) []NodeFeatures {
+	byNode := map[string]*NodeProbe{}
+	for i := range probes {
+		if probes[i].Status == NodeProbeOK {
+			byNode[probes[i].Node] = &probes[i]
+		}
+	}
+	var result []NodeFeatures
+	for i := range nodes {
+		node := &nodes[i]
+		if NodeOS(node) != "linux" {
+			continue
+		}
+		nf := NodeFeatures{Node: node.Name, Pool: NodePool(node), KernelRelease: node.Status.NodeInfo.KernelVersion}
+		probe := byNode[node.Name]
+		if probe != nil {
+			nf.Probed = true
+			if probe.KernelRelease != "" {
+				nf.KernelRelease = probe.KernelRelease
+			}
+		}
+		for _, f := range KernelFeatures {
+			nf.Features = append(nf.Features, evaluateFeature(f, nf.KernelRelease, probe))
+		}
+		result = append(result, nf)
+	}
+	return result
+}
+
+// evaluateFeature checks the requirements of f against a node's kernel
+// release and, when available, its probe result.
+func evaluateFeature(f KernelFeature, release string, probe *NodeProbe) FeatureVerdict {
+	var unmet, unknown []string
+	if f.MinKernel != "" {
+		if ok, err := VersionAtLeast(release, f.MinKernel); err != nil {
+			unknown = append(unknown, "kernel version unknown")
+		} else if !ok {
+			unmet = append(unmet, fmt.Sprintf("kernel %s < %s", release, f.MinKernel))
+		}
+	}
+	if len(f.Flags) > 0 {
+		switch {
+		case probe == nil:
+			unknown = append(unknown, "kernel config not probed")
+		case probe.KernelConfigSource == "":
+			unknown = append(unknown, "kernel config unreadable")
+		default:
+			for _, flag := range f.Flags {
+				if probe.ConfigValue(flag) != "y" {
+					unmet = append(unmet, flag+" not set")
+				}
+			}
+		}
+	}
+	if f.NeedsBTF {
+		switch {
+		case probe == nil:
+			unknown = append(unknown, "BTF not probed")
+		case !probe.BTF:
+			unmet = append(unmet, "no BTF")
+		}
+	}
+	if f.NeedsCgroupV2 {
+		switch {
+		case probe == nil || probe.CgroupVersion == "":
+			unknown = append(unknown, "cgroup version unknown")
+		case probe.CgroupVersion != CgroupV2:
+			unmet = append(unmet, "cgroup "+probe.CgroupVersion)
+		}
+	}
+
+	v := FeatureVerdict{Feature: f.Name, Support: FeatureSupported}
+	if len(unmet) > 0 {
+		v.Support, v.Reason = FeatureUnsupported, strings.Join(unmet, ", ")
+	} else if len(unknown) > 0 {
+		v.Support, v.Reason = FeatureUnknown, strings.Join(unknown, ", ")
+	}
+	return v
+}
+
+var kernelVersionPattern = regexp.MustCompile(`^(\d+)\.(\d+)(?:\.(\d+))?`)
+
+// ParseKernelVersion extracts major, minor, patch from a string like "5.4.0-104-generic".
+func ParseKernelVersion(versionStr string) (uint, uint, uint, error) {
+	matches := kernelVersionPattern.FindStringSubmatch(versionStr)
+	if len(matches) < 3 {
+		return 0, 0, 0, fmt.Errorf("invalid kernel version format: %s", versionStr)
+	}
+	var major, minor, patch uint
+	if _, err := fmt.Sscanf(matches[0], "%d.%d.%d", &major, &minor, &patch); err != nil {
+		// If there's no patch part, attempt parsing just %d.%d
+		if _, err2 := fmt.Sscanf(matches[0], "%d.%d", &major, &minor); err2 != nil {
+			return 0, 0, 0, fmt.Errorf("unable to parse kernel version %q: %v", matches[0], err)
+		}
+	}
+	return major, minor, patch, nil
+}
+
+// VersionAtLeast reports whether a dotted version, such as the kernel release
+// "5.15.0-1051-aws" or the runtime version "1.7.22", is min (e.g. "5.8") or newer.
+func VersionAtLeast(release, min string) (bool, error) {
+	major, minor, patch, err := ParseKernelVersion(release)
+	if err != nil {
+		return false, err
+	}
+	wantMajor, wantMinor, wantPatch, err := ParseKernelVersion(min)
+	if err != nil {
+		return false, err
+	}
+	if major != wantMajor {
+		return major > wantMajor, nil
+	}
+	if minor != wantMinor {
+		return minor > wantMinor, nil
+	}
+	return patch >= wantPatch, nil
+}
+
+// NodeOS returns the operating system of a node, from its status or label.
+func NodeOS(node *corev1.Node) string {
+	if os := node.Status.NodeInfo.OperatingSystem; os != "" {
+		return os
+	}
+	if os := node.Labels[corev1.LabelOSStable]; os != "" {
+		return os
+	}
+	return "linux"
+}
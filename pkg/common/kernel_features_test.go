@@ -0,0 +1,101 @@
+package common
+
+import (
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestParseKernelVersion(t *testing.T) {
+	tests := []struct {
+		in                  string
+		major, minor, patch uint
+		wantErr             bool
+	}{
+		{in: "5.4.0-104-generic", major: 5, minor: 4, patch: 0},
+		{in: "6.1.112-124.190.amzn2023.x86_64", major: 6, minor: 1, patch: 112},
+		{in: "4.19", major: 4, minor: 19},
+		{in: "3.10.0-1160.el7.x86_64", major: 3, minor: 10, patch: 0},
+		{in: "", wantErr: true},
+		{in: "unknown", wantErr: true},
+	}
+	for _, tt := range tests {
+		major, minor, patch, err := ParseKernelVersion(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseKernelVersion(%q) error = %v, wantErr %v", tt.in, err, tt.wantErr)
+			continue
+		}
+		if major != tt.major || minor != tt.minor || patch != tt.patch {
+			t.Errorf("ParseKernelVersion(%q) = %d.%d.%d, want %d.%d.%d",
+				tt.in, major, minor, patch, tt.major, tt.minor, tt.patch)
+		}
+	}
+}
+
+func TestVersionAtLeast(t *testing.T) {
+	tests := []struct {
+		release, min string
+		want         bool
+	}{
+		{"5.8.0-1041-aws", "5.8", true},
+		{"5.4.0-104-generic", "5.8", false},
+		{"6.1.112-124.190.amzn2023.x86_64", "5.8", true},
+		{"4.19.0", "4.7", true},
+		{"5.7", "5.7.1", false},
+	}
+	for _, tt := range tests {
+		got, err := VersionAtLeast(tt.release, tt.min)
+		if err != nil || got != tt.want {
+			t.Errorf("VersionAtLeast(%q, %q) = %v, %v, want %v", tt.release, tt.min, got, err, tt.want)
+		}
+	}
+	if _, err := VersionAtLeast("custom", "5.8"); err == nil {
+		t.Error("VersionAtLeast() accepted an unparsable release")
+	}
+}
+
+func TestEvaluateNodeFeatures(t *testing.T) {
+	node := func(name, os, kernel string) corev1.Node {
+		return corev1.Node{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Status:     corev1.NodeStatus{NodeInfo: corev1.NodeSystemInfo{OperatingSystem: os, KernelVersion: kernel}},
+		}
+	}
+	config := map[string]string{}
+	for _, flag := range KernelFeatureFlags() {
+		config[flag] = "y"
+	}
+	delete(config, "CONFIG_BPF_LSM")
+	nodes := []corev1.Node{node("probed", "linux", "6.1.0"), node("old", "linux", "5.4.0-104-generic"), node("win", "windows", "10.0.17763")}
+	probes := []NodeProbe{{Node: "probed", Status: NodeProbeOK, KernelRelease: "6.1.0", KernelConfigSource: "/boot/config-6.1.0",
+		KernelConfig: config, BTF: true, CgroupVersion: CgroupV2}}
+
+	got := EvaluateNodeFeatures(nodes, probes)
+	if len(got) != 2 {
+		t.Fatalf("got %d nodes, want the 2 Linux nodes: %+v", len(got), got)
+	}
+	verdicts := func(nf NodeFeatures) map[string]FeatureVerdict {
+		m := map[string]FeatureVerdict{}
+		for _, v := range nf.Features {
+			m[v.Feature] = v
+		}
+		return m
+	}
+
+	probed := verdicts(got[0])
+	if !got[0].Probed || probed["ring buffer"].Support != FeatureSupported || probed["cgroup v2"].Support != FeatureSupported {
+		t.Errorf("probed node = %+v, want ring buffer and cgroup v2 supported", got[0])
+	}
+	if v := probed["BPF LSM"]; v.Support != FeatureUnsupported || v.Reason != "CONFIG_BPF_LSM not set" {
+		t.Errorf("BPF LSM = %+v, want unsupported for the missing flag", v)
+	}
+
+	old := verdicts(got[1])
+	if v := old["ring buffer"]; v.Support != FeatureUnsupported || v.Reason != "kernel 5.4.0-104-generic < 5.8" {
+		t.Errorf("ring buffer = %+v, want unsupported by kernel version", v)
+	}
+	if v := old["kprobes"]; v.Support != FeatureUnknown || v.Reason != "kernel config not probed" {
+		t.Errorf("kprobes = %+v, want unknown without a probe", v)
+	}
+}
@@ -0,0 +1,140 @@
+package common
+
+import (
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// NodeProbeStatus tells whether the node probe (--node-probe) reported from a node.
+type NodeProbeStatus string
+
+const (
+	NodeProbeOK            NodeProbeStatus = "ok"
+	NodeProbeTainted       NodeProbeStatus = "tainted"        // not scheduled: the node has taints the probe does not tolerate
+	NodeProbeUnsupportedOS NodeProbeStatus = "unsupported-os" // not scheduled: the probe only runs on Linux nodes
+	NodeProbeTimeout       NodeProbeStatus = "timeout"        // no result within --probe-timeout
+	NodeProbeFailed        NodeProbeStatus = "failed"         // the probe ran but its result could not be read
+)
+
+// Outcomes of the BPF syscall test of the node probe.
+const (
+	BPFSyscallAvailable      = "available"
+	BPFSyscallDenied         = "denied"          // EPERM: blocked, e.g. by kernel lockdown or seccomp
+	BPFSyscallNotImplemented = "not-implemented" // ENOSYS: kernel built without CONFIG_BPF_SYSCALL
+	BPFSyscallUnknown        = "unknown"
+)
+
+// Cgroup versions reported by the node probe.
+const (
+	CgroupV1 = "v1"
+	CgroupV2 = "v2"
+)
+
+// NodeProbe is what the node probe found on one node.
+type NodeProbe struct {
+	Node    string          `json:"node"`
+	Pool    string          `json:"pool,omitempty"`
+	Status  NodeProbeStatus `json:"status"`
+	Message string          `json:"message,omitempty"`
+
+	KernelRelease string `json:"kernelRelease,omitempty"`
+	// KernelConfigSource is the file the kernel config was read from.
+	KernelConfigSource string `json:"kernelConfigSource,omitempty"`
+	// KernelConfig holds the probed CONFIG_* options and their values (y, m or n).
+	KernelConfig map[string]string `json:"kernelConfig,omitempty"`
+	BTF          bool              `json:"btf"`
+	BPFSyscall   string            `json:"bpfSyscall,omitempty"`
+	// CgroupVersion is the cgroup hierarchy mounted at /sys/fs/cgroup, v1 or v2.
+	CgroupVersion string `json:"cgroupVersion,omitempty"`
+}
+
+// ConfigValue returns the value of a probed kernel config option, "n" when it
+// is not set.
+func (p NodeProbe) ConfigValue(option string) string {
+	if v, ok := p.KernelConfig[option]; ok {
+		return v
+	}
+	return "n"
+}
+
+// nodePoolLabels are the labels managed node pools carry, most specific first.
+var nodePoolLabels = []string{
+	"eks.amazonaws.com/nodegroup",
+	"alpha.eksctl.io/nodegroup-name",
+	"karpenter.sh/nodepool",
+	"cloud.google.com/gke-nodepool",
+	"kubernetes.azure.com/agentpool",
+	"agentpool",
+	"node.kubernetes.io/pool",
+}
+
+// NodePool returns the node pool (EKS node group, GKE node pool, AKS agent
+// pool, ...) a node belongs to, or "" when it carries no known pool label.
+func NodePool(node *corev1.Node) string {
+	_, pool := NodePoolLabel(node, "")
+	return pool
+}
+
+// NodePoolLabel returns the pool label of a node and its value, looking at
+// the custom label first, if set, or "" when the node carries none.
+func NodePoolLabel(node *corev1.Node, custom string) (string, string) {
+	if custom != "" {
+		if pool := node.Labels[custom]; pool != "" {
+			return custom, pool
+		}
+	}
+	for _, label := range nodePoolLabels {
+		if pool := node.Labels[label]; pool != "" {
+			return label, pool
+		}
+	}
+	return "", ""
+}
+
+// daemonSetTolerated are the taints the DaemonSet controller tolerates on
+// every DaemonSet pod.
+var daemonSetTolerated = map[string]bool{
+	corev1.TaintNodeNotReady:           true,
+	corev1.TaintNodeUnreachable:        true,
+	corev1.TaintNodeDiskPressure:       true,
+	corev1.TaintNodeMemoryPressure:     true,
+	corev1.TaintNodePIDPressure:        true,
+	corev1.TaintNodeUnschedulable:      true,
+	corev1.TaintNodeNetworkUnavailable: true,
+}
+
+// BlockingTaints returns the NoSchedule and NoExecute taints of a node that
+// keep DaemonSet pods without matching tolerations off it.
+func BlockingTaints(node *corev1.Node) []corev1.Taint {
+	var taints []corev1.Taint
+	for _, t := range node.Spec.Taints {
+		if t.Effect == corev1.TaintEffectPreferNoSchedule || daemonSetTolerated[t.Key] {
+			continue
+		}
+		taints = append(taints, t)
+	}
+	return taints
+}
+
+// DescribeTaints renders taints like kubectl, e.g. "dedicated=gpu:NoSchedule".
+func DescribeTaints(taints []corev1.Taint) string {
+	names := make([]string, 0, len(taints))
+	for _, t := range taints {
+		names = append(names, t.ToString())
+	}
+	return strings.Join(names, ", ")
+}
+
+// DescribePools renders node counts per pool, e.g. "gpu (3), default (1)",
+// largest first. Nodes without a pool are counted as "(no pool)".
+func DescribePools(pools []string) string {
+	counts := map[string]int{}
+	for _, p := range pools {
+		if p == "" {
+			p = "(no pool)"
+		}
+		counts[p]++
+	}
+	return DescribeCounts(counts)
+}
@@ -0,0 +1,130 @@
+package common
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// Artifact identifies one of the files the checker can produce.
+type Artifact string
+
+const (
+	ArtifactHTML   Artifact = "html"   // HTML report
+	ArtifactReview Artifact = "review" // review-values page, produced together with the HTML report
+	ArtifactJSON   Artifact = "json"   // versioned JSON report
+	ArtifactYAML   Artifact = "yaml"   // the JSON report rendered as YAML
+	ArtifactValues Artifact = "values" // recommended Helm values
+	ArtifactDump   Artifact = "dump"   // full cluster dump
+)
+
+// artifactOrder is the order in which artifacts are written and listed.
+var artifactOrder = []Artifact{ArtifactHTML, ArtifactReview, ArtifactJSON, ArtifactYAML, ArtifactValues, ArtifactDump}
+
+var defaultFileNames = map[Artifact]string{
+	ArtifactHTML:   "prerequisites-report.html",
+	ArtifactReview: "review-values.html",
+	ArtifactJSON:   "prerequisites-report.json",
+	ArtifactYAML:   "prerequisites-report.yaml",
+	ArtifactValues: "recommended-values.yaml",
+	ArtifactDump:   "full-cluster-dump.yaml",
+}
+
+var artifactLabels = map[Artifact]string{
+	ArtifactHTML:   "HTML report",
+	ArtifactReview: "Review values page",
+	ArtifactJSON:   "JSON report",
+	ArtifactYAML:   "YAML report",
+	ArtifactValues: "Helm values file",
+	ArtifactDump:   "Full cluster dump",
+}
+
+// DefaultFormats is the --formats value used when the flag is not set.
+const DefaultFormats = "html,json,values,dump"
+
+// OutputOptions controls which artifacts are produced and where they go.
+type OutputOptions struct {
+	// Dir is the directory local runs write to. Defaults to os.TempDir().
+	Dir string
+	// Formats is the set of artifacts to produce.
+	Formats map[Artifact]bool
+	// FileNames holds the file name (or ConfigMap key) of each artifact.
+	FileNames map[Artifact]string
+	// ConfigMap controls where in-cluster runs store the artifacts.
+	ConfigMap ConfigMapOptions
+}
+
+// NewOutputOptions builds OutputOptions from the --output-dir, --formats and
+// --file-names flag values. Formats is a comma-separated list of html, json,
+// yaml, values and dump ("html" also produces the review-values page).
+// FileNames is a comma-separated list of <artifact>=<file name> entries, where
+// artifact is one of the formats or "review".
+func NewOutputOptions(dir, formats, fileNames string) (OutputOptions, error) {
+	if dir == "" {
+		dir = os.TempDir()
+	}
+	opts := OutputOptions{
+		Dir:       dir,
+		Formats:   map[Artifact]bool{},
+		FileNames: map[Artifact]string{},
+	}
+	for a, name := range defaultFileNames {
+		opts.FileNames[a] = name
+	}
+
+	for _, f := range strings.Split(formats, ",") {
+		a := Artifact(strings.TrimSpace(strings.ToLower(f)))
+		if a == "" {
+			continue
+		}
+		if _, ok := defaultFileNames[a]; !ok || a == ArtifactReview {
+			return opts, fmt.Errorf("unknown output format %q (expected html, json, yaml, values or dump)", f)
+		}
+		opts.Formats[a] = true
+		if a == ArtifactHTML {
+			opts.Formats[ArtifactReview] = true
+		}
+	}
+	if len(opts.Formats) == 0 {
+		return opts, fmt.Errorf("--formats must select at least one output format")
+	}
+
+	for _, entry := range strings.Split(fileNames, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		key, name, ok := strings.Cut(entry, "=")
+		a := Artifact(strings.TrimSpace(key))
+		name = strings.TrimSpace(name)
+		if !ok || name == "" {
+			return opts, fmt.Errorf("invalid --file-names entry %q (expected <artifact>=<file name>)", entry)
+		}
+		if _, known := defaultFileNames[a]; !known {
+			return opts, fmt.Errorf("unknown artifact %q in --file-names (expected html, review, json, yaml, values or dump)", key)
+		}
+		if strings.ContainsAny(name, `/\`) {
+			return opts, fmt.Errorf("file name %q for %s must not contain a path; use --output-dir instead", name, a)
+		}
+		// In-cluster runs store the artifacts under their file name, with
+		// ".gz" appended when compressed
+		if errs := validation.IsConfigMapKey(name + ".gz"); len(errs) > 0 {
+			return opts, fmt.Errorf("file name %q for %s is not a valid ConfigMap key: %s", name, a, strings.Join(errs, "; "))
+		}
+		opts.FileNames[a] = name
+	}
+	return opts, nil
+}
+
+// OutputFile is a rendered artifact ready to be written to disk or a ConfigMap.
+type OutputFile struct {
+	Artifact Artifact
+	Name     string // file name or ConfigMap key
+	Content  string
+}
+
+func (f OutputFile) label() string {
+	return artifactLabels[f.Artifact]
+}
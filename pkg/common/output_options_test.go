@@ -0,0 +1,28 @@
+package common
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestNewOutputOptionsFileNames(t *testing.T) {
+	tests := []struct {
+		fileNames string
+		wantErr   string
+	}{
+		{fileNames: "json=cluster-a.json,values=cluster_a-values.yaml"},
+		{fileNames: "json=dir/report.json", wantErr: "must not contain a path"},
+		{fileNames: "json=report 1.json", wantErr: "not a valid ConfigMap key"},
+		{fileNames: "json=report:1.json", wantErr: "not a valid ConfigMap key"},
+		{fileNames: "json=" + strings.Repeat("a", 251), wantErr: "not a valid ConfigMap key"},
+	}
+	for _, tt := range tests {
+		_, err := NewOutputOptions("", DefaultFormats, tt.fileNames)
+		if tt.wantErr == "" && err != nil {
+			t.Errorf("NewOutputOptions(%q): %v", tt.fileNames, err)
+		}
+		if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+			t.Errorf("NewOutputOptions(%q) error = %v, want %q", tt.fileNames, err, tt.wantErr)
+		}
+	}
+}
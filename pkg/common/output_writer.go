@@ -3,12 +3,10 @@ package common
 import (
 	"context"
 	"fmt"
-	"log"
 	"os"
 	"path/filepath"
+	"strings"
 
-	corev1 "k8s.io/api/core/v1"
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
 )
@@ -17,120 +15,143 @@ func printSeparator() {
 	fmt.Println("------------------------------------------------------------")
 }
 
-func printHelmInstructions() {
-	fmt.Println("🚀 Use the generated recommended-values.yaml to optimize Kubescape for your cluster.")
+func printHelmInstructions(valuesName string) {
+	fmt.Printf("🚀 Use the generated %s to optimize Kubescape for your cluster.\n", valuesName)
 }
 
-func printDiskSuccess(reportPath, valuesPath, dumpPath string) {
+func printDiskSuccess(files []OutputFile, paths []string, opts OutputOptions) {
 	printSeparator()
 	fmt.Println("✅ prerequisites report generated locally!")
-	fmt.Println("   •", reportPath, "(HTML report)")
-	fmt.Println("   •", valuesPath, "(Helm values file)")
-	fmt.Println("   •", dumpPath, "(Full cluster dump)")
+	for i, f := range files {
+		fmt.Println("   •", paths[i], "("+f.label()+")")
+	}
 	fmt.Println("")
-	fmt.Println("📋 Open", reportPath, "in your browser for details.")
-	printHelmInstructions()
+	if opts.Formats[ArtifactHTML] {
+		fmt.Println("📋 Open", filepath.Join(opts.Dir, opts.FileNames[ArtifactHTML]), "in your browser for details.")
+	}
+	if opts.Formats[ArtifactValues] {
+		printHelmInstructions(opts.FileNames[ArtifactValues])
+	}
 	printSeparator()
 }
 
-func printConfigMapSuccess() {
+func printConfigMapSuccess(configMaps []string, stored []*storedArtifact, skipped []OutputFile, opts OutputOptions) {
 	printSeparator()
-	fmt.Println("✅ prerequisites report stored in Kubernetes ConfigMap!")
-	fmt.Println("   • ConfigMap Name: kubescape-prerequisites-report")
-	fmt.Println("   • Namespace: default")
+	if len(configMaps) == 1 {
+		fmt.Println("✅ prerequisites report stored in Kubernetes ConfigMap!")
+		fmt.Println("   • ConfigMap Name:", configMaps[0])
+	} else {
+		fmt.Println("✅ prerequisites report stored in Kubernetes ConfigMaps!")
+		fmt.Println("   • ConfigMap Names:", strings.Join(configMaps, ", "))
+	}
+	fmt.Println("   • Namespace:", opts.ConfigMap.Namespace)
+	for _, f := range skipped {
+		fmt.Printf("   ⚠️  %s was not stored: it does not fit into a ConfigMap (use --configmap-mode auto or gzip)\n", f.Name)
+	}
 	printSeparator()
 	fmt.Println("")
 	fmt.Println("⬇️  To export the report files locally:")
-	fmt.Println("    kubectl get configmap kubescape-prerequisites-report -n default -o go-template='{{ index .data \"prerequisites-report.html\" }}' > prerequisites-report.html")
-	fmt.Println("    kubectl get configmap kubescape-prerequisites-report -n default -o go-template='{{ index .data \"recommended-values.yaml\" }}' > recommended-values.yaml")
-	fmt.Println("    kubectl get configmap kubescape-prerequisites-report -n default -o go-template='{{ index .data \"review-values.html\" }}' > review-values.html")
-	fmt.Println("    kubectl get configmap kubescape-prerequisites-report -n default -o go-template='{{ index .data \"full-cluster-dump.yaml\" }}' > full-cluster-dump.yaml")
+	for _, sa := range stored {
+		fmt.Println("    " + exportCommand(sa, opts.ConfigMap.Namespace))
+	}
 	fmt.Println("")
-	fmt.Println("📋 Open prerequisites-report.html in your browser for details.")
-	printHelmInstructions()
-	printSeparator()
-}
-
-func WriteToDisk(htmlContent string, helmValuesContent string, fullDumpContent string, reviewValuesHTML string) {
-	// 1) Write the HTML report
-	reportPath := filepath.Join(os.TempDir(), "prerequisites-report.html")
-	if err := os.WriteFile(reportPath, []byte(htmlContent), 0644); err != nil {
-		log.Fatalf("Failed to write HTML report to %s: %v", reportPath, err)
+	if opts.Formats[ArtifactHTML] {
+		fmt.Println("📋 Open", opts.FileNames[ArtifactHTML], "in your browser for details.")
 	}
-
-	// 2) Write the review values HTML
-	reviewValuesPath := filepath.Join(os.TempDir(), "review-values.html")
-	if err := os.WriteFile(reviewValuesPath, []byte(reviewValuesHTML), 0644); err != nil {
-		log.Fatalf("Failed to write review-values.html to %s: %v", reviewValuesPath, err)
+	if opts.Formats[ArtifactValues] {
+		printHelmInstructions(opts.FileNames[ArtifactValues])
 	}
+	printSeparator()
+}
 
-	// 3) Write the recommended values YAML
-	valuesPath := filepath.Join(os.TempDir(), "recommended-values.yaml")
-	if err := os.WriteFile(valuesPath, []byte(helmValuesContent), 0644); err != nil {
-		log.Fatalf("Failed to write recommended-values.yaml to %s: %v", valuesPath, err)
+// WriteToDisk writes the rendered artifacts into opts.Dir, creating it if needed.
+func WriteToDisk(files []OutputFile, opts OutputOptions) error {
+	if err := os.MkdirAll(opts.Dir, 0755); err != nil {
+		return fmt.Errorf("failed to create output directory %s: %w", opts.Dir, err)
 	}
 
-	// 4) Write the full cluster dump
-	dumpPath := filepath.Join(os.TempDir(), "full-cluster-dump.yaml")
-	if err := os.WriteFile(dumpPath, []byte(fullDumpContent), 0644); err != nil {
-		log.Fatalf("Failed to write full-cluster-dump.yaml to %s: %v", dumpPath, err)
+	paths := make([]string, 0, len(files))
+	for _, f := range files {
+		path := filepath.Join(opts.Dir, f.Name)
+		if err := os.WriteFile(path, []byte(f.Content), 0644); err != nil {
+			return fmt.Errorf("failed to write %s to %s: %w", f.label(), path, err)
+		}
+		paths = append(paths, path)
 	}
 
-	// 5) Print success messages and instructions for local disk
-	printDiskSuccess(reportPath, valuesPath, dumpPath)
+	// Print success messages and instructions for local disk
+	printDiskSuccess(files, paths, opts)
+	return nil
 }
 
-func WriteToConfigMap(htmlContent string, helmValuesContent string, fullDumpContent string, reviewValuesHTML string) {
+// WriteToConfigMap stores the rendered artifacts in the report ConfigMap(s),
+// compressing and splitting large artifacts according to opts.ConfigMap.Mode.
+func WriteToConfigMap(files []OutputFile, opts OutputOptions) error {
 	// Build in-cluster Kubernetes client configuration
 	config, err := rest.InClusterConfig()
 	if err != nil {
-		log.Fatalf("Failed to build in-cluster config: %v", err)
+		return fmt.Errorf("failed to build in-cluster config: %w", err)
 	}
 
 	// Create clientset
 	clientset, err := kubernetes.NewForConfig(config)
 	if err != nil {
-		log.Fatalf("Failed to create Kubernetes client: %v", err)
+		return fmt.Errorf("failed to create Kubernetes client: %w", err)
 	}
 
-	configMapName := "kubescape-prerequisites-report"
-	namespace := "default"
+	entries, skipped := buildConfigMapEntries(files, opts.ConfigMap.Mode)
+	configMaps, stored := packConfigMaps(entries, opts.ConfigMap)
 
-	configMap := &corev1.ConfigMap{
-		ObjectMeta: metav1.ObjectMeta{
-			Name:      configMapName,
-			Namespace: namespace,
-		},
-		Data: map[string]string{
-			"prerequisites-report.html": htmlContent,
-			"review-values.html":        reviewValuesHTML,
-			"recommended-values.yaml":   helmValuesContent,
-			// "full-cluster-dump.yaml":    fullDumpContent,
-		},
+	if err := writeReportConfigMaps(context.Background(), clientset, configMaps, opts.ConfigMap); err != nil {
+		return err
 	}
 
-	// Create or Update
-	_, err = clientset.CoreV1().ConfigMaps(namespace).Create(context.Background(), configMap, metav1.CreateOptions{})
-	if err != nil {
-		log.Printf("Failed to create ConfigMap: %v", err)
-		_, err = clientset.CoreV1().ConfigMaps(namespace).Update(context.Background(), configMap, metav1.UpdateOptions{})
-		if err != nil {
-			log.Fatalf("Failed to update ConfigMap: %v", err)
-		}
+	printConfigMapSuccess(configMapNames(configMaps), stored, skipped, opts)
+	return nil
+}
+
+// RenderOutputs renders the artifacts selected in opts, in a stable order.
+func RenderOutputs(reportData *ReportData, opts OutputOptions) []OutputFile {
+	reportData.ValuesFileName = opts.FileNames[ArtifactValues]
+	reportData.ReviewValuesFileName = opts.FileNames[ArtifactReview]
+
+	var valuesContent string
+	if opts.Formats[ArtifactValues] || opts.Formats[ArtifactReview] {
+		valuesContent = BuildValuesYAML(reportData)
 	}
 
-	printConfigMapSuccess()
+	var files []OutputFile
+	for _, a := range artifactOrder {
+		if !opts.Formats[a] {
+			continue
+		}
+		var content string
+		switch a {
+		case ArtifactHTML:
+			content = BuildHTMLReport(reportData, PrerequisitesReportHTML)
+		case ArtifactReview:
+			content = BuildReviewValuesHTML(reportData, valuesContent)
+		case ArtifactJSON:
+			content = BuildJSONReport(reportData)
+		case ArtifactYAML:
+			content = BuildYAMLReport(reportData)
+		case ArtifactValues:
+			content = valuesContent
+		case ArtifactDump:
+			content = BuildFullDumpYAML(reportData.FullClusterData)
+		}
+		files = append(files, OutputFile{Artifact: a, Name: opts.FileNames[a], Content: content})
+	}
+	return files
 }
 
-func GenerateOutput(reportData *ReportData, inCluster bool) {
-	htmlContent := BuildHTMLReport(reportData, PrerequisitesReportHTML)
-	yamlContent := BuildValuesYAML(reportData)
-	reviewValuesHTML := BuildReviewValuesHTML(reportData, yamlContent)
-	fullDumpContent := BuildFullDumpYAML(reportData.FullClusterData)
+// GenerateOutput renders the report and stores it in ConfigMaps when running
+// in-cluster, or on disk otherwise.
+func GenerateOutput(reportData *ReportData, inCluster bool, opts OutputOptions) error {
+	files := RenderOutputs(reportData, opts)
 
 	if inCluster {
-		WriteToConfigMap(htmlContent, yamlContent, fullDumpContent, reviewValuesHTML)
-	} else {
-		WriteToDisk(htmlContent, yamlContent, fullDumpContent, reviewValuesHTML)
+		return WriteToConfigMap(files, opts)
 	}
+	return WriteToDisk(files, opts)
 }
@@ -0,0 +1,133 @@
+package common
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Process exit codes of the checker. Exit code 2 is used for invalid flags.
+const (
+	ExitOK           = 0
+	ExitRuntimeError = 1 // the run could not complete, e.g. the kube client could not be built
+	ExitCheckWarn    = 3 // a check matched by --fail-on reported Warn
+	ExitCheckErr     = 4 // a check matched by --fail-on reported Error
+	ExitCheckFail    = 5 // a check matched by --fail-on reported Fail
+)
+
+// failOnLevels maps the --fail-on level keywords to the least severe status
+// that violates the policy.
+var failOnLevels = map[string]CheckStatus{
+	"warn":  StatusWarn,
+	"error": StatusError,
+	"fail":  StatusFail,
+}
+
+// FailOnPolicy decides, from the check results, whether the run should end
+// with a non-zero exit code. It is built from the --fail-on flag.
+type FailOnPolicy struct {
+	// threshold applies to every check; empty means no global threshold.
+	threshold CheckStatus
+	// perCheck holds thresholds for specific checks, keyed by check name.
+	perCheck map[string]CheckStatus
+}
+
+// ParseFailOnPolicy parses a comma-separated --fail-on value. Each entry is
+// either a level ("none", "warn", "error" or "fail") applied to all checks,
+// a check name (fails the run when that check reports Fail), or
+// "<check>:<level>" for a per-check level, e.g. "fail,ebpf:warn". The last
+// level wins, so "warn,none" clears the global level again.
+func ParseFailOnPolicy(spec string) (*FailOnPolicy, error) {
+	p := &FailOnPolicy{perCheck: map[string]CheckStatus{}}
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(strings.ToLower(entry))
+		if entry == "" {
+			continue
+		}
+		if entry == "none" {
+			p.threshold = ""
+			continue
+		}
+		if level, ok := failOnLevels[entry]; ok {
+			p.threshold = level
+			continue
+		}
+
+		name, levelName, hasLevel := strings.Cut(entry, ":")
+		level := StatusFail
+		if hasLevel {
+			var ok bool
+			if level, ok = failOnLevels[levelName]; !ok {
+				return nil, fmt.Errorf("invalid --fail-on level %q in %q (expected warn, error or fail)", levelName, entry)
+			}
+		}
+		if name == "" {
+			return nil, fmt.Errorf("invalid --fail-on entry %q: missing check name", entry)
+		}
+		p.perCheck[name] = level
+	}
+	return p, nil
+}
+
+// Validate returns an error if the policy refers to a check that does not exist.
+func (p *FailOnPolicy) Validate(checks []Check) error {
+	known := map[string]bool{}
+	for _, c := range checks {
+		known[c.Name()] = true
+	}
+	var unknown []string
+	for name := range p.perCheck {
+		if !known[name] {
+			unknown = append(unknown, name)
+		}
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return fmt.Errorf("--fail-on refers to unknown check(s): %s", strings.Join(unknown, ", "))
+	}
+	return nil
+}
+
+// Evaluate returns the exit code for the given results together with the
+// results that violate the policy. The most severe violation decides the code.
+func (p *FailOnPolicy) Evaluate(results []*CheckResult) (int, []*CheckResult) {
+	var violations []*CheckResult
+	worst := StatusPass
+	for _, r := range results {
+		threshold, ok := p.perCheck[r.Name]
+		if !ok {
+			threshold = p.threshold
+		}
+		if threshold == "" || r.Status.Severity() < threshold.Severity() {
+			continue
+		}
+		violations = append(violations, r)
+		if r.Status.Severity() > worst.Severity() {
+			worst = r.Status
+		}
+	}
+
+	switch worst {
+	case StatusFail:
+		return ExitCheckFail, violations
+	case StatusError:
+		return ExitCheckErr, violations
+	case StatusWarn:
+		return ExitCheckWarn, violations
+	default:
+		return ExitOK, nil
+	}
+}
+
+// PrintPolicyViolations reports the checks that caused a non-zero exit code.
+func PrintPolicyViolations(violations []*CheckResult) {
+	if len(violations) == 0 {
+		return
+	}
+	printSeparator()
+	fmt.Println("❌ --fail-on policy violated by:")
+	for _, r := range violations {
+		fmt.Printf("   • %s: %s (%s)\n", r.Name, r.Status, r.Message)
+	}
+	printSeparator()
+}
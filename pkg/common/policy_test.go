@@ -0,0 +1,30 @@
+package common
+
+import "testing"
+
+func TestParseFailOnPolicy(t *testing.T) {
+	results := []*CheckResult{
+		{Name: "sizing", Status: StatusWarn},
+		{Name: "ebpf", Status: StatusPass},
+	}
+	tests := []struct {
+		spec     string
+		wantCode int
+	}{
+		{"none", ExitOK},
+		{"warn", ExitCheckWarn},
+		{"warn,none", ExitOK},
+		{"none,warn", ExitCheckWarn},
+		{"fail,sizing:warn", ExitCheckWarn},
+		{"warn,none,sizing:warn", ExitCheckWarn},
+	}
+	for _, tt := range tests {
+		p, err := ParseFailOnPolicy(tt.spec)
+		if err != nil {
+			t.Fatalf("ParseFailOnPolicy(%q): %v", tt.spec, err)
+		}
+		if code, _ := p.Evaluate(results); code != tt.wantCode {
+			t.Errorf("--fail-on %s: exit code %d, want %d", tt.spec, code, tt.wantCode)
+		}
+	}
+}
@@ -0,0 +1,124 @@
+package common
+
+import (
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	sigsyaml "sigs.k8s.io/yaml"
+)
+
+// NodeAgentArchitectures are the CPU architectures the node-agent images are
+// built for.
+var NodeAgentArchitectures = []string{"amd64", "arm64"}
+
+// NodeAgentTolerations are the tolerations the node-agent DaemonSet has with
+// the default Helm values, besides those the DaemonSet controller adds.
+var NodeAgentTolerations []corev1.Toleration
+
+// SchedulingAdvice holds the scheduling constraints recommended for the
+// Kubescape components, so they stay off the nodes they cannot run on.
+type SchedulingAdvice struct {
+	// LinuxOnly is set when the cluster has non-Linux nodes.
+	LinuxOnly bool `json:"linuxOnly,omitempty"`
+	// Architectures restricts the node-agent to these CPU architectures; it is
+	// set when some Linux nodes have an architecture without node-agent images.
+	Architectures []string `json:"architectures,omitempty"`
+	// ExcludedNodes are the nodes the constraints keep the node-agent off.
+	ExcludedNodes []string `json:"excludedNodes,omitempty"`
+	// Tolerations let the node-agent onto the tainted nodes it does not
+	// tolerate by default.
+	Tolerations []corev1.Toleration `json:"tolerations,omitempty"`
+	// ExcludedPools are the node pools left without node-agent on purpose
+	// (--exclude-node-pools), whose taints get no toleration.
+	ExcludedPools []string `json:"excludedPools,omitempty"`
+}
+
+// Merge adds the advice of another check.
+func (s *SchedulingAdvice) Merge(o *SchedulingAdvice) {
+	s.LinuxOnly = s.LinuxOnly || o.LinuxOnly
+	if len(o.Architectures) > 0 {
+		s.Architectures = o.Architectures
+	}
+	s.ExcludedNodes = append(s.ExcludedNodes, o.ExcludedNodes...)
+	s.Tolerations = append(s.Tolerations, o.Tolerations...)
+	s.ExcludedPools = append(s.ExcludedPools, o.ExcludedPools...)
+}
+
+// Tolerated reports whether any of the tolerations tolerates the taint.
+func Tolerated(tolerations []corev1.Toleration, taint *corev1.Taint) bool {
+	for i := range tolerations {
+		if tolerations[i].ToleratesTaint(taint) {
+			return true
+		}
+	}
+	return false
+}
+
+// NodeArch returns the CPU architecture of a node, from its status or label.
+func NodeArch(node *corev1.Node) string {
+	if arch := node.Status.NodeInfo.Architecture; arch != "" {
+		return arch
+	}
+	return node.Labels[corev1.LabelArchStable]
+}
+
+// NodeAgentSupported reports whether the node-agent can run on the node at
+// all: a Linux node of an architecture with node-agent images.
+func NodeAgentSupported(node *corev1.Node) bool {
+	if NodeOS(node) != "linux" {
+		return false
+	}
+	arch := NodeArch(node)
+	if arch == "" {
+		return true
+	}
+	for _, a := range NodeAgentArchitectures {
+		if a == arch {
+			return true
+		}
+	}
+	return false
+}
+
+// schedulingOverrides returns the Helm value overrides of the scheduling
+// advice: a node affinity and tolerations for the node-agent DaemonSet, and a
+// Linux node selector for every component when there are non-Linux nodes.
+func schedulingOverrides(s *SchedulingAdvice) map[string]string {
+	overrides := map[string]string{}
+	if s == nil {
+		return overrides
+	}
+	var exprs []corev1.NodeSelectorRequirement
+	if s.LinuxOnly {
+		exprs = append(exprs, corev1.NodeSelectorRequirement{
+			Key: corev1.LabelOSStable, Operator: corev1.NodeSelectorOpIn, Values: []string{"linux"},
+		})
+		overrides["customScheduling.nodeSelector"] = yamlBlock(map[string]string{corev1.LabelOSStable: "linux"})
+	}
+	if len(s.Architectures) > 0 {
+		exprs = append(exprs, corev1.NodeSelectorRequirement{
+			Key: corev1.LabelArchStable, Operator: corev1.NodeSelectorOpIn, Values: s.Architectures,
+		})
+	}
+	if len(s.Tolerations) > 0 {
+		overrides["nodeAgent.tolerations"] = yamlBlock(s.Tolerations)
+	}
+	if len(exprs) > 0 {
+		overrides["nodeAgent.affinity"] = yamlBlock(corev1.Affinity{NodeAffinity: &corev1.NodeAffinity{
+			RequiredDuringSchedulingIgnoredDuringExecution: &corev1.NodeSelector{
+				NodeSelectorTerms: []corev1.NodeSelectorTerm{{MatchExpressions: exprs}},
+			},
+		}})
+	}
+	return overrides
+}
+
+// yamlBlock renders a structured Helm value as a YAML block, which
+// convertOverridesToYAML nests below its key.
+func yamlBlock(v any) string {
+	y, err := sigsyaml.Marshal(v)
+	if err != nil {
+		return "null"
+	}
+	return strings.TrimRight(string(y), "\n") + "\n"
+}
@@ -0,0 +1,38 @@
+package common
+
+// SizingProfile holds the chart default resources of the sized Kubescape
+// components, and the formulas their recommendations are computed with, for
+// the Kubescape chart releases from ChartVersion on.
+type SizingProfile struct {
+	ChartVersion string                      `json:"chartVersion"`
+	Components   map[string]ComponentProfile `json:"components"`
+	// Removed lists the components of the previous profile that the chart
+	// no longer ships; the other ones are inherited.
+	Removed []string `json:"removed,omitempty"`
+}
+
+// ComponentProfile sizes one chart component, e.g. nodeAgent. Its resources
+// are keyed cpuReq, cpuLim, memReq and memLim.
+type ComponentProfile struct {
+	// Defaults are the resources of the chart's values.yaml, e.g. "180Mi".
+	Defaults map[string]string `json:"defaults"`
+	// Formulas compute the recommended resources; resources without a
+	// formula keep their default.
+	Formulas map[string]SizingFormula `json:"formulas,omitempty"`
+}
+
+// SizingFormula computes a resource as the sum of its terms, in millicores
+// for CPU and MiB for memory.
+type SizingFormula struct {
+	Base float64 `json:"base,omitempty"`
+	// NodeCPUFraction and NodeMemoryFraction scale with the capacity of the
+	// largest node.
+	NodeCPUFraction    float64 `json:"nodeCPUFraction,omitempty"`
+	NodeMemoryFraction float64 `json:"nodeMemoryFraction,omitempty"`
+	// PerResource scales with the number of Kubernetes objects counted in
+	// the cluster, PerNode with the number of nodes.
+	PerResource float64 `json:"perResource,omitempty"`
+	PerNode     float64 `json:"perNode,omitempty"`
+	// PerImageMiB scales with the size of the largest container image.
+	PerImageMiB float64 `json:"perImageMiB,omitempty"`
+}
@@ -1,16 +1,81 @@
 package common
 
 import (
+	"time"
+
 	appsv1 "k8s.io/api/apps/v1"
 	batchv1 "k8s.io/api/batch/v1"
 	corev1 "k8s.io/api/core/v1"
 	storagev1 "k8s.io/api/storage/v1"
 )
 
-type ConnectivityResult struct {
-	AddressesTested []string
-	SuccessCount    int
-	ResultMessage   string // "Passed", "Failed", "Partial success (X/Y)", or "Skipped"
+// CheckStatus is the typed verdict of a check or of a single finding.
+type CheckStatus string
+
+const (
+	StatusPass  CheckStatus = "Pass"
+	StatusWarn  CheckStatus = "Warn"
+	StatusFail  CheckStatus = "Fail"
+	StatusSkip  CheckStatus = "Skip"
+	StatusError CheckStatus = "Error" // the check itself could not complete
+)
+
+// Severity orders statuses from harmless (Pass, Skip) to most severe (Fail).
+func (s CheckStatus) Severity() int {
+	switch s {
+	case StatusWarn:
+		return 1
+	case StatusError:
+		return 2
+	case StatusFail:
+		return 3
+	default:
+		return 0
+	}
+}
+
+// Finding is a single per-node or per-object observation made by a check.
+type Finding struct {
+	Kind    string      `json:"kind"` // e.g. "Node", "StorageClass", "Endpoint"
+	Name    string      `json:"name"`
+	Status  CheckStatus `json:"status"`
+	Reason  string      `json:"reason,omitempty"`
+	Message string      `json:"message,omitempty"`
+}
+
+// CheckResult is the outcome of a single Check as it appears in the report.
+type CheckResult struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+
+	Status      CheckStatus `json:"status"`
+	Reason      string      `json:"reason,omitempty"`      // machine-readable reason code, e.g. "NoDefaultStorageClass"
+	Message     string      `json:"message"`               // human-readable summary of the verdict
+	Remediation string      `json:"remediation,omitempty"` // hint on how to address a non-passing result
+	Findings    []Finding   `json:"findings,omitempty"`
+
+	// DurationMs is how long the check ran, in milliseconds.
+	DurationMs int64 `json:"durationMs"`
+
+	// Details holds check-specific data, e.g. *SizingResult. It is not part
+	// of the JSON report; the well-known details are flattened into ReportData.
+	Details interface{} `json:"-"`
+}
+
+// Escalate records an issue on the result. Status, reason and remediation are
+// replaced only when the new status is more severe than the current one, while
+// messages accumulate.
+func (r *CheckResult) Escalate(status CheckStatus, reason, message, remediation string) {
+	if r.Status == "" || status.Severity() > r.Status.Severity() {
+		r.Status = status
+		r.Reason = reason
+		r.Remediation = remediation
+	}
+	if r.Message == "" {
+		r.Message = message
+	} else {
+		r.Message += " | " + message
+	}
 }
 
 type SizingResult struct {
@@ -26,23 +91,44 @@ type SizingResult struct {
 
 	// Whether any resource changed from default
 	HasSizingAdjustments bool
+
+	// Confidence tells how complete the sizing inputs were (see SizingConfidence*).
+	Confidence string
+	// MissingInputs lists the resource kinds the sizing could not use.
+	MissingInputs []ResourceCollection
+	// ProfileVersion is the chart version of the sizing profile used.
+	ProfileVersion string
+
+	// NodeAgentPools sizes the node-agent per node pool.
+	NodeAgentPools []NodePoolSizing
+	// MultipleNodeAgentDaemonSets is set when the node pools need different
+	// node-agent resources and can all be selected by their pool label, so
+	// the node-agent is deployed as one DaemonSet per pool.
+	MultipleNodeAgentDaemonSets bool
 }
 
-type PVCheckResult struct {
-	PassedCount   int
-	FailedCount   int
-	TotalNodes    int
-	ResultMessage string // "Passed", "Failed", or "Skipped"
+// NodePoolSizing is the node-agent sizing of the Linux nodes of one node pool.
+type NodePoolSizing struct {
+	// Pool is "" for the nodes without a pool label.
+	Pool string `json:"pool"`
+	// Label is the node label the pool is selected with.
+	Label              string `json:"label,omitempty"`
+	Nodes              int    `json:"nodes"`
+	MaxNodeCPUCapacity int    `json:"maxNodeCPUCapacity"`
+	MaxNodeMemoryMB    int    `json:"maxNodeMemoryMB"`
+	// Resources are the recommended node-agent resources, keyed cpuReq,
+	// cpuLim, memReq and memLim.
+	Resources map[string]string `json:"resources"`
 }
 
 type NodeInfoSummary struct {
-	OperatingSystemCounts         map[string]int
-	ArchitectureCounts            map[string]int
-	KernelVersionCounts           map[string]int
-	OSImageCounts                 map[string]int
-	ContainerRuntimeVersionCounts map[string]int
-	KubeletVersionCounts          map[string]int
-	KubeProxyVersionCounts        map[string]int
+	OperatingSystemCounts         map[string]int `json:"operatingSystemCounts"`
+	ArchitectureCounts            map[string]int `json:"architectureCounts"`
+	KernelVersionCounts           map[string]int `json:"kernelVersionCounts"`
+	OSImageCounts                 map[string]int `json:"osImageCounts"`
+	ContainerRuntimeVersionCounts map[string]int `json:"containerRuntimeVersionCounts"`
+	KubeletVersionCounts          map[string]int `json:"kubeletVersionCounts"`
+	KubeProxyVersionCounts        map[string]int `json:"kubeProxyVersionCounts"`
 }
 
 // ClusterDetails stores metadata about the cluster
@@ -70,46 +156,142 @@ type ClusterData struct {
 
 	ClusterDetails    ClusterDetails
 	NodeInfoSummaries NodeInfoSummary
+
+	// Collection describes how the data was listed from the cluster.
+	Collection *CollectionStats `json:",omitempty"`
+
+	// NodeProbes holds the per-node results of the node probe DaemonSet
+	// (--node-probe), sorted by node name.
+	NodeProbes []NodeProbe `json:",omitempty"`
 }
 
-type EbpfResult struct {
-	ResultMessage string // "Passed", "Warning", "Failed", or any descriptive message
+// Sizing confidence levels, lowered when sizing inputs could not be collected.
+const (
+	SizingConfidenceHigh   = "high"
+	SizingConfidenceMedium = "medium" // object counts missing: object-count-based sizing is a lower bound
+	SizingConfidenceLow    = "low"    // nodes missing: node-based sizing falls back to defaults
+)
+
+// PhaseTiming records how long a phase of the run took.
+type PhaseTiming struct {
+	Name       string `json:"name"`
+	DurationMs int64  `json:"durationMs"`
+}
+
+// NewPhaseTiming returns the timing of the phase name that started at start.
+func NewPhaseTiming(name string, start time.Time) PhaseTiming {
+	return PhaseTiming{Name: name, DurationMs: time.Since(start).Milliseconds()}
+}
+
+// CollectionStats describes a CollectClusterData run.
+type CollectionStats struct {
+	PageSize int64 `json:"pageSize"`
+	Slim     bool  `json:"slim"`
+	// Pages is the number of List calls made.
+	Pages int `json:"pages"`
+	// PeakMemoryMiB is the largest Go heap size observed while collecting.
+	PeakMemoryMiB int `json:"peakMemoryMiB"`
+	// Resources records the outcome of collecting each resource kind.
+	Resources []ResourceCollection `json:"resources,omitempty"`
+}
+
+// ResourceCollection is the outcome of collecting one resource kind.
+type ResourceCollection struct {
+	Kind       string           `json:"kind"` // e.g. "nodes", see the Resource* constants
+	Status     CollectionStatus `json:"status"`
+	Count      int              `json:"count"`
+	DurationMs int64            `json:"durationMs"`
+	Error      string           `json:"error,omitempty"`
 }
 
 type ReportData struct {
-	TotalResources          int
-	MaxNodeCPUCapacity      int
-	MaxNodeMemoryMB         int
-	LargestContainerImageMB int
+	// ClusterName is the kubeconfig context the report was generated for,
+	// when one was selected with --context, --contexts or --all-contexts.
+	ClusterName string `json:"clusterName,omitempty"`
 
-	DefaultResourceAllocations map[string]map[string]string
-	FinalResourceAllocations   map[string]map[string]string
+	TotalResources          int `json:"totalResources"`
+	MaxNodeCPUCapacity      int `json:"maxNodeCPUCapacity"`
+	MaxNodeMemoryMB         int `json:"maxNodeMemoryMB"`
+	LargestContainerImageMB int `json:"largestContainerImageMB"`
 
-	KubernetesVersion string
-	CloudProvider     string
-	K8sDistribution   string
-	TotalNodeCount    int
-	TotalVCPUCount    int
+	DefaultResourceAllocations map[string]map[string]string `json:"defaultResourceAllocations"`
+	FinalResourceAllocations   map[string]map[string]string `json:"finalResourceAllocations"`
 
-	GenerationTime       string
-	HasSizingAdjustments bool
+	KubernetesVersion string `json:"kubernetesVersion"`
+	CloudProvider     string `json:"cloudProvider"`
+	K8sDistribution   string `json:"k8sDistribution"`
+	TotalNodeCount    int    `json:"totalNodeCount"`
+	TotalVCPUCount    int    `json:"totalVCPUCount"`
+
+	GenerationTime       string `json:"generationTime"`
+	HasSizingAdjustments bool   `json:"hasSizingAdjustments"`
+
+	NodeOSSummary               string `json:"nodeOSSummary"`
+	NodeArchSummary             string `json:"nodeArchSummary"`
+	NodeKernelVersionSummary    string `json:"nodeKernelVersionSummary"`
+	NodeOSImageSummary          string `json:"nodeOSImageSummary"`
+	NodeContainerRuntimeSummary string `json:"nodeContainerRuntimeSummary"`
+	NodeKubeletVersionSummary   string `json:"nodeKubeletVersionSummary"`
+	NodeKubeProxyVersionSummary string `json:"nodeKubeProxyVersionSummary"`
 
-	NodeOSSummary               string
-	NodeArchSummary             string
-	NodeKernelVersionSummary    string
-	NodeOSImageSummary          string
-	NodeContainerRuntimeSummary string
-	NodeKubeletVersionSummary   string
-	NodeKubeProxyVersionSummary string
+	// NodeInfo holds the raw per-attribute node counts behind the summaries above.
+	NodeInfo NodeInfoSummary `json:"nodeInfo"`
 
-	FullClusterData *ClusterData
+	FullClusterData *ClusterData `json:"-"`
 
-	PVProvisioningMessage    string
-	ConnectivityCheckMessage string
+	// PVProvisioningStatus mirrors the PV provisioning check verdict, which
+	// decides whether persistence has to be disabled in the Helm values.
+	PVProvisioningStatus CheckStatus `json:"pvProvisioningStatus"`
 
-	EBPFResultMessage string
+	// CheckResults holds the results of every check that ran, in run order.
+	CheckResults []*CheckResult `json:"checkResults"`
 
-	InCluster bool
+	InCluster bool `json:"inCluster"`
+
+	// DumpSource is the dump file the report was generated from with --from-dump.
+	DumpSource string `json:"dumpSource,omitempty"`
+
+	StorageClasses []string `json:"storageClasses"`
+
+	// Collection describes how the cluster data was listed; for offline
+	// runs it is carried over from the dump.
+	Collection *CollectionStats `json:"collection,omitempty"`
+
+	// SizingConfidence and MissingInputs tell how far the sizing can be
+	// trusted when some resources could not be collected.
+	SizingConfidence string               `json:"sizingConfidence,omitempty"`
+	MissingInputs    []ResourceCollection `json:"missingInputs,omitempty"`
+	// SizingProfileVersion is the chart version of the sizing profile the
+	// defaults and recommendations come from (--chart-version).
+	SizingProfileVersion string `json:"sizingProfileVersion,omitempty"`
+	// NodeAgentPools and MultipleNodeAgentDaemonSets carry the per-pool
+	// node-agent sizing (see SizingResult).
+	NodeAgentPools              []NodePoolSizing `json:"nodeAgentPools,omitempty"`
+	MultipleNodeAgentDaemonSets bool             `json:"multipleNodeAgentDaemonSets,omitempty"`
+
+	// Phases records how long the collection and check phases took.
+	Phases []PhaseTiming `json:"phases,omitempty"`
+
+	// NodeProbes holds the per-node node probe results (--node-probe).
+	NodeProbes []NodeProbe `json:"nodeProbes,omitempty"`
+
+	// Scheduling holds the scheduling constraints recommended in the Helm
+	// values to keep the components off unsupported nodes.
+	Scheduling *SchedulingAdvice `json:"scheduling,omitempty"`
+
+	// KernelFeatures is the feature table NodeFeatures was evaluated against;
+	// NodeFeatures holds which runtime detection features each Linux node supports.
+	KernelFeatures []KernelFeature `json:"kernelFeatures,omitempty"`
+	NodeFeatures   []NodeFeatures  `json:"nodeFeatures,omitempty"`
+
+	// File names the HTML report links to; set when the outputs are rendered.
+	ValuesFileName       string `json:"-"`
+	ReviewValuesFileName string `json:"-"`
+}
 
-	StorageClasses []string
+// JSONReport is the versioned, machine-readable form of ReportData written to
+// prerequisites-report.json. Its layout is described by ReportJSONSchema.
+type JSONReport struct {
+	SchemaVersion string `json:"schemaVersion"`
+	*ReportData
 }
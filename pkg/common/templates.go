@@ -9,3 +9,14 @@ var PrerequisitesReportHTML string
 
 //go:embed templates/review-values.html
 var ReviewValuesHTML string
+
+//go:embed templates/fleet-summary.html
+var FleetSummaryHTML string
+
+// ReportSchemaVersion is the version of the JSON report layout. Bump the minor
+// version for additive changes and the major version (and schema file) for
+// breaking ones.
+const ReportSchemaVersion = "1.11"
+
+//go:embed schemas/prerequisites-report.v1.schema.json
+var ReportJSONSchema string
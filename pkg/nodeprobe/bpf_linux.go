@@ -0,0 +1,35 @@
+//go:build linux
+
+package nodeprobe
+
+import (
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+
+	"github.com/kubescape/sizing-checker/pkg/common"
+)
+
+// bpfSyscall tests the bpf(2) syscall by creating, and closing, a one-entry
+// array map.
+func bpfSyscall() string {
+	attr := struct {
+		mapType    uint32
+		keySize    uint32
+		valueSize  uint32
+		maxEntries uint32
+	}{mapType: unix.BPF_MAP_TYPE_ARRAY, keySize: 4, valueSize: 4, maxEntries: 1}
+
+	fd, _, errno := unix.Syscall(unix.SYS_BPF, unix.BPF_MAP_CREATE, uintptr(unsafe.Pointer(&attr)), unsafe.Sizeof(attr))
+	switch errno {
+	case 0:
+		unix.Close(int(fd))
+		return common.BPFSyscallAvailable
+	case unix.EPERM, unix.EACCES:
+		return common.BPFSyscallDenied
+	case unix.ENOSYS:
+		return common.BPFSyscallNotImplemented
+	default:
+		return common.BPFSyscallUnknown
+	}
+}
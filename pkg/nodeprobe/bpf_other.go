@@ -0,0 +1,10 @@
+//go:build !linux
+
+package nodeprobe
+
+import "github.com/kubescape/sizing-checker/pkg/common"
+
+// bpfSyscall is only implemented on Linux.
+func bpfSyscall() string {
+	return common.BPFSyscallUnknown
+}
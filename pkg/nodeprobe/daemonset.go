@@ -0,0 +1,247 @@
+package nodeprobe
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	appsv1client "k8s.io/client-go/kubernetes/typed/apps/v1"
+
+	"github.com/kubescape/sizing-checker/pkg/common"
+)
+
+// Defaults of the node probe options.
+const (
+	DefaultImage     = "quay.io/danvid/kubescape-prerequisite"
+	DefaultNamespace = "kubescape-prerequisite"
+	DefaultTimeout   = 2 * time.Minute
+)
+
+const (
+	daemonSetName      = "kubescape-node-probe"
+	probeContainerName = "probe"
+	pauseImage         = "registry.k8s.io/pause:3.9"
+	runLabel           = "kubescape.io/node-probe-run"
+)
+
+// ProbeArg is the checker flag that makes it run as the node probe.
+const ProbeArg = "--run-node-probe"
+
+// pollInterval is how often the probe Pods are listed while waiting for results.
+var pollInterval = 2 * time.Second
+
+// Options configures the node probe DaemonSet.
+type Options struct {
+	// Namespace the DaemonSet is created in.
+	Namespace string
+	// Image is a checker image; the probe runs it with ProbeArg.
+	Image string
+	// Timeout bounds how long to wait for the results of all nodes.
+	Timeout time.Duration
+	// TolerateAll makes the probe tolerate every taint, instead of skipping
+	// tainted nodes.
+	TolerateAll bool
+}
+
+// Run schedules the probe DaemonSet on the Linux nodes it may run on, waits
+// for every node's result and removes the DaemonSet again. Nodes the probe
+// could not run on are reported with the reason.
+func Run(ctx context.Context, clientset kubernetes.Interface, nodes []corev1.Node, opts Options) []common.NodeProbe {
+	results := map[string]*common.NodeProbe{}
+	pending := map[string]string{} // node -> why it has no result yet
+	for i := range nodes {
+		node := &nodes[i]
+		res := &common.NodeProbe{Node: node.Name, Pool: common.NodePool(node)}
+		results[node.Name] = res
+		if os := common.NodeOS(node); os != "linux" {
+			res.Status = common.NodeProbeUnsupportedOS
+			res.Message = fmt.Sprintf("the probe only runs on Linux nodes (OS: %s)", os)
+			continue
+		}
+		if taints := common.BlockingTaints(node); len(taints) > 0 && !opts.TolerateAll {
+			res.Status = common.NodeProbeTainted
+			res.Message = "not probed, tainted with " + common.DescribeTaints(taints)
+			continue
+		}
+		pending[node.Name] = "no probe Pod was scheduled"
+	}
+
+	if len(pending) > 0 {
+		if err := deployAndWait(ctx, clientset, opts, results, pending); err != nil {
+			log.Printf("Node probe: %v", err)
+			for name := range pending {
+				pending[name] = err.Error()
+			}
+		}
+		for name, reason := range pending {
+			results[name].Status = common.NodeProbeTimeout
+			results[name].Message = reason
+		}
+	}
+
+	probes := make([]common.NodeProbe, 0, len(results))
+	for _, res := range results {
+		probes = append(probes, *res)
+	}
+	sort.Slice(probes, func(i, j int) bool { return probes[i].Node < probes[j].Node })
+	return probes
+}
+
+// deployAndWait creates the DaemonSet and collects results into results,
+// removing nodes from pending as they report.
+func deployAndWait(ctx context.Context, clientset kubernetes.Interface, opts Options,
+	results map[string]*common.NodeProbe, pending map[string]string) error {
+
+	runID := strconv.FormatInt(time.Now().UnixNano(), 36)
+	name := daemonSetName + "-" + runID
+	daemonSets := clientset.AppsV1().DaemonSets(opts.Namespace)
+
+	// Remove DaemonSets left over by interrupted runs
+	stale, err := daemonSets.List(ctx, metav1.ListOptions{LabelSelector: "app=" + daemonSetName})
+	if err != nil {
+		return fmt.Errorf("could not list previous probe DaemonSets: %w", err)
+	}
+	for _, ds := range stale.Items {
+		deleteDaemonSet(daemonSets, ds.Name)
+	}
+
+	if _, err := daemonSets.Create(ctx, buildDaemonSet(name, opts, runID), metav1.CreateOptions{}); err != nil {
+		return fmt.Errorf("could not create the probe DaemonSet in namespace %s: %w", opts.Namespace, err)
+	}
+	defer deleteDaemonSet(daemonSets, name)
+
+	waitCtx, cancel := context.WithTimeout(ctx, opts.Timeout)
+	defer cancel()
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+	for {
+		pods, err := clientset.CoreV1().Pods(opts.Namespace).List(waitCtx, metav1.ListOptions{LabelSelector: runLabel + "=" + runID})
+		if err == nil {
+			for i := range pods.Items {
+				collectResult(&pods.Items[i], results, pending)
+			}
+		}
+		if len(pending) == 0 {
+			return nil
+		}
+		select {
+		case <-waitCtx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// deleteDaemonSet deletes a probe DaemonSet and its Pods, even if the run's
+// context has expired.
+func deleteDaemonSet(daemonSets appsv1client.DaemonSetInterface, name string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	background := metav1.DeletePropagationBackground
+	err := daemonSets.Delete(ctx, name, metav1.DeleteOptions{PropagationPolicy: &background})
+	if err != nil && !apierrors.IsNotFound(err) {
+		log.Printf("Node probe: could not delete DaemonSet %s: %v", name, err)
+	}
+}
+
+// collectResult reads the probe result of a Pod, or records why it has none yet.
+func collectResult(pod *corev1.Pod, results map[string]*common.NodeProbe, pending map[string]string) {
+	node := pod.Spec.NodeName
+	if _, ok := pending[node]; !ok {
+		return
+	}
+	for _, st := range pod.Status.InitContainerStatuses {
+		if st.Name != probeContainerName {
+			continue
+		}
+		switch {
+		case st.State.Terminated != nil:
+			res := results[node]
+			var probe common.NodeProbe
+			if err := json.Unmarshal([]byte(st.State.Terminated.Message), &probe); err != nil {
+				res.Status = common.NodeProbeFailed
+				res.Message = fmt.Sprintf("unreadable probe result (exit code %d): %v", st.State.Terminated.ExitCode, err)
+			} else {
+				probe.Node, probe.Pool, probe.Status = res.Node, res.Pool, common.NodeProbeOK
+				*res = probe
+			}
+			delete(pending, node)
+			return
+		case st.State.Waiting != nil && st.State.Waiting.Reason != "":
+			pending[node] = fmt.Sprintf("probe Pod %s is waiting: %s", pod.Name, st.State.Waiting.Reason)
+			return
+		}
+	}
+	pending[node] = fmt.Sprintf("probe Pod %s is %s", pod.Name, pod.Status.Phase)
+}
+
+// buildDaemonSet returns the probe DaemonSet: the probe runs once as an init
+// container and leaves its result in the termination message, then the Pod
+// idles until the DaemonSet is deleted.
+func buildDaemonSet(name string, opts Options, runID string) *appsv1.DaemonSet {
+	labels := map[string]string{"app": daemonSetName, runLabel: runID}
+	privileged := true
+	root := int64(0)
+	noToken := false
+
+	var tolerations []corev1.Toleration
+	if opts.TolerateAll {
+		tolerations = []corev1.Toleration{{Operator: corev1.TolerationOpExists}}
+	}
+
+	return &appsv1.DaemonSet{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: opts.Namespace, Labels: labels},
+		Spec: appsv1.DaemonSetSpec{
+			Selector: &metav1.LabelSelector{MatchLabels: labels},
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{Labels: labels},
+				Spec: corev1.PodSpec{
+					NodeSelector:                 map[string]string{corev1.LabelOSStable: "linux"},
+					Tolerations:                  tolerations,
+					AutomountServiceAccountToken: &noToken,
+					InitContainers: []corev1.Container{{
+						Name:  probeContainerName,
+						Image: opts.Image,
+						Args:  []string{ProbeArg},
+						Env: []corev1.EnvVar{{
+							Name:      "NODE_NAME",
+							ValueFrom: &corev1.EnvVarSource{FieldRef: &corev1.ObjectFieldSelector{FieldPath: "spec.nodeName"}},
+						}},
+						SecurityContext:          &corev1.SecurityContext{Privileged: &privileged, RunAsUser: &root},
+						TerminationMessagePolicy: corev1.TerminationMessageReadFile,
+						VolumeMounts:             []corev1.VolumeMount{{Name: "host-root", MountPath: HostRoot, ReadOnly: true}},
+						Resources:                probeResources("50m", "64Mi"),
+					}},
+					Containers: []corev1.Container{{
+						Name:      "pause",
+						Image:     pauseImage,
+						Resources: probeResources("10m", "16Mi"),
+					}},
+					// The whole host filesystem is mounted, as the kernel config
+					// and BTF files live in different places per distribution
+					// and mounting a missing directory would fail the Pod.
+					Volumes: []corev1.Volume{{Name: "host-root", VolumeSource: corev1.VolumeSource{
+						HostPath: &corev1.HostPathVolumeSource{Path: "/"},
+					}}},
+				},
+			},
+		},
+	}
+}
+
+func probeResources(cpu, memory string) corev1.ResourceRequirements {
+	return corev1.ResourceRequirements{
+		Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse(cpu), corev1.ResourceMemory: resource.MustParse(memory)},
+		Limits:   corev1.ResourceList{corev1.ResourceMemory: resource.MustParse(memory)},
+	}
+}
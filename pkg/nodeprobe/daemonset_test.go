@@ -0,0 +1,138 @@
+package nodeprobe
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	k8stesting "k8s.io/client-go/testing"
+
+	"github.com/kubescape/sizing-checker/pkg/common"
+	"github.com/kubescape/sizing-checker/pkg/testutil"
+)
+
+// probePod returns a probe Pod of the DaemonSet ds on node with the given
+// probe container state.
+func probePod(ds *appsv1.DaemonSet, node string, state corev1.ContainerState) *corev1.Pod {
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: ds.Name + "-" + node, Namespace: ds.Namespace, Labels: ds.Spec.Template.Labels},
+		Spec:       corev1.PodSpec{NodeName: node},
+		Status: corev1.PodStatus{
+			Phase:                 corev1.PodPending,
+			InitContainerStatuses: []corev1.ContainerStatus{{Name: probeContainerName, State: state}},
+		},
+	}
+}
+
+var errForbidden = apierrors.NewForbidden(appsv1.Resource("daemonsets"), "", nil)
+
+func TestRun(t *testing.T) {
+	defer func(d time.Duration) { pollInterval = d }(pollInterval)
+	pollInterval = 10 * time.Millisecond
+
+	nodes := []corev1.Node{
+		*testutil.Node("ready", "4", "16Gi", testutil.WithLabels(map[string]string{"eks.amazonaws.com/nodegroup": "general"})),
+		*testutil.Node("pulling", "4", "16Gi"),
+		*testutil.Node("garbled", "4", "16Gi"),
+		*testutil.Node("gpu", "4", "16Gi", testutil.WithTaint("nvidia.com/gpu", "true", corev1.TaintEffectNoSchedule)),
+		*testutil.Node("cordoned", "4", "16Gi", testutil.Unschedulable(),
+			testutil.WithTaint(corev1.TaintNodeUnschedulable, "", corev1.TaintEffectNoSchedule)),
+		*testutil.Node("win", "4", "16Gi", testutil.WithOS("windows", "amd64")),
+	}
+	cs := testutil.NewClientset()
+	// Emulate the DaemonSet controller and the kubelets
+	cs.PrependReactor("create", "daemonsets", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		ds := action.(k8stesting.CreateAction).GetObject().(*appsv1.DaemonSet)
+		result := `{"node":"ignored","status":"ok","kernelRelease":"6.1.0","kernelConfigSource":"/boot/config-6.1.0",` +
+			`"kernelConfig":{"CONFIG_BPF":"y","CONFIG_BPF_SYSCALL":"y"},"btf":true,"bpfSyscall":"available"}`
+		pods := []*corev1.Pod{
+			probePod(ds, "ready", corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{Message: result}}),
+			probePod(ds, "cordoned", corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{Message: result}}),
+			probePod(ds, "pulling", corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{Reason: "ImagePullBackOff"}}),
+			probePod(ds, "garbled", corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{Message: "exec format error", ExitCode: 1}}),
+		}
+		for _, p := range pods {
+			if err := cs.Tracker().Add(p); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return false, nil, nil
+	})
+
+	probes := Run(context.Background(), cs, nodes, Options{Namespace: "probes", Image: "checker", Timeout: 200 * time.Millisecond})
+
+	want := map[string]common.NodeProbeStatus{
+		"cordoned": common.NodeProbeOK,
+		"garbled":  common.NodeProbeFailed,
+		"gpu":      common.NodeProbeTainted,
+		"pulling":  common.NodeProbeTimeout,
+		"ready":    common.NodeProbeOK,
+		"win":      common.NodeProbeUnsupportedOS,
+	}
+	if len(probes) != len(want) {
+		t.Fatalf("got %d probes, want %d: %+v", len(probes), len(want), probes)
+	}
+	for i, p := range probes {
+		if i > 0 && probes[i-1].Node > p.Node {
+			t.Errorf("probes are not sorted by node: %s before %s", probes[i-1].Node, p.Node)
+		}
+		if p.Status != want[p.Node] {
+			t.Errorf("%s: status = %s (%s), want %s", p.Node, p.Status, p.Message, want[p.Node])
+		}
+		switch p.Node {
+		case "ready":
+			if p.Pool != "general" || p.KernelRelease != "6.1.0" || p.ConfigValue("CONFIG_BPF") != "y" || !p.BTF {
+				t.Errorf("ready = %+v", p)
+			}
+		case "pulling":
+			if !strings.Contains(p.Message, "ImagePullBackOff") {
+				t.Errorf("pulling message = %q, want the waiting reason", p.Message)
+			}
+		case "gpu":
+			if !strings.Contains(p.Message, "nvidia.com/gpu=true:NoSchedule") {
+				t.Errorf("gpu message = %q, want the taint", p.Message)
+			}
+		}
+	}
+
+	daemonSets, err := cs.AppsV1().DaemonSets("probes").List(context.Background(), metav1.ListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(daemonSets.Items) != 0 {
+		t.Errorf("probe DaemonSet was not deleted: %v", daemonSets.Items)
+	}
+}
+
+func TestRunCreateForbidden(t *testing.T) {
+	cs := testutil.NewClientset()
+	cs.PrependReactor("create", "daemonsets", func(k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, errForbidden
+	})
+	probes := Run(context.Background(), cs, []corev1.Node{*testutil.Node("n1", "4", "16Gi")},
+		Options{Namespace: "probes", Image: "checker", Timeout: time.Second})
+	if len(probes) != 1 || probes[0].Status != common.NodeProbeTimeout || !strings.Contains(probes[0].Message, "could not create") {
+		t.Errorf("probes = %+v, want n1 not probed because of the create error", probes)
+	}
+}
+
+func TestBuildDaemonSet(t *testing.T) {
+	ds := buildDaemonSet("kubescape-node-probe-x", Options{Namespace: "probes", Image: "checker:v1", TolerateAll: true}, "x")
+	spec := ds.Spec.Template.Spec
+	if len(spec.Tolerations) != 1 || spec.Tolerations[0].Operator != corev1.TolerationOpExists {
+		t.Errorf("tolerations = %+v, want tolerate all", spec.Tolerations)
+	}
+	probe := spec.InitContainers[0]
+	if probe.Image != "checker:v1" || probe.Args[0] != ProbeArg || !*probe.SecurityContext.Privileged {
+		t.Errorf("probe container = %+v", probe)
+	}
+	if got := ds.Spec.Selector.MatchLabels[runLabel]; got != "x" {
+		t.Errorf("selector run label = %q, want x", got)
+	}
+}
@@ -0,0 +1,184 @@
+package nodeprobe
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/kubescape/sizing-checker/pkg/common"
+)
+
+// HostRoot is where the probe DaemonSet mounts the host filesystem the kernel
+// config and BTF files are read from.
+const HostRoot = "/host"
+
+// terminationLogPath is where the probe writes its result, for the checker to
+// read from the Pod status.
+const terminationLogPath = "/dev/termination-log"
+
+// ProbedConfig lists the kernel config options the probe reports: the flags of
+// the common.KernelFeatures table, and the BTF option.
+var ProbedConfig = append(common.KernelFeatureFlags(), "CONFIG_DEBUG_INFO_BTF")
+
+// Gather inspects the node it runs on. Host files are read below root ("" on
+// the node itself, HostRoot in the probe Pod); /proc and /sys are always read
+// directly as they describe the running kernel.
+func Gather(node, root string) common.NodeProbe {
+	probe := common.NodeProbe{Node: node, Status: common.NodeProbeOK, BPFSyscall: bpfSyscall(), CgroupVersion: CgroupVersion()}
+
+	release, err := KernelRelease()
+	if err != nil {
+		probe.Message = err.Error()
+		return probe
+	}
+	probe.KernelRelease = release
+
+	config, source, err := ReadKernelConfig(root, release)
+	if err != nil {
+		probe.Message = err.Error()
+	} else {
+		probe.KernelConfigSource = source
+		probe.KernelConfig = ParseKernelConfig(config, ProbedConfig)
+	}
+	probe.BTF = BTFAvailable(root, release, config)
+	return probe
+}
+
+// WriteResult writes the probe result to the termination log, where the
+// checker reads it from, and to stdout for kubectl logs.
+func WriteResult(probe common.NodeProbe) error {
+	data, err := json.Marshal(probe)
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(data))
+	return os.WriteFile(terminationLogPath, data, 0644)
+}
+
+// KernelRelease reads the release of the running kernel, e.g. "5.15.0-1051-aws".
+func KernelRelease() (string, error) {
+	data, err := os.ReadFile("/proc/sys/kernel/osrelease")
+	if err != nil {
+		return "", fmt.Errorf("cannot read /proc/sys/kernel/osrelease: %w", err)
+	}
+	release := strings.TrimSpace(string(data))
+	if release == "" {
+		return "", errors.New("local kernel version is empty")
+	}
+	return release, nil
+}
+
+// procConfigPath is the config of the running kernel, exposed by kernels
+// built with CONFIG_IKCONFIG_PROC (e.g. COS, Bottlerocket, Talos).
+var procConfigPath = "/proc/config.gz"
+
+// kernelConfigSources are the files the config of kernel release is looked
+// up in, in order.
+func kernelConfigSources(release string) []string {
+	return []string{
+		"/boot/config-" + release,
+		procConfigPath,
+		"/lib/modules/" + release + "/config",
+		"/usr/src/linux-" + release + "/.config",
+	}
+}
+
+// ReadKernelConfig reads the config of kernel release from the first
+// readable source: /boot/config-<release>, /proc/config.gz,
+// /lib/modules/<release>/config or /usr/src/linux-<release>/.config. Host
+// files are read below root, /proc/config.gz directly as it describes the
+// running kernel. It returns the config and the file it was read from.
+func ReadKernelConfig(root, release string) (string, string, error) {
+	sources := kernelConfigSources(release)
+	for _, source := range sources {
+		path := source
+		if source != procConfigPath {
+			path = filepath.Join(root, source)
+		}
+		config, err := readConfigFile(path)
+		if err == nil {
+			return config, source, nil
+		}
+	}
+	return "", "", fmt.Errorf("could not read kernel config from any of %s", strings.Join(sources, ", "))
+}
+
+// readConfigFile reads a kernel config, decompressing it if it is gzipped.
+func readConfigFile(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	var r io.Reader = f
+	if strings.HasSuffix(path, ".gz") {
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			return "", fmt.Errorf("%s: %w", path, err)
+		}
+		defer gz.Close()
+		r = gz
+	}
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", path, err)
+	}
+	return string(data), nil
+}
+
+// ParseKernelConfig returns the values of the given options in a kernel
+// config. Options that are not set are left out.
+func ParseKernelConfig(config string, options []string) map[string]string {
+	wanted := map[string]bool{}
+	for _, o := range options {
+		wanted[o] = true
+	}
+	values := map[string]string{}
+	for _, line := range strings.Split(config, "\n") {
+		name, value, ok := strings.Cut(strings.TrimSpace(line), "=")
+		if ok && wanted[name] && value != "n" {
+			values[name] = strings.Trim(value, `"`)
+		}
+	}
+	return values
+}
+
+// BTFAvailable reports whether the kernel exposes BTF type information:
+// /sys/kernel/btf/vmlinux, a vmlinux next to the kernel or modules, or
+// CONFIG_DEBUG_INFO_BTF=y in its config.
+func BTFAvailable(root, release, config string) bool {
+	if fileExists("/sys/kernel/btf/vmlinux") {
+		return true
+	}
+	for _, p := range []string{"/boot/vmlinux-" + release, "/lib/modules/" + release + "/vmlinux"} {
+		if fileExists(filepath.Join(root, p)) {
+			return true
+		}
+	}
+	return ParseKernelConfig(config, []string{"CONFIG_DEBUG_INFO_BTF"})["CONFIG_DEBUG_INFO_BTF"] == "y"
+}
+
+// CgroupVersion returns the cgroup hierarchy mounted at /sys/fs/cgroup: v2
+// when it is the unified hierarchy, v1 otherwise, or "" without cgroupfs.
+func CgroupVersion() string {
+	if fileExists("/sys/fs/cgroup/cgroup.controllers") {
+		return common.CgroupV2
+	}
+	if info, err := os.Stat("/sys/fs/cgroup"); err == nil && info.IsDir() {
+		return common.CgroupV1
+	}
+	return ""
+}
+
+func fileExists(path string) bool {
+	info, err := os.Stat(path)
+	if err != nil {
+		return false
+	}
+	return !info.IsDir()
+}
@@ -0,0 +1,89 @@
+package nodeprobe
+
+import (
+	"bytes"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+const sampleConfig = `#
+# Automatically generated file; DO NOT EDIT.
+CONFIG_BPF=y
+CONFIG_BPF_SYSCALL=y
+CONFIG_BPF_JIT=m
+# CONFIG_BPF_LSM is not set
+CONFIG_DEBUG_INFO_BTF=n
+CONFIG_LSM="lockdown,yama,bpf"
+`
+
+func TestParseKernelConfig(t *testing.T) {
+	got := ParseKernelConfig(sampleConfig, []string{"CONFIG_BPF", "CONFIG_BPF_JIT", "CONFIG_BPF_LSM", "CONFIG_DEBUG_INFO_BTF", "CONFIG_LSM"})
+	want := map[string]string{"CONFIG_BPF": "y", "CONFIG_BPF_JIT": "m", "CONFIG_LSM": "lockdown,yama,bpf"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseKernelConfig() = %v, want %v", got, want)
+	}
+}
+
+func TestReadKernelConfig(t *testing.T) {
+	const release = "5.15.0-1051-aws"
+	var gzipped bytes.Buffer
+	gz := gzip.NewWriter(&gzipped)
+	gz.Write([]byte(sampleConfig))
+	gz.Close()
+
+	tests := []struct {
+		name       string
+		files      map[string][]byte // below root
+		procConfig []byte
+		wantSource string
+	}{
+		{name: "boot", files: map[string][]byte{"boot/config-" + release: []byte(sampleConfig)}, wantSource: "/boot/config-" + release},
+		{name: "proc config.gz", procConfig: gzipped.Bytes(), wantSource: "/proc/config.gz"},
+		{name: "lib modules", files: map[string][]byte{"lib/modules/" + release + "/config": []byte(sampleConfig)}, wantSource: "/lib/modules/" + release + "/config"},
+		{name: "kernel sources", files: map[string][]byte{"usr/src/linux-" + release + "/.config": []byte(sampleConfig)}, wantSource: "/usr/src/linux-" + release + "/.config"},
+		{name: "boot first", procConfig: gzipped.Bytes(),
+			files: map[string][]byte{"boot/config-" + release: []byte(sampleConfig)}, wantSource: "/boot/config-" + release},
+		{name: "missing"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := t.TempDir()
+			for name, data := range tt.files {
+				path := filepath.Join(root, name)
+				if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(path, data, 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			defer func(p string) { procConfigPath = p }(procConfigPath)
+			procConfigPath = filepath.Join(t.TempDir(), "config.gz")
+			if tt.procConfig != nil {
+				if err := os.WriteFile(procConfigPath, tt.procConfig, 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			config, source, err := ReadKernelConfig(root, release)
+			if tt.wantSource == "" {
+				if err == nil {
+					t.Errorf("ReadKernelConfig() read %s, want an error", source)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if source == procConfigPath {
+				source = "/proc/config.gz"
+			}
+			if source != tt.wantSource || config != sampleConfig {
+				t.Errorf("ReadKernelConfig() source = %q, want %q", source, tt.wantSource)
+			}
+		})
+	}
+}
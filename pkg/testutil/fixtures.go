@@ -0,0 +1,186 @@
+// Package testutil provides Kubernetes object fixtures and a fake API server
+// for the collector and check tests.
+package testutil
+
+import (
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/version"
+	fakediscovery "k8s.io/client-go/discovery/fake"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+// DefaultServerVersion is the version reported by NewClientset.
+const DefaultServerVersion = "v1.31.2"
+
+// NewClientset returns a fake clientset serving the given objects and
+// reporting DefaultServerVersion from discovery.
+func NewClientset(objects ...runtime.Object) *fake.Clientset {
+	cs := fake.NewClientset(objects...)
+	cs.Discovery().(*fakediscovery.FakeDiscovery).FakedServerVersion = &version.Info{
+		GitVersion: DefaultServerVersion,
+		Major:      "1",
+		Minor:      "31",
+	}
+	return cs
+}
+
+// NodeOption customizes a Node fixture.
+type NodeOption func(*corev1.Node)
+
+// Node returns a Ready Linux/amd64 node with the given capacity, e.g. Node("n1", "4", "16Gi").
+func Node(name, cpu, memory string, opts ...NodeOption) *corev1.Node {
+	capacity := corev1.ResourceList{
+		corev1.ResourceCPU:    resource.MustParse(cpu),
+		corev1.ResourceMemory: resource.MustParse(memory),
+	}
+	n := &corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Labels: map[string]string{}},
+		Status: corev1.NodeStatus{
+			Capacity:    capacity,
+			Allocatable: capacity.DeepCopy(),
+			NodeInfo: corev1.NodeSystemInfo{
+				OperatingSystem:         "linux",
+				Architecture:            "amd64",
+				KernelVersion:           "6.1.0-27-cloud-amd64",
+				OSImage:                 "Debian GNU/Linux 12 (bookworm)",
+				ContainerRuntimeVersion: "containerd://1.7.22",
+				KubeletVersion:          DefaultServerVersion,
+				KubeProxyVersion:        DefaultServerVersion,
+			},
+			Conditions: []corev1.NodeCondition{{Type: corev1.NodeReady, Status: corev1.ConditionTrue}},
+		},
+	}
+	for _, opt := range opts {
+		opt(n)
+	}
+	return n
+}
+
+// WithOS sets the node operating system and architecture.
+func WithOS(os, arch string) NodeOption {
+	return func(n *corev1.Node) {
+		n.Status.NodeInfo.OperatingSystem = os
+		n.Status.NodeInfo.Architecture = arch
+		n.Labels[corev1.LabelOSStable] = os
+		n.Labels[corev1.LabelArchStable] = arch
+	}
+}
+
+// WithKernel sets the node kernel version.
+func WithKernel(version string) NodeOption {
+	return func(n *corev1.Node) { n.Status.NodeInfo.KernelVersion = version }
+}
+
+// WithRuntime sets the node container runtime version, e.g. "containerd://1.7.2".
+func WithRuntime(version string) NodeOption {
+	return func(n *corev1.Node) { n.Status.NodeInfo.ContainerRuntimeVersion = version }
+}
+
+// WithLabels adds labels to the node.
+func WithLabels(labels map[string]string) NodeOption {
+	return func(n *corev1.Node) {
+		for k, v := range labels {
+			n.Labels[k] = v
+		}
+	}
+}
+
+// WithProviderID sets the node provider ID, e.g. "aws:///eu-west-1a/i-0123".
+func WithProviderID(id string) NodeOption {
+	return func(n *corev1.Node) { n.Spec.ProviderID = id }
+}
+
+// WithImage adds a container image of the given size to the node status.
+func WithImage(name string, sizeBytes int64) NodeOption {
+	return func(n *corev1.Node) {
+		n.Status.Images = append(n.Status.Images, corev1.ContainerImage{Names: []string{name}, SizeBytes: sizeBytes})
+	}
+}
+
+// WithTaint adds a taint to the node.
+func WithTaint(key, value string, effect corev1.TaintEffect) NodeOption {
+	return func(n *corev1.Node) {
+		n.Spec.Taints = append(n.Spec.Taints, corev1.Taint{Key: key, Value: value, Effect: effect})
+	}
+}
+
+// Unschedulable cordons the node.
+func Unschedulable() NodeOption {
+	return func(n *corev1.Node) { n.Spec.Unschedulable = true }
+}
+
+// StorageClass returns a StorageClass, optionally annotated as the cluster default.
+func StorageClass(name, provisioner string, isDefault bool) *storagev1.StorageClass {
+	sc := &storagev1.StorageClass{
+		ObjectMeta:  metav1.ObjectMeta{Name: name},
+		Provisioner: provisioner,
+	}
+	if isDefault {
+		sc.Annotations = map[string]string{"storageclass.kubernetes.io/is-default-class": "true"}
+	}
+	return sc
+}
+
+// Pod returns a running pod scheduled on nodeName with a single container
+// requesting the given CPU and memory (empty strings mean no request).
+func Pod(namespace, name, nodeName, cpuReq, memReq string) *corev1.Pod {
+	requests := corev1.ResourceList{}
+	if cpuReq != "" {
+		requests[corev1.ResourceCPU] = resource.MustParse(cpuReq)
+	}
+	if memReq != "" {
+		requests[corev1.ResourceMemory] = resource.MustParse(memReq)
+	}
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name, Labels: map[string]string{"app": name}},
+		Spec: corev1.PodSpec{
+			NodeName: nodeName,
+			Containers: []corev1.Container{{
+				Name:      "main",
+				Image:     "registry.k8s.io/pause:3.9",
+				Resources: corev1.ResourceRequirements{Requests: requests},
+			}},
+		},
+		Status: corev1.PodStatus{Phase: corev1.PodRunning},
+	}
+}
+
+// PVC returns a PersistentVolumeClaim in the given phase, bound to volumeName if set.
+func PVC(namespace, name string, phase corev1.PersistentVolumeClaimPhase, volumeName string) *corev1.PersistentVolumeClaim {
+	return &corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name},
+		Spec:       corev1.PersistentVolumeClaimSpec{VolumeName: volumeName},
+		Status:     corev1.PersistentVolumeClaimStatus{Phase: phase},
+	}
+}
+
+// PV returns a PersistentVolume in the given phase.
+func PV(name string, phase corev1.PersistentVolumePhase) *corev1.PersistentVolume {
+	return &corev1.PersistentVolume{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Status:     corev1.PersistentVolumeStatus{Phase: phase},
+	}
+}
+
+// Workloads returns one object of every workload kind the collector lists,
+// all in the given namespace.
+func Workloads(namespace string) []runtime.Object {
+	meta := func(name string) metav1.ObjectMeta {
+		return metav1.ObjectMeta{Namespace: namespace, Name: name}
+	}
+	return []runtime.Object{
+		&corev1.Service{ObjectMeta: meta("svc")},
+		&appsv1.Deployment{ObjectMeta: meta("deploy")},
+		&appsv1.ReplicaSet{ObjectMeta: meta("deploy-5d4f")},
+		&appsv1.StatefulSet{ObjectMeta: meta("sts")},
+		&appsv1.DaemonSet{ObjectMeta: meta("ds")},
+		&batchv1.Job{ObjectMeta: meta("job")},
+		&batchv1.CronJob{ObjectMeta: meta("cron")},
+	}
+}